@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"laravel-setup/pkg/backup"
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// runBackup takes, lists and restores database backups
+func runBackup(ctx context.Context, args []string) error {
+	fs := newFlagSet("backup", "backup [flags] now | list | restore <id>",
+		"Take, list and restore database backups.\n\n"+
+			"  now            Dump the database now, apply the retention policy and\n"+
+			"                 upload it when a bucket is configured\n"+
+			"  list           Show the backups in the manifest, or in the bucket with --remote\n"+
+			"  restore <id>   Replace the database with a backup, after backing up\n"+
+			"                 the current database; a backup only kept in the bucket\n"+
+			"                 is downloaded first")
+	configPath := addConfigPathFlag(fs)
+	locking := addLockFlags(fs)
+	yes := fs.Bool("yes", false, "Restore without asking for confirmation")
+	remote := fs.Bool("remote", false, "List the backups uploaded to the bucket")
+	storage := fs.Bool("storage", false, "Also restore the storage/app directory archived with the backup")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	switch action := fs.Arg(0); action {
+	case "", "list":
+		return listBackups(ctx, cfg, *remote)
+	case "now":
+		release, err := locking.acquire(ctx, "backup now")
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		_, err = backup.Now(ctx, cfg)
+		return err
+	case "restore":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return fmt.Errorf("backup restore needs a backup ID, see 'laravel-setup backup list'")
+		}
+
+		id := fs.Arg(1)
+		if !*yes {
+			ok, err := utils.Confirm("Replace database " + cfg.DBName + " with backup " + id + "?")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("restore cancelled")
+			}
+		}
+
+		release, err := locking.acquire(ctx, "backup restore")
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return backup.Restore(ctx, cfg, id, *storage)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown backup action: %s", action)
+	}
+}
+
+// listBackups prints the backups in the manifest or in the bucket, newest first
+func listBackups(ctx context.Context, cfg *config.Config, remote bool) error {
+	list, where := backup.List, cfg.Backup.WithDefaults().Dir
+	if remote {
+		list, where = backup.ListRemote, "bucket "+cfg.Backup.Remote.Bucket
+	}
+	entries, err := list(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	utils.PrintHeader("Database Backups in " + where)
+	if len(entries) == 0 {
+		fmt.Println("  No backups")
+		return nil
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		fmt.Printf("  %-17s %-20s %-10s %10s  %s\n", entry.ID, entry.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			entry.Engine, formatSize(entry.Size), entry.File)
+	}
+	return nil
+}
+
+// formatSize formats a byte count for display
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"laravel-setup/pkg/utils"
+)
+
+// runCleanup removes temporary files left behind by the setup
+func runCleanup(ctx context.Context, args []string) error {
+	fs := newFlagSet("cleanup", "cleanup",
+		"Remove the temporary configuration files the setup writes to the current\n"+
+			"directory before moving them into place.")
+	locking := addLockFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	release, err := locking.acquire(ctx, "cleanup")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return utils.CleanupTempFiles()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// runConfig prints the effective configuration
+func runConfig(_ context.Context, args []string) error {
+	fs := newFlagSet("config", "config [flags]",
+		"Print the effective configuration as TOML, after defaults are applied.\n"+
+			"Passwords and keys are masked unless -show-secrets is given.")
+	configPath := addConfigPathFlag(fs)
+	showSecrets := fs.Bool("show-secrets", false, "Print passwords and keys instead of masking them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize configuration: %w", err)
+	}
+
+	if !*showSecrets {
+		cfg.DBPassword = maskSecret(cfg.DBPassword)
+		cfg.DBRootPassword = maskSecret(cfg.DBRootPassword)
+		cfg.Database.HostAdminPassword = maskSecret(cfg.Database.HostAdminPassword)
+		cfg.Redis.Password = maskSecret(cfg.Redis.Password)
+		cfg.Backup.Remote.SecretAccessKey = maskSecret(cfg.Backup.Remote.SecretAccessKey)
+		cfg.Backup.Remote.Passphrase = maskSecret(cfg.Backup.Remote.Passphrase)
+	}
+
+	utils.PrintHeader("Effective Configuration")
+	return toml.NewEncoder(os.Stdout).Encode(cfg)
+}
+
+// maskSecret hides a secret value, leaving empty values empty so it's clear they will be generated
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/laravel"
+)
+
+// runDB manages the database accounts
+func runDB(ctx context.Context, args []string) error {
+	fs := newFlagSet("db", "db [flags] rotate-password",
+		"Manage the database accounts.\n\n"+
+			"  rotate-password   Give an account a new password and save it in the credentials\n"+
+			"                    store; for the app account, .env is updated and PHP-FPM and\n"+
+			"                    the queue workers reloaded, and the change is rolled back if\n"+
+			"                    the application stops working")
+	configPath := addConfigPathFlag(fs)
+	locking := addLockFlags(fs)
+	account := fs.String("account", config.ProfileApp, "Grant profile of the account: app, migrate, readonly or admin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	switch action := fs.Arg(0); action {
+	case "rotate-password":
+		release, err := locking.acquire(ctx, "db rotate-password")
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return laravel.RotateDatabasePassword(ctx, cfg, *account)
+	case "":
+		fs.Usage()
+		return fmt.Errorf("db needs an action")
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown db action: %s", action)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+
+	"laravel-setup/pkg/deploy"
+)
+
+// runDeploy pulls the latest code and rebuilds the application
+func runDeploy(ctx context.Context, args []string) error {
+	fs := newFlagSet("deploy", "deploy [flags]",
+		"Pull the latest code into the web root, install Composer dependencies,\n"+
+			"run migrations, rebuild the Laravel caches and restart the queue workers.")
+	configPath := addConfigPathFlag(fs)
+	locking := addLockFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	release, err := locking.acquire(ctx, "deploy")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return deploy.Deploy(ctx, cfg)
+}
+
+// runRollback returns the application to the previously deployed revision
+func runRollback(ctx context.Context, args []string) error {
+	fs := newFlagSet("rollback", "rollback [flags]",
+		"Check out the revision that was live before the last deploy, reinstall\n"+
+			"dependencies, rebuild the caches and restart the queue workers.\n"+
+			"Database migrations are not reverted.")
+	configPath := addConfigPathFlag(fs)
+	locking := addLockFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	release, err := locking.acquire(ctx, "rollback")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return deploy.Rollback(ctx, cfg)
+}
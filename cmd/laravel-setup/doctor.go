@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/doctor"
+)
+
+// runDoctor checks that the host and configuration are ready for setup
+func runDoctor(ctx context.Context, args []string) error {
+	fs := newFlagSet("doctor", "doctor [flags]",
+		"Check that the host and the configuration are ready for setup: the user,\n"+
+			"sudo access, the operating system, required commands and settings.")
+	configPath := addConfigPathFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize configuration: %w", err)
+	}
+
+	_, err = doctor.Run(ctx, cfg)
+	return err
+}
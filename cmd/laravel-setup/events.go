@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"laravel-setup/pkg/provision"
+	"laravel-setup/pkg/utils"
+)
+
+// eventFlags holds the flags that select where run events are sent
+type eventFlags struct {
+	output     *string
+	eventsFile *string
+	webhookURL *string
+}
+
+// addEventFlags registers the --output, --events-file and --webhook-url flags
+func addEventFlags(fs *flag.FlagSet) eventFlags {
+	return eventFlags{
+		output:     fs.String("output", "text", "Output format: text, or json to print run events as JSON lines on stdout"),
+		eventsFile: fs.String("events-file", "", "Append run events as JSON lines to this file"),
+		webhookURL: fs.String("webhook-url", "", "POST every run event as JSON to this URL"),
+	}
+}
+
+// jsonOutput reports whether events should be printed to stdout as JSON
+func (f eventFlags) jsonOutput() bool {
+	return *f.output == "json"
+}
+
+// observer builds the observer for the selected sinks
+// In JSON mode human-readable output is moved to stderr so stdout only carries events
+// The returned function closes any files opened for the sinks
+func (f eventFlags) observer() (provision.Observer, func(), error) {
+	var observers provision.MultiObserver
+	closeFn := func() {}
+
+	switch *f.output {
+	case "text":
+	case "json":
+		utils.SetLogger(&utils.ConsoleLogger{Out: os.Stderr})
+		utils.SetExecutor(&utils.LocalExecutor{Stdout: os.Stderr})
+		prompter := utils.NewStdinPrompter(os.Stdin)
+		prompter.Out = os.Stderr
+		utils.SetPrompter(prompter)
+		observers = append(observers, provision.NewJSONObserver(os.Stdout))
+	default:
+		return nil, closeFn, fmt.Errorf("unknown output format %q, expected text or json", *f.output)
+	}
+
+	if *f.eventsFile != "" {
+		fileObserver, err := provision.NewFileObserver(*f.eventsFile)
+		if err != nil {
+			return nil, closeFn, fmt.Errorf("failed to open events file: %w", err)
+		}
+		closeFn = func() { _ = fileObserver.Close() }
+		observers = append(observers, fileObserver)
+	}
+
+	if *f.webhookURL != "" {
+		observers = append(observers, provision.NewWebhookObserver(*f.webhookURL))
+	}
+
+	if len(observers) == 0 {
+		return nil, closeFn, nil
+	}
+	return observers, closeFn, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"laravel-setup/pkg/lock"
+	"laravel-setup/pkg/utils"
+)
+
+// lockFlags holds the flags that control the run lock
+type lockFlags struct {
+	wait *bool
+}
+
+// addLockFlags registers the --wait flag for subcommands that change the server
+func addLockFlags(fs *flag.FlagSet) lockFlags {
+	return lockFlags{
+		wait: fs.Bool("wait", false, "Wait for another run holding "+lock.DefaultPath+" to finish instead of failing"),
+	}
+}
+
+// acquire takes the run lock for a mutating subcommand
+// Returns a function that releases the lock
+func (f lockFlags) acquire(ctx context.Context, command string) (func(), error) {
+	l, err := lock.Acquire(ctx, lock.DefaultPath, command, *f.wait)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := l.Release(); err != nil {
+			utils.PrintWarning("Failed to release " + lock.DefaultPath + ": " + err.Error())
+		}
+	}, nil
+}
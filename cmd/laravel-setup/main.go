@@ -1,172 +1,178 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"laravel-setup/pkg/config"
-	"laravel-setup/pkg/laravel"
-	"laravel-setup/pkg/mysql"
-	"laravel-setup/pkg/nginx"
-	"laravel-setup/pkg/php"
-	"laravel-setup/pkg/security"
-	"laravel-setup/pkg/services"
-	"laravel-setup/pkg/system"
 	"laravel-setup/pkg/utils"
 )
 
-// runConfigStep runs a function that takes a config parameter and checks its exit status
-func runConfigStep(name string, fn func(*config.Config) error, cfg *config.Config) {
-	utils.PrintHeader("Running " + name)
-
-	err := fn(cfg)
-	if err != nil {
-		utils.PrintError("Step " + name + " failed: " + err.Error())
-		os.Exit(1)
-	}
+// command is a laravel-setup subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
 }
 
-// getSkipStatus returns a string indicating whether a step will be skipped
-func getSkipStatus(skip bool) string {
-	if skip {
-		return " (will be skipped)"
-	}
-	return ""
+// commands lists every subcommand in the order shown by the help message
+var commands = []command{
+	{"setup", "Set up a Laravel production server (default)", runSetup},
+	{"plan", "Show the setup steps that would run, without changing anything", runPlan},
+	{"status", "Show setup progress, service status and the deployed revision", runStatus},
+	{"deploy", "Pull the latest code and rebuild the application", runDeploy},
+	{"rollback", "Return the application to the previously deployed revision", runRollback},
+	{"cleanup", "Remove temporary files left behind by the setup", runCleanup},
+	{"php", "List or switch the PHP version serving each site", runPHP},
+	{"backup", "Take, list or restore database backups", runBackup},
+	{"db", "Rotate database account passwords", runDB},
+	{"secrets", "List or read the secrets in the encrypted vault", runSecrets},
+	{"doctor", "Check that the host and configuration are ready for setup", runDoctor},
+	{"config", "Show the effective configuration", runConfig},
+	{"version", "Print the version", runVersion},
 }
 
 func main() {
-	// Module selection flags
-	skipSystemUpdateFlag := flag.Bool("skip-system-update", false, "Skip system update step")
-	skipEssentialsFlag := flag.Bool("skip-essentials", false, "Skip installing essential packages")
-	skipPHPFlag := flag.Bool("skip-php", false, "Skip PHP installation")
-	skipMySQLFlag := flag.Bool("skip-mysql", false, "Skip MySQL installation")
-	skipNginxFlag := flag.Bool("skip-nginx", false, "Skip Nginx installation")
-	skipSecurityFlag := flag.Bool("skip-security", false, "Skip security configuration")
-	skipLaravelFlag := flag.Bool("skip-laravel", false, "Skip Laravel setup")
-	skipServicesFlag := flag.Bool("skip-services", false, "Skip services configuration")
-	configPathFlag := flag.String("config-path", "", "Path to the configuration file (default: ~/config.toml)")
-
-	flag.Parse()
-
-	// Print a welcome message
-	utils.PrintHeader("Laravel Production Server Setup")
-
-	// Check if running as root and if the user has sudo privileges
-	// These are security checks to ensure the script is run correctly
-	if !utils.CheckNotRoot() {
-		utils.PrintError("This script should not be run as root for security reasons")
-		os.Exit(1)
+	args := os.Args[1:]
+
+	// Running without a subcommand, or with only flags, keeps the original behavior of running the setup
+	name := "setup"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		printUsage()
+		return
 	}
 
-	if !utils.CheckSudoPrivileges() {
-		utils.PrintError("This user doesn't have sudo privileges")
-		utils.PrintWarning("Please add this user to the sudo group: sudo usermod -aG sudo " + os.Getenv("USER"))
-		os.Exit(1)
+	if name == "help" {
+		printUsage()
+		return
 	}
 
-	// Initialize configuration
-	cfg, err := config.InitConfig(*configPathFlag)
-	if err != nil {
-		utils.PrintError("Failed to initialize configuration: " + err.Error())
-		os.Exit(1)
+	cmd, ok := findCommand(name)
+	if !ok {
+		utils.PrintError("Unknown command: " + name)
+		printUsage()
+		os.Exit(2)
 	}
 
-	utils.PrintStatus("Setting up server for domain: " + cfg.Domain)
-	utils.PrintStatus("Running as user: " + os.Getenv("USER"))
-
-	// Main setup process
-	utils.PrintHeader("Starting Laravel Server Setup Process")
-	utils.PrintStatus("This script will set up a complete Laravel production server")
-	utils.PrintStatus("The setup process is divided into several steps:")
-	utils.PrintStatus("1. System update" + getSkipStatus(*skipSystemUpdateFlag))
-	utils.PrintStatus("2. Installing essential packages" + getSkipStatus(*skipEssentialsFlag))
-	utils.PrintStatus("3. Installing PHP 8.4 and extensions" + getSkipStatus(*skipPHPFlag))
-	utils.PrintStatus("4. Installing and configuring MySQL" + getSkipStatus(*skipMySQLFlag))
-	utils.PrintStatus("5. Installing and configuring Nginx" + getSkipStatus(*skipNginxFlag))
-	utils.PrintStatus("6. Configuring security (firewall, fail2ban, SSH)" + getSkipStatus(*skipSecurityFlag))
-	utils.PrintStatus("7. Setting up Laravel application" + getSkipStatus(*skipLaravelFlag))
-	utils.PrintStatus("8. Configuring and starting services" + getSkipStatus(*skipServicesFlag))
-	utils.PrintStatus("")
-
-	fmt.Print("Press Enter to begin the setup process...")
-	reader := bufio.NewReader(os.Stdin)
-	_, err = reader.ReadString('\n')
-	if err != nil {
-		return
-	}
+	ctx, stop := signalContext()
+	defer stop()
 
-	// Apply skip flags from config file if not overridden by command-line flags
-	skipSystemUpdate := *skipSystemUpdateFlag || cfg.SkipSystemUpdate
-	skipEssentials := *skipEssentialsFlag || cfg.SkipEssentials
-	skipPHP := *skipPHPFlag || cfg.SkipPHP
-	skipMySQL := *skipMySQLFlag || cfg.SkipMySQL
-	skipNginx := *skipNginxFlag || cfg.SkipNginx
-	skipSecurity := *skipSecurityFlag || cfg.SkipSecurity
-	skipLaravel := *skipLaravelFlag || cfg.SkipLaravel
-	skipServices := *skipServicesFlag || cfg.SkipServices
-
-	// Run each step of the setup process, skipping those that the user has opted to skip
-	if !skipSystemUpdate {
-		runConfigStep("System Update", system.Update, nil)
-	} else {
-		utils.PrintStatus("Skipping System Update step as requested")
+	if err := cmd.run(ctx, args); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		utils.PrintError(err.Error())
+		stop()
+		os.Exit(1)
 	}
+}
 
-	if !skipEssentials {
-		runConfigStep("Install Essentials", system.InstallEssentials, nil)
-	} else {
-		utils.PrintStatus("Skipping Install Essentials step as requested")
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM
+// The running command is then stopped cleanly; a second signal terminates the process immediately
+func signalContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			utils.PrintWarning("Received " + sig.String() + ", stopping the current command (repeat to exit immediately)")
+			signal.Reset(os.Interrupt, syscall.SIGTERM)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
 	}
+}
 
-	if !skipPHP {
-		runConfigStep("Install PHP", php.Install, nil)
-	} else {
-		utils.PrintStatus("Skipping PHP Installation step as requested")
+// findCommand looks up a subcommand by name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
 	}
+	return command{}, false
+}
 
-	if !skipMySQL {
-		runConfigStep("Install MySQL", mysql.Install, cfg)
-	} else {
-		utils.PrintStatus("Skipping MySQL Installation step as requested")
+// printUsage prints the list of available subcommands
+func printUsage() {
+	fmt.Println("Laravel Setup - A tool to set up Laravel production servers")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  laravel-setup <command> [flags] [arguments]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-10s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Println()
+	fmt.Println("Run 'laravel-setup <command> -h' for the flags of a command.")
+	fmt.Println("Running without a command is the same as 'laravel-setup setup'.")
+}
 
-	if !skipNginx {
-		runConfigStep("Install Nginx", nginx.Install, cfg)
-	} else {
-		utils.PrintStatus("Skipping Nginx Installation step as requested")
+// newFlagSet creates the flag set for a subcommand with its own help text
+func newFlagSet(name, usage, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		_, _ = fmt.Fprintf(out, "Usage: laravel-setup %s\n\n%s\n", usage, description)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			_, _ = fmt.Fprintln(out, "\nFlags:")
+			fs.PrintDefaults()
+		}
 	}
+	return fs
+}
 
-	if !skipSecurity {
-		runConfigStep("Configure Security", security.Configure, cfg)
-	} else {
-		utils.PrintStatus("Skipping Security Configuration step as requested")
+// parseFlags parses the flags of a subcommand
+// The flag package stops at the first positional argument, so a flag placed after one would be
+// silently ignored; such arguments are rejected instead. Arguments after "--" are taken as is
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
+	rest := fs.Args()
+	if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+		return nil
+	}
+	for _, arg := range rest {
+		if len(arg) > 1 && strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("flag %s must come before the arguments of %s", arg, fs.Name())
+		}
+	}
+	return nil
+}
 
-	if !skipLaravel {
-		runConfigStep("Setup Laravel", laravel.Setup, cfg)
-	} else {
-		utils.PrintStatus("Skipping Laravel Setup step as requested")
+// addConfigPathFlag registers the -config-path flag shared by most subcommands
+func addConfigPathFlag(fs *flag.FlagSet) *string {
+	return fs.String("config-path", "", "Path to the configuration file (default: ~/config.toml)")
+}
+
+// loadConfig loads the configuration without prompting and checks that the application settings are present
+func loadConfig(configPath string) (*config.Config, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize configuration: %w", err)
 	}
 
-	if !skipServices {
-		runConfigStep("Configure Services", services.Configure, cfg)
-	} else {
-		utils.PrintStatus("Skipping Services Configuration step as requested")
+	if cfg.Domain == "" {
+		return nil, fmt.Errorf("domain is not set; add Domain to the configuration file")
 	}
 
-	// Final message
-	utils.PrintHeader("Setup Complete!")
-	utils.PrintStatus("Laravel production server has been successfully set up")
-	utils.PrintStatus("Server information has been saved to: /home/" + os.Getenv("USER") + "/server_info.txt")
-	utils.PrintStatus("MySQL credentials have been saved to: /home/" + os.Getenv("USER") + "/mysql_credentials.txt")
-	utils.PrintWarning("Remember to:")
-	utils.PrintWarning("1. Point your domain DNS to this server")
-	utils.PrintWarning("2. Set up SSL certificate if you haven't already")
-	utils.PrintWarning("3. Change SSH port in your SSH client to: " + cfg.SSHPort)
-	utils.PrintStatus("")
-	utils.PrintStatus("You can clean up temporary files by running: laravel-setup -cleanup")
-
-	fmt.Printf("%sYour Laravel production server is ready!%s\n", utils.ColorGreen, utils.ColorReset)
+	return cfg, nil
 }
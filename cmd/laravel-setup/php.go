@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/php"
+	"laravel-setup/pkg/utils"
+)
+
+// runPHP manages the PHP versions serving each site
+func runPHP(ctx context.Context, args []string) error {
+	fs := newFlagSet("php", "php [flags] list | switch <site> <version>",
+		"Manage the PHP versions serving each site.\n\n"+
+			"  list                      Show the PHP version serving each site\n"+
+			"  switch <site> <version>   Serve a site with another installed PHP version,\n"+
+			"                            reloading only the affected PHP-FPM services")
+	configPath := addConfigPathFlag(fs)
+	locking := addLockFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	switch action := fs.Arg(0); action {
+	case "", "list":
+		return listPHPVersions(ctx, cfg)
+	case "switch":
+		if fs.NArg() != 3 {
+			fs.Usage()
+			return fmt.Errorf("php switch needs a site and a version")
+		}
+
+		release, err := locking.acquire(ctx, "php switch")
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return php.Switch(ctx, cfg, fs.Arg(1), config.PHPVersion(fs.Arg(2)))
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown php action: %s", action)
+	}
+}
+
+// listPHPVersions prints the PHP version serving each site
+func listPHPVersions(ctx context.Context, cfg *config.Config) error {
+	utils.PrintHeader("PHP Versions")
+	for _, site := range cfg.AllSites() {
+		current, err := php.SiteVersion(ctx, site.Domain)
+		if err != nil {
+			fmt.Printf("  %-30s configured %s, not set up\n", site.Domain, site.PHPVersion)
+			continue
+		}
+		fmt.Printf("  %-30s %s (configured %s)\n", site.Domain, current, site.PHPVersion)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"laravel-setup/pkg/utils"
+	"laravel-setup/pkg/vault"
+)
+
+// runSecrets lists and reads the secrets in the encrypted vault
+func runSecrets(ctx context.Context, args []string) error {
+	fs := newFlagSet("secrets", "secrets [flags] list | get <name>",
+		"List and read the secrets in the encrypted vault, such as the database\n"+
+			"passwords and the APP_KEY.\n\n"+
+			"  list         Show the names of the secrets\n"+
+			"  get <name>   Print a secret, e.g. get database/laravel")
+	configPath := addConfigPathFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	// Messages and the passphrase prompt go to stderr, so stdout only carries what was asked for
+	utils.SetLogger(&utils.ConsoleLogger{Out: os.Stderr})
+	prompter := utils.NewStdinPrompter(os.Stdin)
+	prompter.Out = os.Stderr
+	utils.SetPrompter(prompter)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	switch action := fs.Arg(0); action {
+	case "", "list":
+		v, err := vault.Open(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		utils.PrintHeader("Secrets in " + v.Path())
+		names := v.Names()
+		if len(names) == 0 {
+			utils.PrintStatus("The vault is empty; the setup fills it")
+			return nil
+		}
+		for _, name := range names {
+			fmt.Println("  " + name)
+		}
+		return nil
+	case "get":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return fmt.Errorf("secrets get needs a name, see 'laravel-setup secrets list'")
+		}
+
+		v, err := vault.Open(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		value, ok := v.Get(fs.Arg(1))
+		if !ok {
+			return fmt.Errorf("no secret named %s in %s", fs.Arg(1), v.Path())
+		}
+		// Only the value goes to stdout, so it can be used in scripts
+		fmt.Println(value)
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown secrets action: %s", action)
+	}
+}
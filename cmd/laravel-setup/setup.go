@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/provision"
+	"laravel-setup/pkg/state"
+	"laravel-setup/pkg/utils"
+)
+
+// stepFlags holds the skip flags registered for each step, keyed by step ID
+type stepFlags map[string]*bool
+
+// addStepFlags registers a --skip-<id> flag for every setup step
+func addStepFlags(fs *flag.FlagSet) stepFlags {
+	flags := stepFlags{}
+	for _, s := range provision.Steps() {
+		flags[s.ID] = fs.Bool("skip-"+s.ID, false, "Skip the "+s.Name+" step")
+	}
+	return flags
+}
+
+// skip returns the IDs of the steps skipped on the command line
+func (f stepFlags) skip() map[string]bool {
+	skip := map[string]bool{}
+	for id, set := range f {
+		if *set {
+			skip[id] = true
+		}
+	}
+	return skip
+}
+
+// addSkipMigrateFlag registers --skip-migrate, which overrides SkipMigrate in the [Import] table
+func addSkipMigrateFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("skip-migrate", false, "Don't run the database migrations in the Setup Laravel step")
+}
+
+// runSetup runs the complete server setup
+func runSetup(ctx context.Context, args []string) error {
+	fs := newFlagSet("setup", "setup [flags]",
+		"Set up a complete Laravel production server: system packages, PHP, the database,\n"+
+			"Nginx, security hardening, the Laravel application and its services.")
+	flags := addStepFlags(fs)
+	skipMigrate := addSkipMigrateFlag(fs)
+	configPath := addConfigPathFlag(fs)
+	events := addEventFlags(fs)
+	locking := addLockFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	release, err := locking.acquire(ctx, "setup")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	observer, closeEvents, err := events.observer()
+	if err != nil {
+		return err
+	}
+	defer closeEvents()
+
+	// Print a welcome message
+	utils.PrintHeader("Laravel Production Server Setup")
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize configuration: %w", err)
+	}
+	if *skipMigrate {
+		cfg.Import.SkipMigrate = true
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	err = provision.New(provision.Options{
+		Observer:      observer,
+		State:         st,
+		Skip:          flags.skip(),
+		Confirm:       true,
+		OfferRollback: true,
+	}).Run(ctx, cfg)
+	switch {
+	case errors.Is(err, provision.ErrAborted):
+		return nil
+	case errors.Is(err, provision.ErrNoSudo):
+		utils.PrintWarning("Please add this user to the sudo group: sudo usermod -aG sudo " + os.Getenv("USER"))
+		return err
+	case err != nil:
+		return err
+	}
+
+	// Final message
+	utils.PrintHeader("Setup Complete!")
+	utils.PrintStatus("Laravel production server has been successfully set up")
+	utils.PrintStatus("Server information has been saved to: /home/" + os.Getenv("USER") + "/server_info.txt")
+	utils.PrintStatus("Passwords are kept in the encrypted vault, see: laravel-setup secrets list")
+	utils.PrintWarning("Remember to:")
+	utils.PrintWarning("1. Point your domain DNS to this server")
+	utils.PrintWarning("2. Set up SSL certificate if you haven't already")
+	utils.PrintWarning("3. Change SSH port in your SSH client to: " + cfg.SSHPort)
+	utils.PrintStatus("")
+	utils.PrintStatus("You can clean up temporary files by running: laravel-setup cleanup")
+
+	out := os.Stdout
+	if events.jsonOutput() {
+		out = os.Stderr
+	}
+	_, _ = fmt.Fprintf(out, "%sYour Laravel production server is ready!%s\n", utils.ColorGreen, utils.ColorReset)
+	return nil
+}
+
+// runPlan prints the setup steps that would run with the given flags and configuration
+func runPlan(ctx context.Context, args []string) error {
+	fs := newFlagSet("plan", "plan [flags]",
+		"Show which setup steps would run and which would be skipped, without\n"+
+			"changing anything on the server. Accepts the same flags as setup.")
+	flags := addStepFlags(fs)
+	skipMigrate := addSkipMigrateFlag(fs)
+	configPath := addConfigPathFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize configuration: %w", err)
+	}
+	if *skipMigrate {
+		cfg.Import.SkipMigrate = true
+	}
+
+	utils.PrintHeader("Laravel Server Setup Plan")
+	if cfg.Domain != "" {
+		utils.PrintStatus("Domain: " + cfg.Domain)
+		utils.PrintStatus("Web root: " + cfg.WebRoot)
+	} else {
+		utils.PrintWarning("Domain is not set and will be prompted for")
+	}
+	provision.PrintPlan(cfg, flags.skip())
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/php"
+	"laravel-setup/pkg/provision"
+	"laravel-setup/pkg/state"
+	"laravel-setup/pkg/utils"
+)
+
+// runStatus prints setup progress, service status and the deployed revision
+func runStatus(ctx context.Context, args []string) error {
+	fs := newFlagSet("status", "status [flags] [php]",
+		"Show which setup steps have completed, whether the services are running\n"+
+			"and which revision of the application is deployed.\n\n"+
+			"  php   Show the PHP-FPM pool of each site: ping, active and idle processes,\n"+
+			"        the listen queue, max children reached and slow requests")
+	configPath := addConfigPathFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "":
+	case "php":
+		return statusPHP(ctx, cfg)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown status section: %s", fs.Arg(0))
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	utils.PrintHeader("Setup Steps")
+	for _, s := range provision.Steps() {
+		stepState, ok := st.Steps[s.Name]
+		switch {
+		case !ok:
+			utils.PrintWarning(s.Name + ": not run")
+		case stepState.Status == state.StatusFailed || stepState.Status == state.StatusInterrupted:
+			utils.PrintError(s.Name + ": " + stepState.Status + " at " + stepState.UpdatedAt.Format("2006-01-02 15:04") + ": " + stepState.Error)
+		default:
+			utils.PrintStatus(s.Name + ": " + stepState.Status + " at " + stepState.UpdatedAt.Format("2006-01-02 15:04"))
+		}
+	}
+
+	utils.PrintHeader("Services")
+	for _, service := range cfg.Services() {
+		// is-active exits non-zero for inactive services, so only the output matters here
+		active, _ := utils.RunCommandWithOutput(ctx, "systemctl", "is-active", service)
+		if active == "active" {
+			utils.PrintStatus(service + ": active")
+		} else {
+			utils.PrintWarning(service + ": " + active)
+		}
+	}
+
+	utils.PrintHeader("Application")
+	utils.PrintStatus("Domain: " + cfg.Domain)
+	utils.PrintStatus("Web root: " + cfg.WebRoot)
+	revision, err := utils.RunCommandWithOutput(ctx, "git", "-C", cfg.WebRoot, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		utils.PrintWarning("Deployed revision: unknown")
+	} else {
+		utils.PrintStatus("Deployed revision: " + revision)
+	}
+	if release, ok := st.CurrentRelease(); ok {
+		utils.PrintStatus("Last deployed at: " + release.DeployedAt.Format("2006-01-02 15:04"))
+	}
+
+	return nil
+}
+
+// statusPHP prints the health and saturation of every site's PHP-FPM pool
+func statusPHP(ctx context.Context, cfg *config.Config) error {
+	failed := 0
+	for _, site := range cfg.AllSites() {
+		utils.PrintHeader("PHP-FPM Pool " + site.Domain)
+
+		if err := php.Ping(ctx, site); err != nil {
+			utils.PrintError("Ping: " + err.Error())
+			failed++
+			continue
+		}
+		utils.PrintStatus("Ping: pong")
+
+		status, err := php.Status(ctx, site)
+		if err != nil {
+			utils.PrintError("Status: " + err.Error())
+			failed++
+			continue
+		}
+
+		utils.PrintStatus(fmt.Sprintf("Process manager: %s, up %s, %d accepted connections",
+			status.ProcessManager, time.Duration(status.StartSince)*time.Second, status.AcceptedConn))
+		utils.PrintStatus(fmt.Sprintf("Processes: %d active, %d idle, %d total (max active %d)",
+			status.ActiveProcesses, status.IdleProcesses, status.TotalProcesses, status.MaxActiveProcesses))
+
+		report := utils.PrintStatus
+		if status.Saturated() {
+			report = utils.PrintWarning
+		}
+		report(fmt.Sprintf("Listen queue: %d (max %d, length %d)", status.ListenQueue, status.MaxListenQueue, status.ListenQueueLen))
+		report(fmt.Sprintf("Max children reached: %d", status.MaxChildrenReached))
+
+		if status.SlowRequests > 0 {
+			utils.PrintWarning(fmt.Sprintf("Slow requests: %d, see %s", status.SlowRequests, site.FPMSlowlog()))
+		} else {
+			utils.PrintStatus("Slow requests: 0")
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d PHP-FPM pools didn't respond", failed)
+	}
+	return nil
+}
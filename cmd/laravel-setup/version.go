@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// version is the release version, set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// runVersion prints the version
+func runVersion(_ context.Context, args []string) error {
+	fs := newFlagSet("version", "version", "Print the version of laravel-setup.")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	fmt.Printf("laravel-setup %s (%s, %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
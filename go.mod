@@ -2,4 +2,4 @@ module laravel-setup
 
 go 1.24
 
-require github.com/BurntSushi/toml v1.5.0 // indirect
+require github.com/BurntSushi/toml v1.5.0
@@ -0,0 +1,328 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/database"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// Systemd units running the scheduled backups
+const (
+	serviceFile = "/etc/systemd/system/laravel-setup-backup.service"
+	timerFile   = "/etc/systemd/system/laravel-setup-backup.timer"
+	timerUnit   = "laravel-setup-backup.timer"
+)
+
+// dumpScript pipes a dump command through gzip into a file that only root can read
+// The dump is written under a temporary name, so an interrupted dump never looks complete
+const dumpScript = `set -o pipefail
+out=$1; shift
+umask 077
+if "$@" | gzip -c > "$out.partial"; then
+	mv "$out.partial" "$out"
+else
+	rm -f "$out.partial"
+	exit 1
+fi`
+
+// restoreScript pipes a compressed dump into a restore command
+const restoreScript = `set -o pipefail
+in=$1; shift
+gunzip -c "$in" | "$@"`
+
+// Now dumps the database, compresses it, records it in the manifest and applies the retention policy
+func Now(ctx context.Context, cfg *config.Config) (Entry, error) {
+	b := cfg.Backup.WithDefaults()
+	entry, err := take(ctx, cfg)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := prune(ctx, b.Dir, b.KeepDaily, b.KeepWeekly); err != nil {
+		return Entry{}, err
+	}
+
+	// The local backup is kept when the upload fails; the failure still fails the run, so the timer reports it
+	if b.Remote.Enabled() {
+		if err := upload(ctx, cfg, entry); err != nil {
+			return entry, err
+		}
+		if err := pruneRemote(ctx, cfg); err != nil {
+			return entry, err
+		}
+	}
+	return entry, nil
+}
+
+// take dumps the database, compresses it and records it in the manifest, without applying the retention policy
+func take(ctx context.Context, cfg *config.Config) (Entry, error) {
+	b := cfg.Backup.WithDefaults()
+	utils.PrintHeader("Backing Up Database " + cfg.DBName)
+
+	err := utils.RunCommand(ctx, "sudo", "install", "-d", "-m", "0700", b.Dir)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	created := time.Now().UTC()
+	entry := Entry{
+		ID:        created.Format("20060102-150405"),
+		Engine:    cfg.Database.EngineName(),
+		Database:  cfg.DBName,
+		CreatedAt: created,
+	}
+	entry.File = entry.Database + "-" + entry.ID + ".sql.gz"
+	path := b.Dir + "/" + entry.File
+
+	utils.PrintStatus("Dumping " + cfg.Database.DisplayName() + " database " + cfg.DBName + " to " + path + "...")
+	dump := database.DumpCommand(ctx, cfg)
+	err = utils.RunCommand(ctx, "sudo", append([]string{"bash", "-c", dumpScript, "bash", path}, dump...)...)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to dump the database: %w", err)
+	}
+
+	if entry.SHA256, err = checksum(ctx, path); err != nil {
+		return Entry{}, err
+	}
+	size, err := utils.RunCommandWithOutput(ctx, "sudo", "stat", "-c", "%s", path)
+	if err != nil {
+		return Entry{}, err
+	}
+	if entry.Size, err = strconv.ParseInt(size, 10, 64); err != nil {
+		return Entry{}, fmt.Errorf("failed to read the size of %s: %w", path, err)
+	}
+
+	if b.Remote.Enabled() {
+		if entry.Storage, err = archiveStorage(ctx, cfg, b.Dir, entry); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	manifest, err := loadManifest(ctx, b.Dir)
+	if err != nil {
+		return Entry{}, err
+	}
+	manifest.Entries = append(manifest.Entries, entry)
+	if err := manifest.save(ctx, b.Dir); err != nil {
+		return Entry{}, err
+	}
+
+	utils.PrintStatus(fmt.Sprintf("Backup %s written: %s, %d bytes", entry.ID, entry.File, entry.Size))
+	return entry, nil
+}
+
+// prune removes the local backups the retention policy doesn't keep
+func prune(ctx context.Context, dir string, daily, weekly int) error {
+	manifest, err := loadManifest(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	keep, remove := retain(manifest.Entries, daily, weekly)
+	for _, old := range remove {
+		files := []string{dir + "/" + old.File}
+		if old.Storage != "" {
+			files = append(files, dir+"/"+old.Storage)
+		}
+		if err := utils.RunCommand(ctx, "sudo", append([]string{"rm", "-f"}, files...)...); err != nil {
+			return err
+		}
+		utils.PrintStatus("Removed backup " + old.ID + " (retention)")
+	}
+	manifest.Entries = keep
+	return manifest.save(ctx, dir)
+}
+
+// archiveStorage archives the application's storage/app directory next to a dump
+// Returns an empty name when the application isn't set up yet
+func archiveStorage(ctx context.Context, cfg *config.Config, dir string, entry Entry) (string, error) {
+	storage := cfg.WebRoot + "/storage"
+	if _, err := utils.RunCommandWithOutput(ctx, "sudo", "test", "-d", storage+"/app"); err != nil {
+		utils.PrintWarning(storage + "/app not found, backing up the database only")
+		return "", nil
+	}
+
+	file := entry.Database + "-" + entry.ID + ".storage.tar.gz"
+	utils.PrintStatus("Archiving " + storage + "/app...")
+	err := utils.RunCommand(ctx, "sudo", "bash", "-c", `umask 077; tar -czf "$1" -C "$2" app`, "bash", dir+"/"+file, storage)
+	if err != nil {
+		return "", fmt.Errorf("failed to archive %s/app: %w", storage, err)
+	}
+	return file, nil
+}
+
+// List returns the backups in the manifest, oldest first
+func List(ctx context.Context, cfg *config.Config) ([]Entry, error) {
+	manifest, err := loadManifest(ctx, cfg.Backup.WithDefaults().Dir)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Entries, nil
+}
+
+// Restore replaces the database with a backup from the manifest, or from the bucket when it isn't kept locally
+// The dump's checksum is verified first, and the current database is backed up before it is replaced
+// With storage, the archived storage/app directory is extracted over the application's too
+func Restore(ctx context.Context, cfg *config.Config, id string, storage bool) error {
+	b := cfg.Backup.WithDefaults()
+
+	manifest, err := loadManifest(ctx, b.Dir)
+	if err != nil {
+		return err
+	}
+	entry, local := manifest.find(id)
+	if !local && !b.Remote.Enabled() {
+		return fmt.Errorf("backup %s not found in %s", id, b.Dir)
+	}
+	if local {
+		if err := verify(ctx, cfg, entry); err != nil {
+			return err
+		}
+	}
+
+	// Keep what is about to be overwritten, in case the wrong backup was picked
+	// Retention isn't applied here: the new backup is the newest of its day and week, so it would
+	// remove an earlier backup of the same day, possibly the one being restored
+	// The next scheduled backup applies it
+	current, err := take(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to back up the current database before restoring: %w", err)
+	}
+
+	// The backup above changed the manifest
+	if !local {
+		if manifest, err = loadManifest(ctx, b.Dir); err != nil {
+			return err
+		}
+		if entry, err = pull(ctx, cfg, manifest, id); err != nil {
+			return err
+		}
+		if err := verify(ctx, cfg, entry); err != nil {
+			return err
+		}
+	}
+
+	path := b.Dir + "/" + entry.File
+	if _, err := utils.RunCommandWithOutput(ctx, "sudo", "test", "-f", path); err != nil {
+		return fmt.Errorf("backup %s is gone: %s doesn't exist; the database is unchanged and also saved as backup %s", id, path, current.ID)
+	}
+	utils.PrintHeader("Restoring Backup " + id)
+	utils.PrintStatus("Loading " + path + " into " + cfg.DBName + "...")
+	restore := database.RestoreCommand(ctx, cfg)
+	err = utils.RunCommand(ctx, "sudo", append([]string{"bash", "-c", restoreScript, "bash", path}, restore...)...)
+	if err != nil {
+		return fmt.Errorf("failed to restore backup %s; the database before the restore is backup %s: %w", id, current.ID, err)
+	}
+
+	if storage {
+		if entry.Storage == "" {
+			utils.PrintWarning("Backup " + id + " has no storage/app archive")
+		} else {
+			utils.PrintStatus("Extracting " + entry.Storage + " into " + cfg.WebRoot + "/storage/app...")
+			err := utils.RunCommand(ctx, "sudo", "tar", "-xzf", b.Dir+"/"+entry.Storage, "-C", cfg.WebRoot+"/storage")
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", entry.Storage, err)
+			}
+		}
+	}
+
+	utils.PrintStatus("Backup " + id + " restored; the database before the restore is backup " + current.ID)
+	return nil
+}
+
+// verify checks that a backup matches the database engine and the checksum in its entry
+func verify(ctx context.Context, cfg *config.Config, entry Entry) error {
+	if entry.Engine != cfg.Database.EngineName() {
+		return fmt.Errorf("backup %s is a %s dump, but the database engine is %s", entry.ID, entry.Engine, cfg.Database.EngineName())
+	}
+
+	path := cfg.Backup.WithDefaults().Dir + "/" + entry.File
+	sum, err := checksum(ctx, path)
+	if err != nil {
+		return err
+	}
+	if sum != entry.SHA256 {
+		return fmt.Errorf("backup %s is corrupt: %s has checksum %s, the manifest has %s", entry.ID, path, sum, entry.SHA256)
+	}
+	return nil
+}
+
+// Schedule installs a systemd timer that runs "backup now" on the configured schedule
+func Schedule(ctx context.Context, cfg *config.Config) error {
+	b := cfg.Backup.WithDefaults()
+	utils.PrintHeader("Scheduling Database Backups")
+
+	// The timer runs as root, so it needs the configuration file rather than the user's defaults
+	if cfg.ConfigPath == "" {
+		utils.PrintWarning("Backups aren't scheduled: they need a configuration file, pass --config-path")
+		return nil
+	}
+
+	if b.Remote.Enabled() {
+		if err := ensureRclone(ctx); err != nil {
+			return err
+		}
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	err = utils.WriteSystemFile(ctx, serviceFile, templates.GetBackupService(binary, cfg.ConfigPath), 0644)
+	if err != nil {
+		return err
+	}
+	err = utils.WriteSystemFile(ctx, timerFile, templates.GetBackupTimer(b.Schedule), 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := utils.RunCommand(ctx, "sudo", "systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	if err := utils.RunCommand(ctx, "sudo", "systemctl", "enable", "--now", timerUnit); err != nil {
+		return err
+	}
+
+	utils.PrintStatus(fmt.Sprintf("Backups of %s run on %q into %s, keeping %d daily and %d weekly",
+		cfg.DBName, b.Schedule, b.Dir, b.KeepDaily, b.KeepWeekly))
+	if b.Remote.Enabled() {
+		utils.PrintStatus(fmt.Sprintf("Backups and storage/app are uploaded to %s and kept there for %d days",
+			remotePath(b.Remote, ""), b.Remote.KeepDays))
+	}
+	return nil
+}
+
+// Unschedule removes the backup timer; existing backups are kept
+func Unschedule(ctx context.Context, _ *config.Config) error {
+	utils.PrintHeader("Removing Scheduled Database Backups")
+
+	// disable fails when the timer was never installed, which is fine here
+	_ = utils.RunCommand(ctx, "sudo", "systemctl", "disable", "--now", timerUnit)
+
+	if err := utils.RunCommand(ctx, "sudo", "rm", "-f", serviceFile, timerFile); err != nil {
+		return err
+	}
+	return utils.RunCommand(ctx, "sudo", "systemctl", "daemon-reload")
+}
+
+// checksum returns the SHA-256 of a file only root can read
+func checksum(ctx context.Context, path string) (string, error) {
+	output, err := utils.RunCommandWithOutput(ctx, "sudo", "sha256sum", path)
+	if err != nil {
+		return "", fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("failed to checksum %s", path)
+	}
+	return fields[0], nil
+}
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"laravel-setup/pkg/utils"
+)
+
+// manifestFile lists the backups in the backup directory
+const manifestFile = "manifest.json"
+
+// Entry is a database dump listed in the manifest
+type Entry struct {
+	// ID identifies the backup, e.g. "20261019-030000"; it sorts by creation time
+	ID string `json:"id"`
+	// File is the compressed dump, relative to the backup directory
+	File      string    `json:"file"`
+	Engine    string    `json:"engine"`
+	Database  string    `json:"database"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	// Storage is the archive of the application's storage/app directory, taken with off-site backups
+	Storage string `json:"storage,omitempty"`
+	// Encrypted marks an upload encrypted with the remote passphrase
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+// Manifest lists the backups kept in a backup directory, oldest first
+type Manifest struct {
+	Entries []Entry `json:"entries"`
+}
+
+// loadManifest reads the manifest of a backup directory
+// The directory is only readable by root; a directory without a manifest has no backups
+func loadManifest(ctx context.Context, dir string) (*Manifest, error) {
+	manifest := &Manifest{}
+	if _, err := utils.RunCommandWithOutput(ctx, "sudo", "test", "-f", dir+"/"+manifestFile); err != nil {
+		return manifest, nil
+	}
+
+	data, err := utils.RunCommandWithOutput(ctx, "sudo", "cat", dir+"/"+manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the backup manifest: %w", err)
+	}
+	if err := json.Unmarshal([]byte(data), manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse the backup manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// save writes the manifest, sorted by creation time
+func (m *Manifest) save(ctx context.Context, dir string) error {
+	m.sort()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteSystemFile(ctx, dir+"/"+manifestFile, string(data)+"\n", 0600)
+}
+
+// sort orders the entries by creation time
+func (m *Manifest) sort() {
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].CreatedAt.Before(m.Entries[j].CreatedAt)
+	})
+}
+
+// find returns the entry with the given ID
+func (m *Manifest) find(id string) (Entry, bool) {
+	for _, entry := range m.Entries {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
@@ -0,0 +1,246 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// rcloneRemote names the bucket in the rclone configuration passed through the environment
+const rcloneRemote = "offsite"
+
+// passphraseVar passes the encryption passphrase to openssl without putting it on a command line
+const passphraseVar = "LARAVEL_SETUP_BACKUP_PASSPHRASE"
+
+// uploadScript uploads a file, encrypting it on the way when a passphrase is set
+const uploadScript = `set -o pipefail
+src=$1; dst=$2
+if [ -n "$LARAVEL_SETUP_BACKUP_PASSPHRASE" ]; then
+	openssl enc -aes-256-cbc -salt -pbkdf2 -iter 100000 -pass env:LARAVEL_SETUP_BACKUP_PASSPHRASE -in "$src" | rclone rcat "$dst"
+else
+	rclone copyto "$src" "$dst"
+fi`
+
+// downloadScript downloads a file, decrypting it on the way when a passphrase is set
+// The file is written under a temporary name, so an interrupted download never looks complete
+const downloadScript = `set -o pipefail
+src=$1; dst=$2
+umask 077
+if [ -n "$LARAVEL_SETUP_BACKUP_PASSPHRASE" ]; then
+	rclone cat "$src" | openssl enc -d -aes-256-cbc -pbkdf2 -iter 100000 -pass env:LARAVEL_SETUP_BACKUP_PASSPHRASE > "$dst.partial"
+else
+	rclone cat "$src" > "$dst.partial"
+fi && mv "$dst.partial" "$dst" || { rm -f "$dst.partial"; exit 1; }`
+
+// rcloneScript runs rclone with the arguments
+const rcloneScript = `rclone "$@"`
+
+// remoteDir is where the entries of the uploaded backups are mirrored, inside the backup directory
+const remoteDir = "remote"
+
+// ensureRclone installs rclone, which uploads to the bucket, unless it is installed
+func ensureRclone(ctx context.Context) error {
+	if _, err := utils.RunCommandWithOutput(ctx, "which", "rclone"); err == nil {
+		return nil
+	}
+	utils.PrintStatus("Installing rclone for off-site backups...")
+	return utils.RunCommand(ctx, "sudo", "apt", "install", "-y", "rclone")
+}
+
+// rcloneEnv configures the bucket for rclone and openssl
+// rclone reads its remotes from RCLONE_CONFIG_<NAME>_<OPTION> variables, so no config file holds the keys
+func rcloneEnv(r config.BackupRemote) []string {
+	option := "RCLONE_CONFIG_" + strings.ToUpper(rcloneRemote) + "_"
+	env := []string{option + "TYPE=s3"}
+	if r.Endpoint == "" {
+		env = append(env, option+"PROVIDER=AWS")
+	} else {
+		// MinIO and most other S3-compatible services need path-style bucket URLs
+		env = append(env, option+"PROVIDER=Other", option+"ENDPOINT="+r.Endpoint, option+"FORCE_PATH_STYLE=true")
+	}
+	if r.Region != "" {
+		env = append(env, option+"REGION="+r.Region)
+	}
+	if r.AccessKeyID != "" {
+		env = append(env, option+"ACCESS_KEY_ID="+r.AccessKeyID, option+"SECRET_ACCESS_KEY="+r.SecretAccessKey)
+	} else {
+		env = append(env, option+"ENV_AUTH=true")
+	}
+	if r.Passphrase != "" {
+		env = append(env, passphraseVar+"="+r.Passphrase)
+	}
+	return env
+}
+
+// runRemote runs a script as root with the bucket configured in its environment
+// The backups can only be read by root, so the variables are kept across sudo
+func runRemote(ctx context.Context, r config.BackupRemote, script string, args ...string) error {
+	env := rcloneEnv(r)
+	names := make([]string, 0, len(env))
+	for _, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		names = append(names, name)
+	}
+
+	sudoArgs := []string{"--preserve-env=" + strings.Join(names, ","), "bash", "-c", script, "bash"}
+	return utils.RunCommandWithEnv(ctx, env, "sudo", append(sudoArgs, args...)...)
+}
+
+// remotePath returns the rclone path of an object in the bucket
+func remotePath(r config.BackupRemote, name string) string {
+	return rcloneRemote + ":" + r.Bucket + "/" + strings.Trim(r.Prefix, "/") + "/" + name
+}
+
+// remoteName returns the object name of a backup file, marking encrypted uploads
+func remoteName(r config.BackupRemote, file string) string {
+	if r.Passphrase != "" {
+		return file + ".enc"
+	}
+	return file
+}
+
+// entryName returns the object name of the entry describing an uploaded backup
+func entryName(entry Entry) string {
+	return entry.Database + "-" + entry.ID + ".json"
+}
+
+// upload copies a backup to the bucket, then its entry, so a listed backup is always complete
+func upload(ctx context.Context, cfg *config.Config, entry Entry) error {
+	b := cfg.Backup.WithDefaults()
+	r := b.Remote
+	if err := ensureRclone(ctx); err != nil {
+		return err
+	}
+	utils.PrintStatus("Uploading backup " + entry.ID + " to " + r.Bucket + "...")
+
+	files := []string{entry.File}
+	if entry.Storage != "" {
+		files = append(files, entry.Storage)
+	}
+	for _, file := range files {
+		if err := runRemote(ctx, r, uploadScript, b.Dir+"/"+file, remotePath(r, remoteName(r, file))); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", file, err)
+		}
+	}
+
+	entry.Encrypted = r.Passphrase != ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	script := `printf '%s\n' "$1" | rclone rcat "$2"`
+	if err := runRemote(ctx, r, script, string(data), remotePath(r, entryName(entry))); err != nil {
+		return fmt.Errorf("failed to upload the entry of backup %s: %w", entry.ID, err)
+	}
+
+	utils.PrintStatus("Backup " + entry.ID + " uploaded")
+	return nil
+}
+
+// pruneRemote deletes uploads older than the remote retention
+func pruneRemote(ctx context.Context, cfg *config.Config) error {
+	r := cfg.Backup.WithDefaults().Remote
+	age := strconv.Itoa(r.KeepDays) + "d"
+	err := runRemote(ctx, r, rcloneScript, "delete", "--min-age", age, remotePath(r, ""))
+	if err != nil {
+		return fmt.Errorf("failed to delete uploads older than %s from %s: %w", age, r.Bucket, err)
+	}
+	return nil
+}
+
+// ListRemote returns the backups in the bucket, oldest first
+// Their entries are mirrored into the backup directory and read from there
+func ListRemote(ctx context.Context, cfg *config.Config) ([]Entry, error) {
+	b := cfg.Backup.WithDefaults()
+	if !b.Remote.Enabled() {
+		return nil, fmt.Errorf("no bucket is configured; set Bucket in the [Backup.Remote] table")
+	}
+	if err := ensureRclone(ctx); err != nil {
+		return nil, err
+	}
+
+	mirror := b.Dir + "/" + remoteDir
+	err := utils.RunCommand(ctx, "sudo", "install", "-d", "-m", "0700", b.Dir, mirror)
+	if err != nil {
+		return nil, err
+	}
+	err = runRemote(ctx, b.Remote, rcloneScript, "sync", "--include", "*.json", remotePath(b.Remote, ""), mirror)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the backups in %s: %w", b.Remote.Bucket, err)
+	}
+
+	output, err := utils.RunCommandWithOutput(ctx, "sudo", "find", mirror, "-maxdepth", "1", "-name", "*.json", "-exec", "cat", "{}", "+")
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	decoder := json.NewDecoder(strings.NewReader(output))
+	for {
+		var entry Entry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse the backups in %s: %w", b.Remote.Bucket, err)
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+	manifest.sort()
+	return manifest.Entries, nil
+}
+
+// pull downloads a backup from the bucket into the backup directory and adds it to the manifest
+func pull(ctx context.Context, cfg *config.Config, manifest *Manifest, id string) (Entry, error) {
+	b := cfg.Backup.WithDefaults()
+	r := b.Remote
+
+	remote, err := ListRemote(ctx, cfg)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry, ok := (&Manifest{Entries: remote}).find(id)
+	if !ok {
+		return Entry{}, fmt.Errorf("backup %s not found in %s or in %s", id, b.Dir, r.Bucket)
+	}
+	r, err = downloadRemote(r, entry)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	utils.PrintStatus("Downloading backup " + id + " from " + r.Bucket + "...")
+	files := []string{entry.File}
+	if entry.Storage != "" {
+		files = append(files, entry.Storage)
+	}
+	for _, file := range files {
+		if err := runRemote(ctx, r, downloadScript, remotePath(r, remoteName(r, file)), b.Dir+"/"+file); err != nil {
+			return Entry{}, fmt.Errorf("failed to download %s: %w", file, err)
+		}
+	}
+
+	entry.Encrypted = false
+	manifest.Entries = append(manifest.Entries, entry)
+	if err := manifest.save(ctx, b.Dir); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// downloadRemote returns the bucket settings an uploaded backup is downloaded with
+// A plain upload must be downloaded as it is, whatever the current passphrase
+func downloadRemote(r config.BackupRemote, entry Entry) (config.BackupRemote, error) {
+	if !entry.Encrypted {
+		r.Passphrase = ""
+		return r, nil
+	}
+	if r.Passphrase == "" {
+		return r, fmt.Errorf("backup %s is encrypted; set Passphrase in the [Backup.Remote] table", entry.ID)
+	}
+	return r, nil
+}
@@ -0,0 +1,112 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// The bucket TestRemoteRoundTrip uploads to, e.g. a local MinIO:
+//
+//	docker run -d -p 9000:9000 -e MINIO_ROOT_USER=minio -e MINIO_ROOT_PASSWORD=minio-secret minio/minio server /data
+//	LARAVEL_SETUP_TEST_S3_ENDPOINT=http://127.0.0.1:9000 LARAVEL_SETUP_TEST_S3_BUCKET=backups \
+//	LARAVEL_SETUP_TEST_S3_ACCESS_KEY=minio LARAVEL_SETUP_TEST_S3_SECRET_KEY=minio-secret go test ./pkg/backup/
+//
+// The bucket must exist; the test needs rclone, openssl and sudo, and deletes what it uploads
+const (
+	testEndpointVar  = "LARAVEL_SETUP_TEST_S3_ENDPOINT"
+	testBucketVar    = "LARAVEL_SETUP_TEST_S3_BUCKET"
+	testAccessKeyVar = "LARAVEL_SETUP_TEST_S3_ACCESS_KEY"
+	testSecretKeyVar = "LARAVEL_SETUP_TEST_S3_SECRET_KEY"
+)
+
+func TestRemoteRoundTrip(t *testing.T) {
+	endpoint, bucket := os.Getenv(testEndpointVar), os.Getenv(testBucketVar)
+	if endpoint == "" || bucket == "" {
+		t.Skip("set " + testEndpointVar + " and " + testBucketVar + " to test against an S3-compatible bucket")
+	}
+	for _, tool := range []string{"rclone", "openssl", "sudo"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skip(tool + " is not installed")
+		}
+	}
+
+	for _, passphrase := range []string{"", "correct horse battery staple"} {
+		name := "plain"
+		if passphrase != "" {
+			name = "encrypted"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			defer cancel()
+
+			cfg := &config.Config{Backup: config.Backup{
+				Dir: t.TempDir(),
+				Remote: config.BackupRemote{
+					Endpoint:        endpoint,
+					Bucket:          bucket,
+					Prefix:          fmt.Sprintf("laravel-setup-test/%s-%d", name, time.Now().UnixNano()),
+					AccessKeyID:     os.Getenv(testAccessKeyVar),
+					SecretAccessKey: os.Getenv(testSecretKeyVar),
+					Passphrase:      passphrase,
+				},
+			}}
+			r := cfg.Backup.Remote
+			t.Cleanup(func() {
+				_ = runRemote(context.Background(), r, rcloneScript, "purge", remotePath(r, ""))
+			})
+
+			content := randomContent(t)
+			entry := Entry{ID: "20261019-030000", File: "laravel-20261019-030000.sql.gz", Database: "laravel", CreatedAt: time.Now()}
+			path := cfg.Backup.Dir + "/" + entry.File
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := upload(ctx, cfg, entry); err != nil {
+				t.Fatalf("upload() = %v", err)
+			}
+
+			// What left the host is the ciphertext when a passphrase is set
+			same := runRemote(ctx, r, `rclone cat "$1" | cmp -s - "$2"`, remotePath(r, remoteName(r, entry.File)), path) == nil
+			if same != (passphrase == "") {
+				t.Errorf("uploaded object equals the backup = %v, want %v", same, passphrase == "")
+			}
+
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+			pulled, err := pull(ctx, cfg, &Manifest{}, entry.ID)
+			if err != nil {
+				t.Fatalf("pull() = %v", err)
+			}
+			if pulled.Encrypted {
+				t.Error("pulled entry is marked encrypted, want the local copy marked plain")
+			}
+			got, err := utils.RunCommandWithOutput(ctx, "sudo", "cat", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != content {
+				t.Errorf("downloaded backup differs from the uploaded one")
+			}
+		})
+	}
+}
+
+// randomContent returns random text standing in for a dump
+func randomContent(t *testing.T) string {
+	data := make([]byte, 64*1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(data)
+}
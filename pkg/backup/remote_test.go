@@ -0,0 +1,126 @@
+package backup
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"laravel-setup/pkg/config"
+)
+
+func TestRcloneEnv(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote config.BackupRemote
+		want   []string
+	}{
+		{
+			name:   "aws with keys",
+			remote: config.BackupRemote{Region: "eu-central-1", AccessKeyID: "AKIA", SecretAccessKey: "secret"},
+			want: []string{
+				"RCLONE_CONFIG_OFFSITE_TYPE=s3",
+				"RCLONE_CONFIG_OFFSITE_PROVIDER=AWS",
+				"RCLONE_CONFIG_OFFSITE_REGION=eu-central-1",
+				"RCLONE_CONFIG_OFFSITE_ACCESS_KEY_ID=AKIA",
+				"RCLONE_CONFIG_OFFSITE_SECRET_ACCESS_KEY=secret",
+			},
+		},
+		{
+			name:   "aws with the instance role",
+			remote: config.BackupRemote{},
+			want: []string{
+				"RCLONE_CONFIG_OFFSITE_TYPE=s3",
+				"RCLONE_CONFIG_OFFSITE_PROVIDER=AWS",
+				"RCLONE_CONFIG_OFFSITE_ENV_AUTH=true",
+			},
+		},
+		{
+			name: "minio with a passphrase",
+			remote: config.BackupRemote{
+				Endpoint: "http://127.0.0.1:9000", AccessKeyID: "minio", SecretAccessKey: "minio-secret", Passphrase: "p4ss phrase",
+			},
+			want: []string{
+				"RCLONE_CONFIG_OFFSITE_TYPE=s3",
+				"RCLONE_CONFIG_OFFSITE_PROVIDER=Other",
+				"RCLONE_CONFIG_OFFSITE_ENDPOINT=http://127.0.0.1:9000",
+				"RCLONE_CONFIG_OFFSITE_FORCE_PATH_STYLE=true",
+				"RCLONE_CONFIG_OFFSITE_ACCESS_KEY_ID=minio",
+				"RCLONE_CONFIG_OFFSITE_SECRET_ACCESS_KEY=minio-secret",
+				"LARAVEL_SETUP_BACKUP_PASSPHRASE=p4ss phrase",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rcloneEnv(tt.remote); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rcloneEnv() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemotePath(t *testing.T) {
+	tests := []struct {
+		prefix string
+		name   string
+		want   string
+	}{
+		{"laravel-setup", "laravel-20261019-030000.sql.gz", "offsite:backups/laravel-setup/laravel-20261019-030000.sql.gz"},
+		{"/hosts/web1/", "entry.json", "offsite:backups/hosts/web1/entry.json"},
+		{"laravel-setup", "", "offsite:backups/laravel-setup/"},
+	}
+	for _, tt := range tests {
+		r := config.BackupRemote{Bucket: "backups", Prefix: tt.prefix}
+		if got := remotePath(r, tt.name); got != tt.want {
+			t.Errorf("remotePath(%q, %q) = %q, want %q", tt.prefix, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteName(t *testing.T) {
+	file := "laravel-20261019-030000.sql.gz"
+	if got := remoteName(config.BackupRemote{}, file); got != file {
+		t.Errorf("remoteName() = %q for a plain upload, want %q", got, file)
+	}
+	if got := remoteName(config.BackupRemote{Passphrase: "secret"}, file); got != file+".enc" {
+		t.Errorf("remoteName() = %q for an encrypted upload, want %q", got, file+".enc")
+	}
+}
+
+func TestDownloadRemote(t *testing.T) {
+	file := "laravel-20261019-030000.sql.gz"
+	tests := []struct {
+		name       string
+		passphrase string
+		encrypted  bool
+		wantFile   string
+		wantErr    string
+	}{
+		{"plain upload without a passphrase", "", false, file, ""},
+		{"plain upload after setting a passphrase", "secret", false, file, ""},
+		{"encrypted upload", "secret", true, file + ".enc", ""},
+		{"encrypted upload without the passphrase", "", true, "", "is encrypted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := config.BackupRemote{Bucket: "backups", Passphrase: tt.passphrase}
+			got, err := downloadRemote(r, Entry{ID: "20261019-030000", File: file, Encrypted: tt.encrypted})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("downloadRemote() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("downloadRemote() = %v", err)
+			}
+			if name := remoteName(got, file); name != tt.wantFile {
+				t.Errorf("downloads %q, want %q", name, tt.wantFile)
+			}
+			decrypts := strings.Contains(strings.Join(rcloneEnv(got), "\n"), passphraseVar+"=")
+			if decrypts != tt.encrypted {
+				t.Errorf("passes the passphrase = %v, want %v", decrypts, tt.encrypted)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+)
+
+// retain splits backups into those to keep and those to remove
+// The newest backup of each of the last daily days with a backup is kept, and the newest backup
+// of each of the last weekly ISO weeks with a backup; a backup kept by either rule stays
+func retain(entries []Entry, daily, weekly int) (keep, remove []Entry) {
+	sorted := append([]Entry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	kept := map[string]bool{}
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	for _, entry := range sorted {
+		created := entry.CreatedAt.Local()
+
+		day := created.Format("2006-01-02")
+		if !days[day] && len(days) < daily {
+			days[day] = true
+			kept[entry.ID] = true
+		}
+
+		year, week := created.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%02d", year, week)
+		if !weeks[weekKey] && len(weeks) < weekly {
+			weeks[weekKey] = true
+			kept[entry.ID] = true
+		}
+	}
+
+	for _, entry := range entries {
+		if kept[entry.ID] {
+			keep = append(keep, entry)
+		} else {
+			remove = append(remove, entry)
+		}
+	}
+	return keep, remove
+}
@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetain(t *testing.T) {
+	// Monday 2026-10-19 is in ISO week 43
+	at := func(day, hour int) Entry {
+		created := time.Date(2026, 10, day, hour, 0, 0, 0, time.Local)
+		return Entry{ID: created.Format("20060102-150405"), CreatedAt: created}
+	}
+	entries := []Entry{
+		at(5, 3),  // week 41
+		at(8, 3),  // week 41, its newest
+		at(12, 3), // week 42
+		at(17, 3),
+		at(18, 3), // Sunday, week 42's newest
+		at(19, 3),
+		at(19, 9),
+		at(19, 14), // the 19th's newest
+	}
+
+	tests := []struct {
+		name          string
+		daily, weekly int
+		keep          []string
+	}{
+		{"newest of each day", 3, 0, []string{"20261017-030000", "20261018-030000", "20261019-140000"}},
+		{"newest of each week", 0, 3, []string{"20261008-030000", "20261018-030000", "20261019-140000"}},
+		{"days and weeks", 3, 3, []string{"20261008-030000", "20261017-030000", "20261018-030000", "20261019-140000"}},
+		{"nothing", 0, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, remove := retain(entries, tt.daily, tt.weekly)
+			var got []string
+			for _, entry := range keep {
+				got = append(got, entry.ID)
+			}
+			// The kept entries stay in their original order
+			if !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("retain() keeps %v, want %v", got, tt.keep)
+			}
+			if len(keep)+len(remove) != len(entries) {
+				t.Errorf("retain() keeps %d and removes %d of %d entries", len(keep), len(remove), len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// bucketName matches S3 bucket names
+var bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// Backup schedules database backups and sets how many are kept
+// Zero values pick the defaults
+type Backup struct {
+	// Dir holds the dumps and their manifest; defaults to /var/backups/laravel-setup
+	Dir string
+	// Schedule is a systemd OnCalendar expression; defaults to "*-*-* 03:00:00", every night at 3
+	Schedule string
+	// KeepDaily keeps the newest backup of each of the last KeepDaily days with a backup; defaults to 7
+	KeepDaily int
+	// KeepWeekly keeps the newest backup of each of the last KeepWeekly weeks with a backup; defaults to 4
+	KeepWeekly int
+	// Remote ships every backup off the host, to an S3-compatible bucket
+	Remote BackupRemote
+}
+
+// BackupRemote is an S3-compatible bucket (AWS S3, Backblaze B2, MinIO...) that backups are uploaded to
+// Off-site upload is enabled by setting Bucket
+type BackupRemote struct {
+	// Endpoint is the S3 API URL, e.g. "https://s3.eu-central-003.backblazeb2.com"; empty means AWS
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to every object name; defaults to "laravel-setup"
+	Prefix string
+	// AccessKeyID and SecretAccessKey authenticate with the bucket
+	// Leaving both empty uses the environment or the instance role, on AWS
+	AccessKeyID     string
+	SecretAccessKey string
+	// Passphrase encrypts uploads on the host before they leave it; empty uploads them as they are
+	Passphrase string
+	// KeepDays deletes uploads older than KeepDays days from the bucket; defaults to 30
+	KeepDays int
+}
+
+// Enabled reports whether backups are uploaded
+func (r BackupRemote) Enabled() bool {
+	return r.Bucket != ""
+}
+
+// WithDefaults returns the backup settings with defaults for unset values
+func (b Backup) WithDefaults() Backup {
+	if b.Dir == "" {
+		b.Dir = "/var/backups/laravel-setup"
+	}
+	if b.Schedule == "" {
+		b.Schedule = "*-*-* 03:00:00"
+	}
+	if b.KeepDaily == 0 {
+		b.KeepDaily = 7
+	}
+	if b.KeepWeekly == 0 {
+		b.KeepWeekly = 4
+	}
+	if b.Remote.Prefix == "" {
+		b.Remote.Prefix = "laravel-setup"
+	}
+	if b.Remote.KeepDays == 0 {
+		b.Remote.KeepDays = 30
+	}
+	return b
+}
+
+// Validate checks the backup settings
+func (b Backup) Validate() error {
+	if b.Dir != "" && !strings.HasPrefix(b.Dir, "/") {
+		return fmt.Errorf("backup: Dir %q must be an absolute path", b.Dir)
+	}
+	if strings.ContainsAny(b.Dir+b.Schedule, "\n\"") {
+		return fmt.Errorf("backup: Dir and Schedule can't contain quotes or newlines")
+	}
+	if b.KeepDaily < 0 || b.KeepWeekly < 0 {
+		return fmt.Errorf("backup: KeepDaily and KeepWeekly can't be negative")
+	}
+	return b.Remote.Validate()
+}
+
+// Validate checks the bucket settings
+func (r BackupRemote) Validate() error {
+	if !r.Enabled() {
+		return nil
+	}
+	if !bucketName.MatchString(r.Bucket) {
+		return fmt.Errorf("backup.remote: Bucket %q is not a valid bucket name", r.Bucket)
+	}
+	if r.Endpoint != "" && !strings.HasPrefix(r.Endpoint, "https://") && !strings.HasPrefix(r.Endpoint, "http://") {
+		return fmt.Errorf("backup.remote: Endpoint %q must be an http:// or https:// URL", r.Endpoint)
+	}
+	if strings.HasPrefix(r.Prefix, "/") || strings.Contains(r.Prefix, "..") || strings.Contains(r.Prefix, ":") {
+		return fmt.Errorf("backup.remote: Prefix %q must be a relative path", r.Prefix)
+	}
+	if (r.AccessKeyID == "") != (r.SecretAccessKey == "") {
+		return fmt.Errorf("backup.remote: set both AccessKeyID and SecretAccessKey, or neither")
+	}
+	if r.KeepDays < 0 {
+		return fmt.Errorf("backup.remote: KeepDays can't be negative")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -19,24 +20,77 @@ type Config struct {
 	SSHPort        string
 	WebRoot        string
 	ScriptDir      string
+	// ConfigPath is the absolute path of the configuration file that was loaded, empty when none was found
+	ConfigPath string `toml:"-"`
+	// Database selects and configures the database server
+	Database Database
+	// Redis configures the Redis server for the cache, sessions and queues
+	Redis Redis
+	// PHPVersion is the PHP major.minor version of the primary site, e.g. "8.2"
+	PHPVersion PHPVersion
+	// PHPVersions lists extra PHP versions to install side by side
+	PHPVersions []PHPVersion
+	// PHPExtensions lists the PHP extensions to install, by their composer name without "ext-"
+	// Leave empty to install DefaultPHPExtensions
+	PHPExtensions []string
+	// FPM holds the PHP-FPM pool settings shared by every site
+	FPM FPMPool
+	// Memory sets aside RAM for the database, Redis and the system when sizing the PHP-FPM pools
+	Memory Memory
+	// PHP holds PHP runtime settings such as the [php.ini] table
+	PHP PHPSettings
+	// OPcache configures the opcode cache of PHP-FPM
+	OPcache OPcache
+	// Backup schedules database backups and sets their retention
+	Backup Backup
+	// Import loads an SQL dump or runs a seeder when the Laravel application is set up
+	Import Import
+	// Vault configures how the encrypted credentials vault is unlocked
+	Vault Vault
+	// Sites lists additional applications hosted on the server
+	Sites []Site
 	// Skip flags
 	SkipSystemUpdate bool
 	SkipEssentials   bool
 	SkipPHP          bool
 	SkipMySQL        bool
+	SkipRedis        bool
 	SkipNginx        bool
 	SkipSecurity     bool
 	SkipLaravel      bool
 	SkipServices     bool
+	SkipBackup       bool
+	// Timeouts for commands and steps
+	Timeouts Timeouts
+}
+
+// Timeouts limits how long commands and steps may run
+// A zero duration means no limit; values are Go durations such as "30m" or "1h30m"
+type Timeouts struct {
+	// Command limits each command run by a step, except interactive ones
+	Command time.Duration
+	// Step limits each step, including time spent waiting for answers to prompts
+	Step time.Duration
+	// Steps overrides Step for individual steps, keyed by step ID (e.g. "essentials")
+	Steps map[string]time.Duration
+}
+
+// StepTimeout returns the timeout for the step with the given ID
+func (t Timeouts) StepTimeout(id string) time.Duration {
+	if timeout, ok := t.Steps[id]; ok {
+		return timeout
+	}
+	return t.Step
 }
 
 // NewConfig initializes a new configuration with default values
 func NewConfig() *Config {
 	return &Config{
-		DBName:  "production_db",
-		DBUser:  "db_user",
-		SSHPort: "2222",
-		WebUser: "www-data",
+		DBName:     "production_db",
+		DBUser:     "db_user",
+		SSHPort:    "2222",
+		WebUser:    "www-data",
+		PHPVersion: DefaultPHPVersion,
 	}
 }
 
@@ -66,3 +120,19 @@ func GetDefaultConfigPath() (string, error) {
 
 	return filepath.Join(homeDir, "config.toml"), nil
 }
+
+// Services returns the systemd services the setup runs on this host
+// The database and Redis servers are left out when they are managed elsewhere
+func (c *Config) Services() []string {
+	services := []string{"nginx"}
+	for _, version := range c.AllPHPVersions() {
+		services = append(services, version.FPMService())
+	}
+	if !c.Database.External() {
+		services = append(services, c.Database.Service())
+	}
+	if !c.Redis.External() {
+		services = append(services, "redis-server")
+	}
+	return append(services, "supervisor")
+}
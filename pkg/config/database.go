@@ -0,0 +1,313 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// hostPattern matches host names and IP addresses of database and Redis servers
+var hostPattern = regexp.MustCompile(`^[A-Za-z0-9.:-]+$`)
+
+// Database engines supported by the setup
+const (
+	EngineMySQL    = "mysql"
+	EngineMariaDB  = "mariadb"
+	EnginePostgres = "postgres"
+)
+
+// Authentication of the MySQL and MariaDB root account
+const (
+	// RootAuthSocket lets only the system root user log in as root, over the local socket
+	RootAuthSocket = "socket"
+	// RootAuthPassword requires DBRootPassword; the tool keeps it in a root-only client option file
+	RootAuthPassword = "password"
+)
+
+// Grant profiles of the database accounts
+const (
+	// ProfileApp is the application's runtime account (DBUser): reads and writes rows only
+	ProfileApp = "app"
+	// ProfileMigrate runs the migrations during setup and deploys: changes the schema
+	ProfileMigrate = "migrate"
+	// ProfileReadOnly is an optional reporting account: reads only
+	ProfileReadOnly = "readonly"
+	// ProfileAdmin is an optional account with every privilege on the server
+	ProfileAdmin = "admin"
+)
+
+// MySQL validate_password policies, plus off to leave the component uninstalled
+var passwordPolicies = []string{"off", "low", "medium", "strong"}
+
+// Database configures the database server
+// The database name, user and passwords are the top-level DBName, DBUser, DBPassword and DBRootPassword
+type Database struct {
+	// Engine is "mysql" (the default), "mariadb" or "postgres"
+	Engine string
+	// Connection overrides the DB_CONNECTION written to .env
+	// e.g. "mysql" for a MariaDB server used by an application older than Laravel 11
+	Connection string
+	// RootAuth is how the MySQL or MariaDB root account authenticates: "socket" (the default) or "password"
+	RootAuth string
+	// PasswordPolicy is the MySQL validate_password policy: "off", "low", "medium" (the default) or "strong"
+	PasswordPolicy string
+	// BufferPoolMB sets innodb_buffer_pool_size in MiB; 0 sizes it from the RAM left for the database
+	BufferPoolMB int
+	// MaxConnections sets max_connections; 0 allows every PHP-FPM and queue worker plus some headroom
+	MaxConnections int
+	// SlowQueryTime logs MySQL and MariaDB queries slower than this to the slow query log; defaults to 1s
+	SlowQueryTime time.Duration
+	// MigrationUser runs the migrations; defaults to DBUser with a "_migrate" suffix
+	MigrationUser string
+	// ReadOnlyUser is a reporting account with read access; none is created when empty
+	ReadOnlyUser string
+	// AdminUser is an account with every privilege on the server; none is created when empty
+	AdminUser string
+	// Host is a managed database server (Amazon RDS, DigitalOcean...) used instead of installing one
+	// Empty installs the engine on this host
+	Host string
+	// Port is the port of Host; defaults to the engine's
+	Port int
+	// SSLCA is the CA certificate file that verifies Host's TLS certificate; empty doesn't verify it
+	SSLCA string
+	// HostAdminUser and HostAdminPassword are the provider's admin account on Host, used to create the
+	// database and accounts; leave them empty when they already exist, and DBUser then runs the migrations
+	HostAdminUser     string
+	HostAdminPassword string
+}
+
+// Account is a database account and the grant profile it is created with
+type Account struct {
+	Profile string
+	User    string
+}
+
+// DatabaseAccounts returns the accounts to create: the application and migration accounts,
+// plus the read-only and admin accounts when configured
+// Without admin credentials for a managed server only the existing application account is used
+func (c *Config) DatabaseAccounts() []Account {
+	accounts := []Account{{Profile: ProfileApp, User: c.DBUser}}
+	if c.Database.External() && c.Database.HostAdminUser == "" {
+		return accounts
+	}
+	accounts = append(accounts, Account{Profile: ProfileMigrate, User: c.MigrationUser()})
+	if c.Database.ReadOnlyUser != "" {
+		accounts = append(accounts, Account{Profile: ProfileReadOnly, User: c.Database.ReadOnlyUser})
+	}
+	if c.Database.AdminUser != "" {
+		accounts = append(accounts, Account{Profile: ProfileAdmin, User: c.Database.AdminUser})
+	}
+	return accounts
+}
+
+// MigrationUser returns the account that runs the migrations
+func (c *Config) MigrationUser() string {
+	if c.Database.MigrationUser != "" {
+		return c.Database.MigrationUser
+	}
+	return c.DBUser + "_migrate"
+}
+
+// EngineName returns the configured engine, defaulting to MySQL
+func (d Database) EngineName() string {
+	if d.Engine == "" {
+		return EngineMySQL
+	}
+	return d.Engine
+}
+
+// IsMySQL reports whether the engine speaks the MySQL protocol, i.e. MySQL or MariaDB
+func (d Database) IsMySQL() bool {
+	return d.EngineName() == EngineMySQL || d.EngineName() == EngineMariaDB
+}
+
+// LaravelConnection returns the DB_CONNECTION value for the engine
+func (d Database) LaravelConnection() string {
+	if d.Connection != "" {
+		return d.Connection
+	}
+	if d.EngineName() == EnginePostgres {
+		return "pgsql"
+	}
+	return d.EngineName()
+}
+
+// External reports whether the database server is managed elsewhere rather than installed on this host
+func (d Database) External() bool {
+	return d.Host != ""
+}
+
+// MySQLSocket is the socket of the local MySQL and MariaDB servers
+const MySQLSocket = "/run/mysqld/mysqld.sock"
+
+// HostName returns the host Laravel connects to
+// The local MySQL and MariaDB accounts log in from localhost, which skip-name-resolve only matches
+// on the socket, so Laravel connects through it
+func (d Database) HostName() string {
+	switch {
+	case d.External():
+		return d.Host
+	case d.IsMySQL():
+		return "localhost"
+	}
+	return "127.0.0.1"
+}
+
+// Socket returns the socket Laravel connects to, empty when it connects over TCP
+func (d Database) Socket() string {
+	if d.External() || !d.IsMySQL() {
+		return ""
+	}
+	return MySQLSocket
+}
+
+// PortNumber returns the TCP port of the server: Port, or the engine's default
+func (d Database) PortNumber() int {
+	if d.Port != 0 {
+		return d.Port
+	}
+	if d.EngineName() == EnginePostgres {
+		return 5432
+	}
+	return 3306
+}
+
+// PHPExtension returns the PHP extension Laravel needs to connect to the engine
+func (d Database) PHPExtension() string {
+	if d.EngineName() == EnginePostgres {
+		return "pdo_pgsql"
+	}
+	return "pdo_mysql"
+}
+
+// Service returns the systemd service of the engine
+func (d Database) Service() string {
+	switch d.EngineName() {
+	case EngineMariaDB:
+		return "mariadb"
+	case EnginePostgres:
+		return "postgresql"
+	default:
+		return "mysql"
+	}
+}
+
+// DisplayName returns the engine name for messages, e.g. "PostgreSQL"
+func (d Database) DisplayName() string {
+	switch d.EngineName() {
+	case EngineMariaDB:
+		return "MariaDB"
+	case EnginePostgres:
+		return "PostgreSQL"
+	default:
+		return "MySQL"
+	}
+}
+
+// RootAuthMode returns how the root account authenticates, defaulting to the socket
+func (d Database) RootAuthMode() string {
+	if d.RootAuth == "" {
+		return RootAuthSocket
+	}
+	return d.RootAuth
+}
+
+// PasswordPolicyLevel returns the validate_password policy as MySQL spells it, e.g. "MEDIUM", or "OFF"
+func (d Database) PasswordPolicyLevel() string {
+	if d.PasswordPolicy == "" {
+		return "MEDIUM"
+	}
+	return strings.ToUpper(d.PasswordPolicy)
+}
+
+// Validate checks that the engine and its settings are supported
+func (d Database) Validate() error {
+	switch d.EngineName() {
+	case EngineMySQL, EngineMariaDB, EnginePostgres:
+	default:
+		return fmt.Errorf("database: unsupported engine %q, expected %q, %q or %q", d.Engine, EngineMySQL, EngineMariaDB, EnginePostgres)
+	}
+
+	if mode := d.RootAuthMode(); mode != RootAuthSocket && mode != RootAuthPassword {
+		return fmt.Errorf("database: unsupported RootAuth %q, expected %q or %q", d.RootAuth, RootAuthSocket, RootAuthPassword)
+	}
+
+	if d.BufferPoolMB < 0 || d.MaxConnections < 0 || d.SlowQueryTime < 0 {
+		return fmt.Errorf("database: BufferPoolMB, MaxConnections and SlowQueryTime can't be negative")
+	}
+
+	if err := d.validateHost(); err != nil {
+		return err
+	}
+
+	for _, policy := range passwordPolicies {
+		if strings.EqualFold(d.PasswordPolicyLevel(), policy) {
+			return nil
+		}
+	}
+	return fmt.Errorf("database: unsupported PasswordPolicy %q, expected one of %s", d.PasswordPolicy, strings.Join(passwordPolicies, ", "))
+}
+
+// validateHost checks the settings of a managed server
+func (d Database) validateHost() error {
+	if d.Port < 0 || d.Port > 65535 {
+		return fmt.Errorf("database: Port %d is out of range", d.Port)
+	}
+	if !d.External() {
+		if d.HostAdminUser != "" || d.SSLCA != "" {
+			return fmt.Errorf("database: HostAdminUser and SSLCA need a managed server in Host")
+		}
+		return nil
+	}
+
+	if !hostPattern.MatchString(d.Host) {
+		return fmt.Errorf("database: Host %q is not a host name or IP address", d.Host)
+	}
+	if d.SSLCA != "" && (!strings.HasPrefix(d.SSLCA, "/") || strings.ContainsAny(d.SSLCA, "\n\"' ")) {
+		return fmt.Errorf("database: SSLCA %q must be an absolute path without quotes or spaces", d.SSLCA)
+	}
+	if (d.HostAdminUser == "") != (d.HostAdminPassword == "") {
+		return fmt.Errorf("database: set both HostAdminUser and HostAdminPassword, or neither")
+	}
+	if strings.ContainsAny(d.HostAdminUser+d.HostAdminPassword, "\r\n") {
+		return fmt.Errorf("database: HostAdminUser and HostAdminPassword can't contain newlines")
+	}
+	// Managed servers don't hand out server-wide privileges; the provider's admin account is the admin
+	if d.AdminUser != "" {
+		return fmt.Errorf("database: AdminUser can't be created on a managed server, use the provider's admin account")
+	}
+	return nil
+}
+
+// LogDir returns the directory the engine logs to
+func (d Database) LogDir() string {
+	if d.EngineName() == EnginePostgres {
+		return "/var/log/postgresql/"
+	}
+	return "/var/log/mysql/"
+}
+
+// ClientFile returns the name of the client password file written to the user's home directory,
+// which logs mysql or psql in to the Laravel database
+func (d Database) ClientFile() string {
+	if d.EngineName() == EnginePostgres {
+		return ".pgpass"
+	}
+	return ".my.cnf"
+}
+
+// validateDatabaseAccounts checks that every account has its own user name
+func (c *Config) validateDatabaseAccounts() error {
+	seen := map[string]string{}
+	for _, account := range c.DatabaseAccounts() {
+		if account.User == "" {
+			continue
+		}
+		if profile, ok := seen[account.User]; ok {
+			return fmt.Errorf("database: %s and %s accounts both use user %q", profile, account.Profile, account.User)
+		}
+		seen[account.User] = account.Profile
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Import loads existing data into the new database during the Laravel setup
+// The import and the seeder only run while the database has no tables, so running the setup again
+// doesn't load them twice
+type Import struct {
+	// Source is an SQL dump, .sql or .sql.gz, as a local path or an http(s) URL,
+	// loaded before the migrations run
+	Source string
+	// SkipMigrate leaves out artisan migrate, e.g. when the dump is already at the latest migration
+	SkipMigrate bool
+	// SeedClass is run with artisan db:seed after the migrations of a fresh install, e.g. "ProductionSeeder"
+	SeedClass string
+}
+
+// seedClassPattern matches a PHP class name, optionally with its namespace
+var seedClassPattern = regexp.MustCompile(`^\\?[A-Za-z_][A-Za-z0-9_]*(\\[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// IsURL reports whether Source is downloaded rather than read from the host
+func (i Import) IsURL() bool {
+	return strings.HasPrefix(i.Source, "http://") || strings.HasPrefix(i.Source, "https://")
+}
+
+// sourcePath returns the path of Source, without the query string of a URL
+func (i Import) sourcePath() string {
+	if i.IsURL() {
+		if u, err := url.Parse(i.Source); err == nil {
+			return u.Path
+		}
+	}
+	return i.Source
+}
+
+// Compressed reports whether Source is a gzip-compressed dump
+func (i Import) Compressed() bool {
+	return strings.HasSuffix(i.sourcePath(), ".gz")
+}
+
+// Validate checks the import settings
+func (i Import) Validate() error {
+	if i.Source != "" {
+		path := i.sourcePath()
+		if !strings.HasSuffix(path, ".sql") && !strings.HasSuffix(path, ".sql.gz") {
+			return fmt.Errorf("import: Source %q must be a .sql or .sql.gz file", i.Source)
+		}
+		if i.SeedClass != "" {
+			return fmt.Errorf("import: SeedClass is for fresh installs and can't be combined with Source")
+		}
+	}
+	if i.SeedClass != "" && !seedClassPattern.MatchString(i.SeedClass) {
+		return fmt.Errorf("import: SeedClass %q is not a PHP class name", i.SeedClass)
+	}
+	return nil
+}
@@ -1,17 +1,70 @@
 package config
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"laravel-setup/pkg/utils"
 )
 
 // InitConfig initializes the configuration with user input and/or config file
-func InitConfig(configPath string) (*Config, error) {
+func InitConfig(ctx context.Context, configPath string) (*Config, error) {
+	config, err := Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Complete(ctx, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Complete fills in the settings that are still missing after loading
+// The user is asked for the domain and repository, and the application's database password is generated
+// A missing root password is filled in by the database step, which reuses the one in the vault
+func Complete(ctx context.Context, config *Config) error {
+	// Get domain from user input if not in config
+	if config.Domain == "" {
+		domain, err := utils.Prompt("Enter the Domain for your Laravel project: ")
+		if err != nil {
+			return err
+		}
+		config.Domain = domain
+	}
+
+	// Get repository URL from user input if not in config
+	if config.RepoURL == "" {
+		repoURL, err := utils.Prompt("Enter the Git repository URL for your Laravel project: ")
+		if err != nil {
+			return err
+		}
+		config.RepoURL = repoURL
+	}
+
+	// Generate a random password for the application account if not in config
+	if config.DBPassword == "" {
+		password, err := utils.GeneratePassword()
+		if err != nil {
+			return err
+		}
+		config.DBPassword = password
+	}
+
+	// Set web root based on domain if not in config
+	if config.WebRoot == "" {
+		config.WebRoot = "/var/www/" + config.Domain
+	}
+
+	return nil
+}
+
+// Load loads the configuration without prompting the user
+// Values that are missing from the config file are left empty so callers can decide how to fill them
+func Load(configPath string) (*Config, error) {
 	var config *Config
 	var err error
 
@@ -23,6 +76,9 @@ func InitConfig(configPath string) (*Config, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to load config from %s: %w", configPath, err)
 		}
+		if _, err := os.Stat(configPath); err == nil {
+			config.ConfigPath, _ = filepath.Abs(configPath)
+		}
 	} else {
 		// Try default config a path
 		defaultPath, err := GetDefaultConfigPath()
@@ -36,6 +92,7 @@ func InitConfig(configPath string) (*Config, error) {
 			if err != nil {
 				return nil, fmt.Errorf("failed to load config from %s: %w", defaultPath, err)
 			}
+			config.ConfigPath = defaultPath
 		} else {
 			// No config file found, use default config
 			utils.PrintStatus("No configuration file found, using default configuration")
@@ -50,40 +107,49 @@ func InitConfig(configPath string) (*Config, error) {
 	}
 	config.ScriptDir = filepath.Dir(ex)
 
-	reader := bufio.NewReader(os.Stdin)
+	// Set web root based on domain if not in config
+	if config.WebRoot == "" && config.Domain != "" {
+		config.WebRoot = "/var/www/" + config.Domain
+	}
 
-	// Get domain from user input if not in config
-	if config.Domain == "" {
-		fmt.Print("Enter the Domain for your Laravel project: ")
-		domain, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
-		config.Domain = strings.TrimSpace(domain)
+	if err := config.validateSites(); err != nil {
+		return nil, err
 	}
 
-	// Get repository URL from user input if not in config
-	if config.RepoURL == "" {
-		fmt.Print("Enter the Git repository URL for your Laravel project: ")
-		repoURL, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
-		config.RepoURL = strings.TrimSpace(repoURL)
+	if err := config.validatePHPExtensions(); err != nil {
+		return nil, err
 	}
 
-	// Generate random passwords for database if not in config
-	if config.DBPassword == "" {
-		config.DBPassword = utils.GenerateRandomPassword()
+	if err := config.PHP.INI.Validate(); err != nil {
+		return nil, err
 	}
 
-	if config.DBRootPassword == "" {
-		config.DBRootPassword = utils.GenerateRandomPassword()
+	if err := config.OPcache.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Set web root based on domain if not in config
-	if config.WebRoot == "" {
-		config.WebRoot = "/var/www/" + config.Domain
+	if err := config.Database.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateDatabaseAccounts(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Redis.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Backup.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Import.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Vault.Validate(); err != nil {
+		return nil, err
 	}
 
 	return config, nil
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// QueueWorkers is the number of Laravel queue workers Supervisor runs
+const QueueWorkers = 2
+
+// Estimates used to size MySQL and MariaDB from the RAM left for them
+const (
+	// mysqlBaseMB is used by the server itself: dictionary, caches and performance_schema
+	mysqlBaseMB = 256
+	// mysqlConnectionMB is used by each connection: thread stack and sort, join and read buffers
+	mysqlConnectionMB = 4
+	// mysqlExtraConnections leaves room for artisan commands, the scheduler and administration
+	mysqlExtraConnections = 20
+	// bufferPoolChunkMB is innodb_buffer_pool_chunk_size; the pool is a multiple of it
+	bufferPoolChunkMB = 128
+	// performanceSchemaMinMB is the RAM from which performance_schema is left on
+	performanceSchemaMinMB = 1024
+)
+
+// MySQLTuning is the memory and logging configuration of MySQL or MariaDB, sized for a host
+type MySQLTuning struct {
+	// MemoryMB is the RAM the server may use, the smaller of the Memory.Database reservation
+	// and what the PHP-FPM pools, Redis and the system leave
+	MemoryMB          int
+	BufferPoolMB      int
+	RedoLogMB         int
+	MaxConnections    int
+	SlowQueryTime     time.Duration
+	PerformanceSchema bool
+}
+
+// MySQLTuning sizes MySQL or MariaDB for a host
+// max_connections covers every PHP-FPM worker and queue worker; the buffer pool gets the RAM left
+// after the server's own memory and the memory of those connections
+func (c *Config) MySQLTuning(host Host) MySQLTuning {
+	memory := c.ResolveMemory(host.MemoryMB)
+
+	fpmWorkers := 0
+	for _, site := range c.SizedSites(host) {
+		fpmWorkers += site.FPM.MaxChildren
+	}
+
+	t := MySQLTuning{
+		MemoryMB:       memory.Database,
+		MaxConnections: c.Database.MaxConnections,
+		SlowQueryTime:  c.Database.SlowQueryTime,
+	}
+
+	// Pools sized by hand can take more than their share, and that comes out of the database's
+	if left := host.MemoryMB - memory.Redis - memory.System - fpmWorkers*memory.FPMWorker; left < t.MemoryMB {
+		t.MemoryMB = left
+	}
+
+	if t.MaxConnections == 0 {
+		t.MaxConnections = fpmWorkers + QueueWorkers + mysqlExtraConnections
+	}
+	if t.SlowQueryTime == 0 {
+		t.SlowQueryTime = time.Second
+	}
+	t.PerformanceSchema = t.MemoryMB >= performanceSchemaMinMB
+
+	t.BufferPoolMB = c.Database.BufferPoolMB
+	if t.BufferPoolMB == 0 {
+		base := mysqlBaseMB
+		if !t.PerformanceSchema {
+			base /= 2
+		}
+		t.BufferPoolMB = (t.MemoryMB - base - t.MaxConnections*mysqlConnectionMB) / bufferPoolChunkMB * bufferPoolChunkMB
+		if t.BufferPoolMB < bufferPoolChunkMB {
+			t.BufferPoolMB = bufferPoolChunkMB
+		}
+	}
+
+	// A quarter of the buffer pool lets InnoDB absorb write bursts without stalling on checkpoints
+	t.RedoLogMB = clamp(t.BufferPoolMB/4, 64, 4096)
+
+	return t
+}
+
+// Describe summarizes the tuning, e.g. for the plan output
+func (t MySQLTuning) Describe() string {
+	return fmt.Sprintf("%d MiB: innodb_buffer_pool_size = %dM, redo log %dM, max_connections = %d, long_query_time = %s",
+		t.MemoryMB, t.BufferPoolMB, t.RedoLogMB, t.MaxConnections, t.SlowQueryTime)
+}
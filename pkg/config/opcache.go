@@ -0,0 +1,62 @@
+package config
+
+import "fmt"
+
+// OPcache configures the PHP-FPM opcode cache
+// Sizes left at zero are derived from the number of PHP files in the applications served by a PHP version
+type OPcache struct {
+	// MemoryConsumption is the shared memory for compiled scripts in MiB
+	MemoryConsumption int
+	// InternedStringsBuffer is the memory for interned strings in MiB
+	InternedStringsBuffer int
+	// MaxAcceleratedFiles is the number of scripts that can be cached
+	MaxAcceleratedFiles int
+	// ValidateTimestamps makes OPcache pick up changed files on its own
+	// When false (the default) PHP-FPM is reloaded on every deploy instead
+	ValidateTimestamps bool
+	// RevalidateFreq is how often timestamps are checked in seconds, when ValidateTimestamps is set
+	RevalidateFreq int
+	// JIT enables the tracing JIT compiler
+	JIT bool
+	// JITBufferSize is the memory for JIT compiled code in MiB, 64 when JIT is enabled
+	JITBufferSize int
+	// Preload preloads the Laravel framework classes of the primary site when PHP-FPM starts
+	Preload bool
+}
+
+// Sized returns the settings with the sizes that aren't set derived from the number of PHP files to cache
+// Each cached file takes about 16 KiB; room is left for the application to grow
+func (o OPcache) Sized(files int) OPcache {
+	if o.MaxAcceleratedFiles == 0 {
+		o.MaxAcceleratedFiles = clamp(roundUp(files*3/2, 1000), 10000, 1000000)
+	}
+	if o.MemoryConsumption == 0 {
+		o.MemoryConsumption = clamp(roundUp(files/64*3/2, 64), 128, 2048)
+	}
+	if o.InternedStringsBuffer == 0 {
+		o.InternedStringsBuffer = clamp(o.MemoryConsumption/8, 16, 256)
+	}
+	if o.RevalidateFreq == 0 {
+		o.RevalidateFreq = 2
+	}
+	if o.JIT && o.JITBufferSize == 0 {
+		o.JITBufferSize = 64
+	}
+	return o
+}
+
+// Validate checks that the sizes are usable
+func (o OPcache) Validate() error {
+	if o.MemoryConsumption < 0 || o.InternedStringsBuffer < 0 || o.MaxAcceleratedFiles < 0 || o.RevalidateFreq < 0 || o.JITBufferSize < 0 {
+		return fmt.Errorf("OPcache sizes must not be negative")
+	}
+	if o.InternedStringsBuffer > 0 && o.MemoryConsumption > 0 && o.InternedStringsBuffer >= o.MemoryConsumption {
+		return fmt.Errorf("OPcache InternedStringsBuffer must be smaller than MemoryConsumption")
+	}
+	return nil
+}
+
+// roundUp rounds v up to a multiple of step
+func roundUp(v, step int) int {
+	return (v + step - 1) / step * step
+}
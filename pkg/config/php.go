@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultPHPVersion is the PHP version installed when PHPVersion isn't set
+const DefaultPHPVersion PHPVersion = "8.4"
+
+// minPHPMajor and minPHPMinor are the oldest PHP version the tool supports
+const (
+	minPHPMajor = 8
+	minPHPMinor = 1
+)
+
+// DefaultPHPExtensions are the PHP extensions installed when PHPExtensions isn't set
+var DefaultPHPExtensions = []string{
+	"pdo_mysql", "mbstring", "xml", "bcmath", "curl", "gd",
+	"zip", "intl", "soap", "redis", "imagick", "opcache",
+}
+
+// phpExtensionPattern matches a PHP extension name such as "pdo_pgsql"
+var phpExtensionPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// phpVersionPattern matches a PHP major.minor version such as "8.2"
+var phpVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)$`)
+
+// PHPVersion is a PHP major.minor version such as "8.2"
+// Package names, service names and paths for the version are derived from it
+type PHPVersion string
+
+// Validate checks that the version is a major.minor PHP version the tool supports
+// Versions newer than the ones tested are accepted, as long as the PPA provides them
+func (v PHPVersion) Validate() error {
+	m := phpVersionPattern.FindStringSubmatch(string(v))
+	if m == nil {
+		return fmt.Errorf("invalid PHP version %q, expected major.minor such as %q", string(v), string(DefaultPHPVersion))
+	}
+
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	if major < minPHPMajor || (major == minPHPMajor && minor < minPHPMinor) {
+		return fmt.Errorf("PHP %s is not supported, the minimum is %d.%d", string(v), minPHPMajor, minPHPMinor)
+	}
+
+	return nil
+}
+
+// Binary returns the versioned PHP CLI binary, e.g. "php8.4"
+func (v PHPVersion) Binary() string {
+	return "php" + string(v)
+}
+
+// Package returns the apt package name of a PHP component, e.g. "fpm" gives "php8.4-fpm"
+// An empty component gives the base package, e.g. "php8.4"
+func (v PHPVersion) Package(component string) string {
+	if component == "" {
+		return v.Binary()
+	}
+	return v.Binary() + "-" + component
+}
+
+// FPMService returns the systemd service name of PHP-FPM
+func (v PHPVersion) FPMService() string {
+	return v.Package("fpm")
+}
+
+// FPMSocket returns the Unix socket of the default PHP-FPM pool
+func (v PHPVersion) FPMSocket() string {
+	return "/var/run/php/php" + string(v) + "-fpm.sock"
+}
+
+// ConfigDir returns the configuration directory, e.g. "/etc/php/8.4"
+func (v PHPVersion) ConfigDir() string {
+	return "/etc/php/" + string(v)
+}
+
+// FPMLog returns the PHP-FPM log file
+func (v PHPVersion) FPMLog() string {
+	return "/var/log/php" + string(v) + "-fpm.log"
+}
+
+// NormalizePHPExtension returns the canonical name of a PHP extension
+// Names are lowercase and the composer "ext-" prefix is dropped, e.g. "ext-PDO_PGSQL" gives "pdo_pgsql"
+func NormalizePHPExtension(name string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "ext-")
+}
+
+// AllPHPExtensions returns the PHP extensions to install for every version, without duplicates
+// The extension of the configured database engine is always included
+func (c *Config) AllPHPExtensions() []string {
+	names := c.PHPExtensions
+	if len(names) == 0 {
+		names = DefaultPHPExtensions
+	}
+	names = append(append([]string(nil), names...), c.Database.PHPExtension())
+
+	seen := map[string]bool{}
+	var extensions []string
+	for _, name := range names {
+		name = NormalizePHPExtension(name)
+		if !seen[name] {
+			seen[name] = true
+			extensions = append(extensions, name)
+		}
+	}
+	return extensions
+}
+
+// IsValidPHPExtension reports whether a normalized extension name is safe to pass to apt
+func IsValidPHPExtension(name string) bool {
+	return phpExtensionPattern.MatchString(name)
+}
+
+// validatePHPExtensions checks that every configured extension name is safe to pass to apt
+func (c *Config) validatePHPExtensions() error {
+	for _, name := range c.PHPExtensions {
+		if !IsValidPHPExtension(NormalizePHPExtension(name)) {
+			return fmt.Errorf("PHPExtensions: invalid extension name %q", name)
+		}
+	}
+	return nil
+}
+
+// FPMBinary returns the PHP-FPM binary, e.g. "php-fpm8.4"
+func (v PHPVersion) FPMBinary() string {
+	return "php-fpm" + string(v)
+}
+
+// INIDropIn returns the managed conf.d file holding the php.ini settings of a SAPI ("fpm" or "cli")
+func (v PHPVersion) INIDropIn(sapi string) string {
+	return v.ConfigDir() + "/" + sapi + "/conf.d/99-laravel-setup.ini"
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SAPIs that get their own php.ini settings
+const (
+	SAPIFPM = "fpm"
+	SAPICLI = "cli"
+)
+
+// iniKeyPattern matches a php.ini directive name such as "opcache.memory_consumption"
+var iniKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// defaultINI holds the php.ini settings applied to each SAPI unless overridden
+var defaultINI = map[string]map[string]string{
+	SAPIFPM: {
+		// Disable path info fixing for security
+		"cgi.fix_pathinfo": "0",
+		// Hide the PHP version from response headers
+		"expose_php": "Off",
+		// Allow larger file uploads
+		"upload_max_filesize": "64M",
+		"post_max_size":       "64M",
+		// Allow longer-running requests and more complex applications
+		"max_execution_time": "300",
+		"memory_limit":       "512M",
+	},
+	SAPICLI: {},
+}
+
+// PHPSettings holds settings for the PHP runtime
+type PHPSettings struct {
+	// INI is the [php.ini] table, see PHPINI
+	INI PHPINI `toml:"ini"`
+}
+
+// PHPINI holds php.ini directives written to a conf.d drop-in for every PHP version
+// Top-level keys apply to both FPM and the CLI; the fpm and cli subtables apply to one of them:
+//
+//	[php.ini]
+//	memory_limit = "512M"
+//	[php.ini.cli]
+//	memory_limit = "-1"
+type PHPINI map[string]interface{}
+
+// Settings returns the effective php.ini directives of a SAPI ("fpm" or "cli")
+// Built-in defaults are overridden by the shared keys, which are overridden by the SAPI's subtable
+func (ini PHPINI) Settings(sapi string) map[string]string {
+	settings := map[string]string{}
+	for k, v := range defaultINI[sapi] {
+		settings[k] = v
+	}
+
+	for k, v := range ini {
+		if k == SAPIFPM || k == SAPICLI {
+			continue
+		}
+		settings[k] = formatINIValue(v)
+	}
+
+	if table, ok := ini[sapi].(map[string]interface{}); ok {
+		for k, v := range table {
+			settings[k] = formatINIValue(v)
+		}
+	}
+
+	return settings
+}
+
+// Validate checks that every directive can be written to an ini file safely
+func (ini PHPINI) Validate() error {
+	for _, sapi := range []string{SAPIFPM, SAPICLI} {
+		if v, ok := ini[sapi]; ok {
+			if _, isTable := v.(map[string]interface{}); !isTable {
+				return fmt.Errorf("php.ini: %s must be a table", sapi)
+			}
+		}
+
+		for k, v := range ini.Settings(sapi) {
+			if !iniKeyPattern.MatchString(k) {
+				return fmt.Errorf("php.ini: invalid directive name %q", k)
+			}
+			if strings.ContainsAny(v, "\"\r\n") {
+				return fmt.Errorf("php.ini: %s contains a quote or line break", k)
+			}
+		}
+	}
+
+	for k, v := range ini {
+		if _, isTable := v.(map[string]interface{}); isTable && k != SAPIFPM && k != SAPICLI {
+			return fmt.Errorf("php.ini: unknown table %q, expected %q or %q", k, SAPIFPM, SAPICLI)
+		}
+	}
+
+	return nil
+}
+
+// formatINIValue converts a TOML value to its php.ini form, e.g. true becomes "On"
+func formatINIValue(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "On"
+		}
+		return "Off"
+	default:
+		return fmt.Sprint(v)
+	}
+}
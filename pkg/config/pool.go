@@ -0,0 +1,224 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Process manager modes supported by PHP-FPM
+const (
+	PMDynamic  = "dynamic"
+	PMStatic   = "static"
+	PMOndemand = "ondemand"
+)
+
+// FPMStatusAddress is the localhost-only Nginx server exposing the status and ping pages of every pool
+const FPMStatusAddress = "127.0.0.1:8089"
+
+// FPMStatusSite is the name of the Nginx site holding the status server
+const FPMStatusSite = "laravel-setup-status"
+
+// Paths of the status and ping pages inside a PHP-FPM pool
+const (
+	FPMStatusPath = "/status"
+	FPMPingPath   = "/ping"
+)
+
+// accountPattern matches a Unix user or group name
+var accountPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+
+// envNamePattern matches an environment variable name
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// unsafeValuePattern matches characters that would break out of a pool configuration value
+var unsafeValuePattern = regexp.MustCompile(`[\r\n"]`)
+
+// FPMPool configures the dedicated PHP-FPM pool of a site
+// Zero values are filled in from the top-level FPM settings, then from the built-in defaults
+type FPMPool struct {
+	// User and Group run the pool's workers; missing ones are created as system accounts
+	// Group defaults to User when only User is set
+	User  string
+	Group string
+	// PM is the process manager mode: "dynamic", "static" or "ondemand"
+	// The process manager values left at zero are sized from the host's RAM and CPU count
+	PM              string
+	MaxChildren     int
+	StartServers    int
+	MinSpareServers int
+	MaxSpareServers int
+	// MaxRequests recycles a worker after this many requests to contain memory leaks
+	MaxRequests int
+	// RequestTerminateTimeout kills a worker whose request runs longer, e.g. "300s"
+	RequestTerminateTimeout time.Duration
+	// SlowlogTimeout logs a backtrace of requests running longer, e.g. "5s"
+	SlowlogTimeout time.Duration
+	// OpenBasedir limits the files PHP may open; empty allows the web root and /tmp only
+	OpenBasedir string
+	// Env sets environment variables for the pool's workers
+	Env map[string]string
+}
+
+// maxAccountName is the longest user or group name useradd and groupadd accept
+const maxAccountName = 32
+
+// siteAccount returns the default user and group of an additional site's pool, derived from its domain,
+// e.g. "legacy_example_com" for legacy.example.com, so the sites' workers don't share a user
+// Long names are shortened and made unique with a hash of the domain
+func siteAccount(domain string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '_'
+	}, domain)
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "site_" + name
+	}
+	if len(name) > maxAccountName {
+		sum := sha256.Sum256([]byte(domain))
+		name = name[:maxAccountName-9] + "_" + hex.EncodeToString(sum[:4])
+	}
+	return name
+}
+
+// defaultFPMPool returns the built-in pool settings for a site
+func defaultFPMPool(webUser, webRoot string) FPMPool {
+	return FPMPool{
+		User:                    webUser,
+		Group:                   webUser,
+		PM:                      PMDynamic,
+		MaxRequests:             500,
+		RequestTerminateTimeout: 300 * time.Second,
+		SlowlogTimeout:          5 * time.Second,
+		OpenBasedir:             webRoot + "/:/tmp/",
+	}
+}
+
+// withDefaults returns the pool with every zero value taken from d
+// Environment variables are merged, with the pool's own values taking precedence
+func (p FPMPool) withDefaults(d FPMPool) FPMPool {
+	if p.User == "" {
+		p.User = d.User
+	}
+	if p.Group == "" && p.User != "" {
+		// A pool with its own user gets the group of the same name
+		p.Group = p.User
+	}
+	if p.Group == "" {
+		p.Group = d.Group
+	}
+	if p.PM == "" {
+		p.PM = d.PM
+	}
+	if p.MaxChildren == 0 {
+		p.MaxChildren = d.MaxChildren
+	}
+	if p.StartServers == 0 {
+		p.StartServers = d.StartServers
+	}
+	if p.MinSpareServers == 0 {
+		p.MinSpareServers = d.MinSpareServers
+	}
+	if p.MaxSpareServers == 0 {
+		p.MaxSpareServers = d.MaxSpareServers
+	}
+	if p.MaxRequests == 0 {
+		p.MaxRequests = d.MaxRequests
+	}
+	if p.RequestTerminateTimeout == 0 {
+		p.RequestTerminateTimeout = d.RequestTerminateTimeout
+	}
+	if p.SlowlogTimeout == 0 {
+		p.SlowlogTimeout = d.SlowlogTimeout
+	}
+	if p.OpenBasedir == "" {
+		p.OpenBasedir = d.OpenBasedir
+	}
+
+	env := map[string]string{}
+	for k, v := range d.Env {
+		env[k] = v
+	}
+	for k, v := range p.Env {
+		env[k] = v
+	}
+	p.Env = env
+
+	return p
+}
+
+// Validate checks that the pool settings produce a valid PHP-FPM configuration
+func (p FPMPool) Validate() error {
+	if !accountPattern.MatchString(p.User) {
+		return fmt.Errorf("invalid pool user %q", p.User)
+	}
+	if !accountPattern.MatchString(p.Group) {
+		return fmt.Errorf("invalid pool group %q", p.Group)
+	}
+
+	switch p.PM {
+	case PMDynamic, PMStatic, PMOndemand:
+	default:
+		return fmt.Errorf("invalid pool PM %q, expected %q, %q or %q", p.PM, PMDynamic, PMStatic, PMOndemand)
+	}
+
+	if p.MaxChildren < 0 || p.StartServers < 0 || p.MinSpareServers < 0 || p.MaxSpareServers < 0 || p.MaxRequests < 0 {
+		return fmt.Errorf("pool process manager values must not be negative")
+	}
+
+	// Values left at zero are sized automatically, so the ordering can only be checked once all are set
+	if p.PM == PMDynamic && p.MaxChildren > 0 && p.StartServers > 0 && p.MinSpareServers > 0 && p.MaxSpareServers > 0 {
+		if p.MinSpareServers > p.StartServers || p.StartServers > p.MaxSpareServers || p.MaxSpareServers > p.MaxChildren {
+			return fmt.Errorf("dynamic pool needs MinSpareServers <= StartServers <= MaxSpareServers <= MaxChildren")
+		}
+	}
+
+	if unsafeValuePattern.MatchString(p.OpenBasedir) {
+		return fmt.Errorf("pool OpenBasedir contains a quote or line break")
+	}
+
+	for name, value := range p.Env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid pool environment variable name %q", name)
+		}
+		if unsafeValuePattern.MatchString(value) {
+			return fmt.Errorf("pool environment variable %s contains a quote or line break", name)
+		}
+	}
+
+	return nil
+}
+
+// FPMSocket returns the Unix socket of the site's PHP-FPM pool
+// The path includes the PHP version so the version serving a site can be read from its vhost
+func (s Site) FPMSocket() string {
+	return "/run/php/php" + string(s.PHPVersion) + "-fpm-" + s.Domain + ".sock"
+}
+
+// FPMPoolFile returns the configuration file of the site's PHP-FPM pool
+func (s Site) FPMPoolFile() string {
+	return s.PHPVersion.ConfigDir() + "/fpm/pool.d/" + s.Domain + ".conf"
+}
+
+// FPMStatusURL returns the URL of the status page of the site's PHP-FPM pool
+func (s Site) FPMStatusURL() string {
+	return "http://" + FPMStatusAddress + "/" + s.Domain + FPMStatusPath
+}
+
+// FPMPingURL returns the URL of the ping page of the site's PHP-FPM pool
+func (s Site) FPMPingURL() string {
+	return "http://" + FPMStatusAddress + "/" + s.Domain + FPMPingPath
+}
+
+// FPMSlowlog returns the slow request log of the site's PHP-FPM pool
+func (s Site) FPMSlowlog() string {
+	return "/var/log/php" + string(s.PHPVersion) + "-fpm-" + s.Domain + ".slow.log"
+}
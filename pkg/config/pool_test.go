@@ -0,0 +1,60 @@
+package config
+
+import "testing"
+
+func TestSiteAccount(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"legacy.example.com", "legacy_example_com"},
+		{"Shop-EU.example.org", "shop_eu_example_org"},
+		{"1password.example.com", "site_1password_example_com"},
+	}
+	for _, tt := range tests {
+		if got := siteAccount(tt.domain); got != tt.want {
+			t.Errorf("siteAccount(%q) = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestSiteAccountLongDomains(t *testing.T) {
+	a := siteAccount("a-very-long-subdomain.of-a-long-domain.example.com")
+	b := siteAccount("a-very-long-subdomain.of-a-long-domain.example.org")
+	for _, name := range []string{a, b} {
+		if len(name) != maxAccountName || !accountPattern.MatchString(name) {
+			t.Errorf("siteAccount() = %q, want a valid name of %d characters", name, maxAccountName)
+		}
+	}
+	if a == b {
+		t.Errorf("siteAccount() = %q for two domains, want distinct names", a)
+	}
+}
+
+func TestAllSitesPoolUsers(t *testing.T) {
+	c := &Config{
+		Domain:  "example.com",
+		WebUser: "www-data",
+		Sites: []Site{
+			{Domain: "legacy.example.com"},
+			{Domain: "blog.example.com", FPM: FPMPool{User: "blog"}},
+		},
+	}
+	want := []struct{ user, group string }{
+		{"www-data", "www-data"},
+		{"legacy_example_com", "legacy_example_com"},
+		{"blog", "blog"},
+	}
+	for i, site := range c.AllSites() {
+		if site.FPM.User != want[i].user || site.FPM.Group != want[i].group {
+			t.Errorf("pool of %s runs as %s:%s, want %s:%s", site.Domain, site.FPM.User, site.FPM.Group, want[i].user, want[i].group)
+		}
+	}
+
+	c.FPM.User = "shared"
+	for _, site := range c.AllSites()[:2] {
+		if site.FPM.User != "shared" {
+			t.Errorf("pool of %s runs as %s, want the [FPM] user shared", site.Domain, site.FPM.User)
+		}
+	}
+}
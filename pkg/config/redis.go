@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RedisSocket is the Unix socket the local Redis server listens on when Socket is set
+const RedisSocket = "/run/redis/redis-server.sock"
+
+// How the local Redis server keeps its data across restarts
+const (
+	// RedisPersistRDB saves snapshots of the data periodically, Redis's own default
+	RedisPersistRDB = "rdb"
+	// RedisPersistAOF logs every write to an append-only file, fsynced every second
+	RedisPersistAOF = "aof"
+	// RedisPersistNone keeps the data in memory only, so a restart empties the cache, sessions and queues
+	RedisPersistNone = "none"
+)
+
+// redisEvictionPolicies are the values of Redis's maxmemory-policy
+var redisEvictionPolicies = []string{
+	"noeviction", "allkeys-lru", "allkeys-lfu", "allkeys-random",
+	"volatile-lru", "volatile-lfu", "volatile-random", "volatile-ttl",
+}
+
+// redisUserPattern matches Redis ACL user names the setup accepts
+var redisUserPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// Redis configures the Redis server used for the cache, sessions and queues
+type Redis struct {
+	// Host is a Redis server elsewhere, e.g. a managed one, used instead of installing redis-server here
+	Host string
+	// Port is the TCP port of the server; defaults to 6379
+	Port int
+	// Password authenticates with the server; the local server requires it when it is set
+	Password string
+	// User is the ACL user Laravel authenticates as, with Password (Redis 6 and later)
+	// On the local server it replaces the default user, which is turned off
+	User string
+	// TLS connects to Host over TLS, which most managed Redis services require
+	TLS bool
+
+	// The settings below apply to the server installed on this host
+
+	// MaxMemoryMB caps the memory of the local server; defaults to the RAM reserved for Redis in [Memory]
+	MaxMemoryMB int
+	// EvictionPolicy is the maxmemory-policy used once MaxMemoryMB is reached; defaults to "allkeys-lru"
+	// Queues stored in Redis can lose jobs to an allkeys policy; "volatile-lru" or "noeviction" keep them
+	EvictionPolicy string
+	// Socket makes the local server listen on RedisSocket only, instead of TCP on 127.0.0.1
+	Socket bool
+	// Persistence is RedisPersistRDB (default), RedisPersistAOF or RedisPersistNone
+	Persistence string
+}
+
+// External reports whether Redis runs elsewhere rather than on this host
+func (r Redis) External() bool {
+	return r.Host != ""
+}
+
+// HostName returns the host Laravel connects to, or the socket path of a local server listening on one
+func (r Redis) HostName() string {
+	switch {
+	case r.External():
+		return r.Host
+	case r.Socket:
+		return RedisSocket
+	}
+	return "127.0.0.1"
+}
+
+// PortNumber returns the TCP port of the server: Port, or 6379
+func (r Redis) PortNumber() int {
+	if r.Port != 0 {
+		return r.Port
+	}
+	return 6379
+}
+
+// Policy returns the eviction policy of the local server, defaulting to allkeys-lru
+func (r Redis) Policy() string {
+	if r.EvictionPolicy == "" {
+		return "allkeys-lru"
+	}
+	return r.EvictionPolicy
+}
+
+// PersistenceMode returns how the local server keeps its data, defaulting to RDB snapshots
+func (r Redis) PersistenceMode() string {
+	if r.Persistence == "" {
+		return RedisPersistRDB
+	}
+	return r.Persistence
+}
+
+// MaxMemory returns the memory cap of the local server in MiB, given the RAM reserved for Redis
+func (r Redis) MaxMemory(reservedMB int) int {
+	if r.MaxMemoryMB != 0 {
+		return r.MaxMemoryMB
+	}
+	return reservedMB
+}
+
+// Validate checks the Redis settings
+func (r Redis) Validate() error {
+	if r.Port < 0 || r.Port > 65535 {
+		return fmt.Errorf("redis: Port %d is out of range", r.Port)
+	}
+	if r.External() && !hostPattern.MatchString(r.Host) {
+		return fmt.Errorf("redis: Host %q is not a host name or IP address", r.Host)
+	}
+	if r.User != "" {
+		if !redisUserPattern.MatchString(r.User) || r.User == "default" {
+			return fmt.Errorf("redis: User %q is not a valid ACL user name", r.User)
+		}
+		if r.Password == "" {
+			return fmt.Errorf("redis: User %s needs a Password", r.User)
+		}
+	}
+	if r.External() {
+		if r.Socket || r.MaxMemoryMB != 0 || r.EvictionPolicy != "" || r.Persistence != "" {
+			return fmt.Errorf("redis: Socket, MaxMemoryMB, EvictionPolicy and Persistence configure a local server and can't be combined with Host")
+		}
+		return nil
+	}
+
+	if r.TLS {
+		return fmt.Errorf("redis: TLS needs a Redis server in Host")
+	}
+	if r.MaxMemoryMB < 0 {
+		return fmt.Errorf("redis: MaxMemoryMB must not be negative")
+	}
+	if r.Socket && r.Port != 0 {
+		return fmt.Errorf("redis: Port can't be combined with Socket, which turns TCP off")
+	}
+	switch r.PersistenceMode() {
+	case RedisPersistRDB, RedisPersistAOF, RedisPersistNone:
+	default:
+		return fmt.Errorf("redis: Persistence %q must be %q, %q or %q", r.Persistence, RedisPersistRDB, RedisPersistAOF, RedisPersistNone)
+	}
+
+	for _, policy := range redisEvictionPolicies {
+		if r.Policy() == policy {
+			return nil
+		}
+	}
+	return fmt.Errorf("redis: unsupported EvictionPolicy %q, expected one of %s", r.EvictionPolicy, strings.Join(redisEvictionPolicies, ", "))
+}
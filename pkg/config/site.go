@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Site is an application hosted on the server with its own Nginx vhost
+type Site struct {
+	Domain  string
+	WebRoot string
+	// PHPVersion is the PHP version serving the site, defaulting to the top-level PHPVersion
+	PHPVersion PHPVersion
+	// FPM configures the site's PHP-FPM pool, defaulting to the top-level FPM settings
+	FPM FPMPool
+}
+
+// AllSites returns every site hosted on the server with defaults applied
+// The primary site described by the top-level settings comes first
+func (c *Config) AllSites() []Site {
+	primary := Site{Domain: c.Domain, WebRoot: c.WebRoot, PHPVersion: c.PHPVersion}
+	primary.FPM = c.FPM.withDefaults(defaultFPMPool(c.WebUser, primary.WebRoot))
+	sites := []Site{primary}
+
+	for _, site := range c.Sites {
+		if site.WebRoot == "" {
+			site.WebRoot = "/var/www/" + site.Domain
+		}
+		if site.PHPVersion == "" {
+			site.PHPVersion = c.PHPVersion
+		}
+		// Each additional site runs as its own user unless one is set for the site or under [FPM]
+		site.FPM = site.FPM.withDefaults(c.FPM.withDefaults(defaultFPMPool(siteAccount(site.Domain), site.WebRoot)))
+		sites = append(sites, site)
+	}
+
+	return sites
+}
+
+// FindSite returns the site with the given domain
+func (c *Config) FindSite(domain string) (Site, bool) {
+	for _, site := range c.AllSites() {
+		if site.Domain == domain {
+			return site, true
+		}
+	}
+	return Site{}, false
+}
+
+// IsPrimarySite reports whether domain is the site described by the top-level settings
+func (c *Config) IsPrimarySite(domain string) bool {
+	return domain == c.Domain
+}
+
+// AllPHPVersions returns every PHP version to install, sorted and without duplicates
+// This is the top-level PHPVersion, the extra PHPVersions and the version of each site
+func (c *Config) AllPHPVersions() []PHPVersion {
+	seen := map[PHPVersion]bool{}
+	var versions []PHPVersion
+
+	add := func(v PHPVersion) {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			versions = append(versions, v)
+		}
+	}
+
+	add(c.PHPVersion)
+	for _, v := range c.PHPVersions {
+		add(v)
+	}
+	for _, site := range c.AllSites() {
+		add(site.PHPVersion)
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// PrimarySite returns the site described by the top-level settings with defaults applied
+func (c *Config) PrimarySite() Site {
+	return c.AllSites()[0]
+}
+
+// validateSites checks the PHP versions, the additional sites and their PHP-FPM pools
+func (c *Config) validateSites() error {
+	if err := c.PHPVersion.Validate(); err != nil {
+		return err
+	}
+	for _, v := range c.PHPVersions {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("PHPVersions: %w", err)
+		}
+	}
+
+	seen := map[string]bool{c.Domain: true}
+	for i, site := range c.Sites {
+		if site.Domain == "" {
+			return fmt.Errorf("site %d has no Domain", i+1)
+		}
+		if strings.ContainsAny(site.Domain, "/ \t") {
+			return fmt.Errorf("invalid site domain %q", site.Domain)
+		}
+		if seen[site.Domain] {
+			return fmt.Errorf("site %s is defined more than once", site.Domain)
+		}
+		seen[site.Domain] = true
+
+		if site.PHPVersion != "" {
+			if err := site.PHPVersion.Validate(); err != nil {
+				return fmt.Errorf("site %s: %w", site.Domain, err)
+			}
+		}
+	}
+
+	for _, site := range c.AllSites() {
+		if err := site.FPM.Validate(); err != nil {
+			return fmt.Errorf("PHP-FPM pool of %s: %w", site.Domain, err)
+		}
+	}
+
+	m := c.Memory
+	if m.FPMWorker < 0 || m.Database < 0 || m.Redis < 0 || m.System < 0 {
+		return fmt.Errorf("memory settings must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package config
+
+import "fmt"
+
+// workersPerCPU caps the PHP-FPM workers of all sites combined, so a host with a lot of RAM
+// but few cores doesn't spawn more workers than it can schedule
+const workersPerCPU = 8
+
+// Host describes the resources of the server the pools are sized for
+type Host struct {
+	// MemoryMB is the total RAM in MiB, as reported by /proc/meminfo
+	MemoryMB int
+	// CPUs is the number of CPU cores
+	CPUs int
+}
+
+// Memory sets aside RAM for the services sharing the host with PHP-FPM
+// Values are in MiB; zero picks a default sized from the host's total memory
+type Memory struct {
+	// FPMWorker is the average memory used by one PHP-FPM worker
+	FPMWorker int
+	// Database, Redis and System are reserved before the rest is shared between the PHP-FPM pools
+	Database int
+	Redis    int
+	System   int
+}
+
+// Resolve returns the memory settings with defaults for a host with totalMB of RAM
+func (m Memory) Resolve(totalMB int) Memory {
+	if m.FPMWorker == 0 {
+		m.FPMWorker = 64
+	}
+	if m.Database == 0 {
+		m.Database = totalMB / 4
+	}
+	if m.Redis == 0 {
+		m.Redis = clamp(totalMB/16, 64, 1024)
+	}
+	if m.System == 0 {
+		m.System = 256
+		if totalMB >= 2048 {
+			m.System = 512
+		}
+	}
+	return m
+}
+
+// ResolveMemory returns the memory settings for a host with totalMB of RAM
+// Nothing is reserved for a database or Redis server that is managed elsewhere
+func (c *Config) ResolveMemory(totalMB int) Memory {
+	memory := c.Memory.Resolve(totalMB)
+	if c.Database.External() {
+		memory.Database = 0
+	}
+	if c.Redis.External() {
+		memory.Redis = 0
+	}
+	return memory
+}
+
+// FPMBudget returns the RAM in MiB left for PHP-FPM after the reservations
+func (m Memory) FPMBudget(totalMB int) int {
+	return totalMB - m.Database - m.Redis - m.System
+}
+
+// SizedSites returns every site with the PHP-FPM process manager values that aren't set
+// computed from the host's RAM and CPU count
+// The RAM left after the reservations in Memory is split evenly between the automatically sized pools,
+// after subtracting the workers of pools whose MaxChildren is set
+func (c *Config) SizedSites(host Host) []Site {
+	sites := c.AllSites()
+	memory := c.ResolveMemory(host.MemoryMB)
+
+	total := memory.FPMBudget(host.MemoryMB) / memory.FPMWorker
+	if limit := host.CPUs * workersPerCPU; host.CPUs > 0 && total > limit {
+		total = limit
+	}
+
+	auto := 0
+	for _, site := range sites {
+		if site.FPM.MaxChildren == 0 {
+			auto++
+		} else {
+			total -= site.FPM.MaxChildren
+		}
+	}
+
+	perSite := 1
+	if auto > 0 && total/auto > 1 {
+		perSite = total / auto
+	}
+
+	for i := range sites {
+		sites[i].FPM = sites[i].FPM.sized(perSite, host.CPUs)
+	}
+	return sites
+}
+
+// sized fills in the process manager values that aren't set
+// maxChildren is used when MaxChildren isn't set; spare servers follow the CPU count
+func (p FPMPool) sized(maxChildren, cpus int) FPMPool {
+	if p.MaxChildren == 0 {
+		p.MaxChildren = maxChildren
+	}
+	if cpus < 1 {
+		cpus = 1
+	}
+
+	if p.MinSpareServers == 0 {
+		p.MinSpareServers = clamp(p.MaxChildren/4, 1, cpus)
+	}
+	if p.MaxSpareServers == 0 {
+		p.MaxSpareServers = clamp(p.MaxChildren/2, p.MinSpareServers, cpus*2)
+	}
+	if p.StartServers == 0 {
+		p.StartServers = (p.MinSpareServers + p.MaxSpareServers) / 2
+	}
+
+	// Keep the dynamic process manager's ordering when only some values were set
+	if p.MaxSpareServers > p.MaxChildren {
+		p.MaxSpareServers = p.MaxChildren
+	}
+	p.MinSpareServers = clamp(p.MinSpareServers, 1, p.MaxSpareServers)
+	p.StartServers = clamp(p.StartServers, p.MinSpareServers, p.MaxSpareServers)
+
+	return p
+}
+
+// Describe summarizes the process manager settings of a pool, e.g. for the plan output
+func (p FPMPool) Describe() string {
+	switch p.PM {
+	case PMDynamic:
+		return fmt.Sprintf("pm = dynamic, max_children = %d, start_servers = %d, min_spare_servers = %d, max_spare_servers = %d",
+			p.MaxChildren, p.StartServers, p.MinSpareServers, p.MaxSpareServers)
+	default:
+		return fmt.Sprintf("pm = %s, max_children = %d", p.PM, p.MaxChildren)
+	}
+}
+
+// clamp limits v to the range [lo, hi]; lo wins if the range is empty
+func clamp(v, lo, hi int) int {
+	if v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
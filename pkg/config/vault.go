@@ -0,0 +1,35 @@
+package config
+
+import "fmt"
+
+// How the credentials vault is unlocked
+const (
+	// VaultHostKey unlocks the vault with a random key only root can read, so unattended runs work
+	VaultHostKey = "host-key"
+	// VaultPassphrase unlocks the vault with a passphrase, read from LARAVEL_SETUP_VAULT_PASSPHRASE or asked for
+	VaultPassphrase = "passphrase"
+)
+
+// Vault configures the encrypted file holding the secrets the setup generates or is given
+type Vault struct {
+	// Unlock is VaultHostKey (default) or VaultPassphrase
+	// Changing it re-encrypts the vault the next time it is saved
+	Unlock string
+}
+
+// UnlockMode returns how the vault is unlocked, defaulting to the host key
+func (v Vault) UnlockMode() string {
+	if v.Unlock == "" {
+		return VaultHostKey
+	}
+	return v.Unlock
+}
+
+// Validate checks the vault settings
+func (v Vault) Validate() error {
+	switch v.UnlockMode() {
+	case VaultHostKey, VaultPassphrase:
+		return nil
+	}
+	return fmt.Errorf("vault: Unlock %q must be %q or %q", v.Unlock, VaultHostKey, VaultPassphrase)
+}
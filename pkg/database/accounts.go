@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+	"laravel-setup/pkg/vault"
+)
+
+// mysqlPrivileges are the privileges each grant profile has on the application database
+// The admin profile is granted every privilege on the server instead
+var mysqlPrivileges = map[string]string{
+	config.ProfileApp:      "SELECT, INSERT, UPDATE, DELETE, CREATE TEMPORARY TABLES, LOCK TABLES, EXECUTE",
+	config.ProfileMigrate:  "SELECT, INSERT, UPDATE, DELETE, CREATE, ALTER, DROP, INDEX, REFERENCES, CREATE TEMPORARY TABLES, LOCK TABLES, CREATE VIEW, SHOW VIEW, TRIGGER, EXECUTE, CREATE ROUTINE, ALTER ROUTINE, EVENT",
+	config.ProfileReadOnly: "SELECT, SHOW VIEW",
+}
+
+// credential is an account with its password
+type credential struct {
+	config.Account
+	Password string
+}
+
+// templateAccounts converts credentials for the setup script templates
+func templateAccounts(creds []credential) []templates.DatabaseAccount {
+	accounts := make([]templates.DatabaseAccount, 0, len(creds))
+	for _, c := range creds {
+		accounts = append(accounts, templates.DatabaseAccount{Profile: c.Profile, User: c.User, Password: c.Password})
+	}
+	return accounts
+}
+
+// PasswordSecret returns the name of an account's password in the vault
+func PasswordSecret(user string) string {
+	return "database/" + user
+}
+
+// legacyPasswordsPath returns the file earlier versions kept the generated account passwords in
+func legacyPasswordsPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".laravel-setup", "database-passwords.json"), nil
+}
+
+// openVault opens the credentials vault
+// Passwords left in the plain file of earlier versions are moved into it, and the file deleted
+func openVault(ctx context.Context, cfg *config.Config) (*vault.Vault, error) {
+	v, err := vault.Open(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := legacyPasswordsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	passwords := map[string]string{}
+	if err := json.Unmarshal(data, &passwords); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for user, password := range passwords {
+		if _, ok := v.Get(PasswordSecret(user)); !ok {
+			v.Set(PasswordSecret(user), password)
+		}
+	}
+	if err := v.Save(ctx); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+	utils.PrintStatus("Moved the database passwords from " + path + " into the vault " + v.Path())
+	return v, nil
+}
+
+// credentials returns every database account with its password, as kept in the vault
+// On first use the application account takes DBPassword and the others get a generated password,
+// saved in the vault so later runs and deploys use the same ones
+func credentials(ctx context.Context, cfg *config.Config) ([]credential, error) {
+	v, err := openVault(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	var creds []credential
+	for _, account := range cfg.DatabaseAccounts() {
+		password, ok := v.Get(PasswordSecret(account.User))
+		if !ok {
+			password = cfg.DBPassword
+			if account.Profile != config.ProfileApp {
+				if password, err = utils.GeneratePassword(); err != nil {
+					return nil, err
+				}
+			}
+			v.Set(PasswordSecret(account.User), password)
+			changed = true
+		}
+		creds = append(creds, credential{Account: account, Password: password})
+	}
+
+	if changed {
+		if err := v.Save(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return creds, nil
+}
+
+// AppPassword returns the password of the application account, as Laravel should use it in .env
+func AppPassword(ctx context.Context, cfg *config.Config) (string, error) {
+	creds, err := credentials(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	return creds[0].Password, nil
+}
+
+// migrator returns the account that changes the schema
+// Without a migration account, as on a managed server without admin credentials, DBUser migrates
+func migrator(creds []credential) credential {
+	for _, c := range creds {
+		if c.Profile == config.ProfileMigrate {
+			return c
+		}
+	}
+	return creds[0]
+}
+
+// Migrate runs the Laravel migrations with the migration account
+func Migrate(ctx context.Context, cfg *config.Config, phpBinary, artisan string) error {
+	return runAsMigrator(ctx, cfg, phpBinary, artisan, "migrate", "--force")
+}
+
+// Seed runs a Laravel seeder with the migration account, which may also truncate the tables it fills
+func Seed(ctx context.Context, cfg *config.Config, phpBinary, artisan, class string) error {
+	return runAsMigrator(ctx, cfg, phpBinary, artisan, "db:seed", "--class="+class, "--force")
+}
+
+// runAsMigrator runs an artisan command with the migration account
+// The credentials override those in .env, and a cached configuration is ignored so they take effect
+func runAsMigrator(ctx context.Context, cfg *config.Config, phpBinary, artisan string, args ...string) error {
+	creds, err := credentials(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	m := migrator(creds)
+	env := []string{
+		"DB_USERNAME=" + m.User,
+		"DB_PASSWORD=" + m.Password,
+		// Laravel reads the cached configuration from this path; a file that can't exist disables it
+		"APP_CONFIG_CACHE=/dev/null/config.php",
+	}
+	return utils.RunCommandWithEnv(ctx, env, phpBinary, append([]string{artisan}, args...)...)
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"laravel-setup/pkg/utils"
+)
+
+// rootOptionFile holds the root password for the mysql client when root authenticates with a password,
+// or the managed server and its admin account
+// It is only readable by root, so the password never appears on a command line
+const rootOptionFile = "/etc/mysql/laravel-setup-root.cnf"
+
+// mysqlClient returns the mysql client command run as root, with the root option file when there is one
+func mysqlClient(ctx context.Context, args ...string) []string {
+	return mysqlClientCommand(ctx, "mysql", args...)
+}
+
+// mysqlClientCommand returns a MySQL client program such as mysql or mysqldump run as root,
+// with the root option file when there is one
+// The option file is harmless while root still uses socket authentication, which ignores the password
+func mysqlClientCommand(ctx context.Context, program string, args ...string) []string {
+	cmd := []string{program}
+	if _, err := utils.RunCommandWithOutput(ctx, "sudo", "test", "-f", rootOptionFile); err == nil {
+		// --defaults-extra-file must be the first option
+		cmd = append(cmd, "--defaults-extra-file="+rootOptionFile)
+	}
+	return append(cmd, args...)
+}
+
+// mysqlQuery runs a query as root and returns its rows with tab separated columns
+func mysqlQuery(ctx context.Context, query string) ([][]string, error) {
+	output, err := utils.RunCommandWithOutput(ctx, "sudo", mysqlClient(ctx, "--batch", "--raw", "--skip-column-names", "-e", query)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, line := range strings.Split(output, "\n") {
+		if line != "" {
+			rows = append(rows, strings.Split(line, "\t"))
+		}
+	}
+	return rows, nil
+}
+
+// mysqlExec runs SQL statements as root, passing them on stdin
+func mysqlExec(ctx context.Context, sql string) error {
+	return utils.RunCommandWithInput(ctx, []byte(backslashEscapes+"\n"+sql), "sudo", mysqlClient(ctx)...)
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"context"
+
+	"laravel-setup/pkg/config"
+)
+
+// DumpCommand returns the command, run as root, that writes a consistent SQL dump of the database to stdout
+// MySQL and MariaDB dump InnoDB tables in a single transaction, so the application keeps running
+func DumpCommand(ctx context.Context, cfg *config.Config) []string {
+	if cfg.Database.EngineName() == config.EnginePostgres {
+		// pg_dump always reads from a single snapshot
+		return postgresClient(cfg, "pg_dump", cfg.DBName, "--clean", "--if-exists")
+	}
+
+	args := []string{"--single-transaction", "--quick", "--routines", "--triggers", "--no-tablespaces"}
+	// Without the provider's admin account, a managed server is dumped as DBUser, which can't read events
+	if !cfg.Database.External() || cfg.Database.HostAdminUser != "" {
+		args = append(args, "--events")
+	}
+	return append([]string{"sudo"}, mysqlClientCommand(ctx, "mysqldump", append(args, cfg.DBName)...)...)
+}
+
+// RestoreCommand returns the command, run as root, that loads an SQL dump from stdin into the database
+func RestoreCommand(ctx context.Context, cfg *config.Config) []string {
+	if cfg.Database.EngineName() == config.EnginePostgres {
+		return postgresClient(cfg, "psql", cfg.DBName, "-X", "-q", "-v", "ON_ERROR_STOP=1")
+	}
+	return append([]string{"sudo"}, mysqlClientCommand(ctx, "mysql", cfg.DBName)...)
+}
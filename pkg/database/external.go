@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// installExternal prepares a managed database server (Amazon RDS, DigitalOcean...) for Laravel
+// Only the client is installed here. With the provider's admin account, the database and accounts are
+// created on the server; without it they must exist, and the application account is checked instead
+func installExternal(ctx context.Context, cfg *config.Config) error {
+	db := cfg.Database
+	name := db.DisplayName()
+	server := db.Host + ":" + strconv.Itoa(db.PortNumber())
+
+	// Validate the names before touching anything
+	if err := validateIdentifier("database", cfg.DBName, maxDatabaseName); err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+	for _, account := range cfg.DatabaseAccounts() {
+		if err := validateIdentifier("user", account.User, maxUserName); err != nil {
+			return fmt.Errorf("invalid database configuration: %w", err)
+		}
+	}
+
+	utils.PrintHeader("Connecting to Managed " + name)
+	utils.PrintStatus("Installing the " + name + " client...")
+	packages := []string{"postgresql-client"}
+	if db.IsMySQL() {
+		packages = mysqlFlavors[db.EngineName()].ClientPackages
+	}
+	if err := utils.RunCommand(ctx, "sudo", append([]string{"apt", "install", "-y"}, packages...)...); err != nil {
+		return err
+	}
+
+	if db.SSLCA != "" {
+		if _, err := utils.RunCommandWithOutput(ctx, "test", "-r", db.SSLCA); err != nil {
+			return fmt.Errorf("the CA certificate %s of %s can't be read", db.SSLCA, server)
+		}
+	}
+
+	creds, err := credentials(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	app := creds[0]
+
+	// The tool connects as the admin account when there is one, e.g. for backups, and as DBUser otherwise
+	adminUser, adminPassword := db.HostAdminUser, db.HostAdminPassword
+	if adminUser == "" {
+		adminUser, adminPassword = app.User, app.Password
+	}
+
+	utils.PrintStatus("Saving the connection to " + server + " for root...")
+	if db.IsMySQL() {
+		options := templates.GetMySQLClientOptions(db.Host, db.PortNumber(), adminUser, adminPassword, db.SSLCA, db.EngineName() == config.EngineMariaDB)
+		err = utils.WriteSystemFile(ctx, rootOptionFile, options, 0600)
+	} else {
+		service := templates.GetPostgresService(pgService, db.Host, db.PortNumber(), adminUser, adminPassword, db.SSLCA)
+		err = utils.WriteSystemFile(ctx, pgServiceFile, service, 0600)
+	}
+	if err != nil {
+		return err
+	}
+
+	if db.HostAdminUser != "" {
+		utils.PrintStatus("Connecting to " + server + " as " + db.HostAdminUser + "...")
+		utils.PrintStatus("Creating database: " + cfg.DBName)
+		for _, account := range cfg.DatabaseAccounts() {
+			utils.PrintStatus("Creating " + account.Profile + " user: " + account.User)
+		}
+
+		if db.IsMySQL() {
+			// Build the script before touching the server, so an invalid name changes nothing
+			script := mysqlSetupScript(cfg.DBName, "%", creds)
+			if err := script.Err(); err != nil {
+				return fmt.Errorf("invalid database configuration: %w", err)
+			}
+			err = mysqlExec(ctx, script.String())
+		} else {
+			roles := map[string]templates.DatabaseAccount{}
+			for _, account := range templateAccounts(creds) {
+				roles[account.Profile] = account
+			}
+			err = psql(ctx, cfg, templates.GetPostgresConfig(cfg.DBName, roles[config.ProfileApp], roles[config.ProfileMigrate],
+				roles[config.ProfileReadOnly], roles[config.ProfileAdmin], ""))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create the database and accounts on %s: %w", server, err)
+		}
+	}
+
+	// Connect the way Laravel will, so a firewall, TLS or password problem shows up now rather than at the first request
+	utils.PrintStatus("Checking that " + app.User + " can connect to " + cfg.DBName + " on " + server + "...")
+	if err := checkConnection(ctx, cfg, app); err != nil {
+		return fmt.Errorf("%s can't connect to %s on %s: %w", app.User, cfg.DBName, server, err)
+	}
+	utils.PrintStatus(name + " at " + server + " is ready")
+
+	return saveCredentials(ctx, cfg, creds)
+}
+
+// checkConnection logs in to the managed server with an account and runs a query in the Laravel database
+// The password is passed in the environment, so it doesn't show up in the process list
+func checkConnection(ctx context.Context, cfg *config.Config, c credential) error {
+	db := cfg.Database
+	port := strconv.Itoa(db.PortNumber())
+
+	if !db.IsMySQL() {
+		conninfo := "host=" + db.Host + " port=" + port + " dbname=" + cfg.DBName + " user=" + c.User
+		if db.SSLCA != "" {
+			conninfo += " sslmode=verify-full sslrootcert=" + db.SSLCA
+		}
+		return utils.RunCommandWithEnv(ctx, []string{"PGPASSWORD=" + c.Password}, "psql", "-X", "-q", "-c", "SELECT 1", conninfo)
+	}
+
+	// --no-defaults keeps the user's option files from overriding the account
+	args := []string{"--no-defaults", "--host=" + db.Host, "--port=" + port, "--user=" + c.User}
+	if db.SSLCA != "" {
+		args = append(args, "--ssl-ca="+db.SSLCA)
+		if db.EngineName() == config.EngineMariaDB {
+			args = append(args, "--ssl-verify-server-cert")
+		} else {
+			args = append(args, "--ssl-mode=VERIFY_IDENTITY")
+		}
+	}
+	args = append(args, "--batch", "-e", "SELECT 1", cfg.DBName)
+	return utils.RunCommandWithEnv(ctx, []string{"MYSQL_PWD=" + c.Password}, "mysql", args...)
+}
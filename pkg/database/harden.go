@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// localHosts are the hosts root may connect from
+var localHosts = map[string]bool{"localhost": true, "127.0.0.1": true, "::1": true}
+
+// socketPlugins are the authentication plugins that log in the matching system user over the socket
+var socketPlugins = map[string]bool{"auth_socket": true, "unix_socket": true}
+
+// hardenMySQL does what mysql_secure_installation does, without prompting
+// Every check is reported, and running it again only changes what drifted
+func hardenMySQL(ctx context.Context, cfg *config.Config) error {
+	if err := removeAnonymousUsers(ctx); err != nil {
+		return err
+	}
+	if err := removeRemoteRoot(ctx); err != nil {
+		return err
+	}
+	if err := removeTestDatabase(ctx); err != nil {
+		return err
+	}
+	// The policy comes first so the root password is checked against it too
+	if err := configurePasswordPolicy(ctx, cfg.Database); err != nil {
+		return err
+	}
+	return configureRootAuth(ctx, cfg.Database, cfg.DBRootPassword)
+}
+
+// removeAnonymousUsers drops the accounts with an empty user name
+func removeAnonymousUsers(ctx context.Context) error {
+	rows, err := mysqlQuery(ctx, "SELECT Host FROM mysql.user WHERE User = ''")
+	if err != nil {
+		return fmt.Errorf("failed to list anonymous users: %w", err)
+	}
+	if len(rows) == 0 {
+		utils.PrintStatus("No anonymous users")
+		return nil
+	}
+
+	for _, row := range rows {
+		if err := mysqlExec(ctx, "DROP USER IF EXISTS "+account("", row[0])+";"); err != nil {
+			return err
+		}
+		utils.PrintStatus("Removed anonymous user ''@'" + row[0] + "'")
+	}
+	return nil
+}
+
+// removeRemoteRoot drops the root accounts that can log in from other hosts
+func removeRemoteRoot(ctx context.Context) error {
+	rows, err := mysqlQuery(ctx, "SELECT Host FROM mysql.user WHERE User = 'root'")
+	if err != nil {
+		return fmt.Errorf("failed to list root accounts: %w", err)
+	}
+
+	removed := 0
+	for _, row := range rows {
+		if localHosts[row[0]] {
+			continue
+		}
+		if err := mysqlExec(ctx, "DROP USER IF EXISTS "+account("root", row[0])+";"); err != nil {
+			return err
+		}
+		utils.PrintStatus("Removed remote root login 'root'@'" + row[0] + "'")
+		removed++
+	}
+	if removed == 0 {
+		utils.PrintStatus("Root can only log in locally")
+	}
+	return nil
+}
+
+// removeTestDatabase drops the test database and the privileges granted on test databases
+func removeTestDatabase(ctx context.Context) error {
+	rows, err := mysqlQuery(ctx, "SHOW DATABASES LIKE 'test'")
+	if err != nil {
+		return fmt.Errorf("failed to list databases: %w", err)
+	}
+	if len(rows) > 0 {
+		if err := mysqlExec(ctx, "DROP DATABASE IF EXISTS test;"); err != nil {
+			return err
+		}
+		utils.PrintStatus("Removed the test database")
+	} else {
+		utils.PrintStatus("No test database")
+	}
+
+	// Some packages grant everyone access to test and test_* databases
+	grants, err := mysqlQuery(ctx, `SELECT User, Host, Db FROM mysql.db WHERE Db = 'test' OR Db LIKE 'test\\_%'`)
+	if err != nil {
+		return fmt.Errorf("failed to list test database privileges: %w", err)
+	}
+	for _, grant := range grants {
+		user, host, db := grant[0], grant[1], grant[2]
+		if err := mysqlExec(ctx, "REVOKE ALL PRIVILEGES ON "+quoteIdent(db)+".* FROM "+account(user, host)+";"); err != nil {
+			return err
+		}
+		utils.PrintStatus("Revoked privileges on " + db + " from '" + user + "'@'" + host + "'")
+	}
+	return nil
+}
+
+// configureRootAuth restricts root to the socket, or to a password kept in the root option file
+func configureRootAuth(ctx context.Context, db config.Database, password string) error {
+	rows, err := mysqlQuery(ctx, "SELECT plugin FROM mysql.user WHERE User = 'root' AND Host = 'localhost'")
+	if err != nil {
+		return fmt.Errorf("failed to read root authentication: %w", err)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("'root'@'localhost' doesn't exist")
+	}
+	plugin := rows[0][0]
+	mariadb := db.EngineName() == config.EngineMariaDB
+
+	if db.RootAuthMode() == config.RootAuthSocket {
+		if socketPlugins[plugin] {
+			utils.PrintStatus("Root uses socket authentication")
+		} else {
+			statement := "ALTER USER 'root'@'localhost' IDENTIFIED WITH auth_socket;"
+			if mariadb {
+				statement = "ALTER USER 'root'@'localhost' IDENTIFIED VIA unix_socket;"
+			}
+			if err := mysqlExec(ctx, statement); err != nil {
+				return err
+			}
+			utils.PrintStatus("Switched root to socket authentication")
+		}
+
+		// The password is no longer needed to connect
+		return utils.RunCommand(ctx, "sudo", "rm", "-f", rootOptionFile)
+	}
+
+	// The queries above logged in with the option file, so when root already uses a password
+	// and the file holds this one, it authenticates and nothing drifted
+	options := templates.GetMySQLRootOptions(password)
+	if !socketPlugins[plugin] {
+		current, err := utils.RunCommandWithOutput(ctx, "sudo", "cat", rootOptionFile)
+		if err == nil && current == strings.TrimSpace(options) {
+			utils.PrintStatus("Root uses password authentication")
+			return nil
+		}
+	}
+
+	// The new option file is staged next to the current one and only replaces it once the server
+	// accepted the password, so a rejected password leaves the tool able to connect
+	staged := rootOptionFile + ".new"
+	if err := utils.WriteSystemFile(ctx, staged, options, 0600); err != nil {
+		return err
+	}
+
+	statement := "ALTER USER 'root'@'localhost' IDENTIFIED WITH caching_sha2_password BY " + quoteString(password) + ";"
+	if mariadb {
+		statement = "ALTER USER 'root'@'localhost' IDENTIFIED VIA mysql_native_password USING PASSWORD(" + quoteString(password) + ");"
+	}
+	if err := mysqlExec(ctx, statement); err != nil {
+		_ = utils.RunCommand(ctx, "sudo", "rm", "-f", staged)
+		return fmt.Errorf("failed to set the root password: %w", err)
+	}
+	if err := utils.RunCommand(ctx, "sudo", "mv", staged, rootOptionFile); err != nil {
+		return fmt.Errorf("the root password was changed, but %s couldn't replace %s: %w", staged, rootOptionFile, err)
+	}
+
+	if socketPlugins[plugin] {
+		utils.PrintStatus("Switched root to password authentication")
+	} else {
+		utils.PrintStatus("Updated the root password")
+	}
+	return nil
+}
+
+// configurePasswordPolicy installs the validate_password component and sets its policy
+// MariaDB has no validate_password component, so the policy only applies to MySQL
+func configurePasswordPolicy(ctx context.Context, db config.Database) error {
+	policy := db.PasswordPolicyLevel()
+	if db.EngineName() == config.EngineMariaDB {
+		if policy != "OFF" {
+			utils.PrintWarning("Password policy " + policy + " isn't applied: validate_password is only available in MySQL")
+		}
+		return nil
+	}
+
+	rows, err := mysqlQuery(ctx, "SELECT COUNT(*) FROM mysql.component WHERE component_urn = 'file://component_validate_password'")
+	if err != nil {
+		return fmt.Errorf("failed to check the validate_password component: %w", err)
+	}
+	installed := len(rows) > 0 && rows[0][0] != "0"
+
+	if policy == "OFF" {
+		if installed {
+			if err := mysqlExec(ctx, "UNINSTALL COMPONENT 'file://component_validate_password';"); err != nil {
+				return err
+			}
+			utils.PrintStatus("Removed the password policy")
+		} else {
+			utils.PrintStatus("No password policy")
+		}
+		return nil
+	}
+
+	if !installed {
+		if err := mysqlExec(ctx, "INSTALL COMPONENT 'file://component_validate_password';"); err != nil {
+			return err
+		}
+		utils.PrintStatus("Installed the validate_password component")
+	}
+
+	rows, err = mysqlQuery(ctx, "SELECT @@GLOBAL.validate_password.policy")
+	if err != nil {
+		return fmt.Errorf("failed to read the password policy: %w", err)
+	}
+	if len(rows) > 0 && rows[0][0] == policy {
+		utils.PrintStatus("Password policy is " + policy)
+		return nil
+	}
+
+	// SET PERSIST keeps the policy across restarts without editing the configuration files
+	if err := mysqlExec(ctx, "SET PERSIST validate_password.policy = "+quoteString(policy)+";"); err != nil {
+		return err
+	}
+	utils.PrintStatus("Set password policy to " + policy)
+	return nil
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// importScript pipes a dump into a restore command through pv, which shows the progress
+// pv measures the file as it is read, so a compressed dump's progress is of the compressed bytes
+const importScript = `set -o pipefail
+in=$1; shift
+case "$in" in
+*.gz) pv -f "$in" | gunzip -c | "$@" ;;
+*) pv -f "$in" | "$@" ;;
+esac`
+
+// Empty reports whether the Laravel database has no tables yet
+func Empty(ctx context.Context, cfg *config.Config) (bool, error) {
+	var count string
+	var err error
+	if cfg.Database.IsMySQL() {
+		var rows [][]string
+		rows, err = mysqlQuery(ctx, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = "+quoteString(cfg.DBName))
+		if err == nil && len(rows) == 1 {
+			count = rows[0][0]
+		}
+	} else {
+		cmd := postgresClient(cfg, "psql", cfg.DBName, "-X", "-A", "-t", "-c",
+			"SELECT count(*) FROM pg_tables WHERE schemaname = 'public'")
+		count, err = utils.RunCommandWithOutput(ctx, cmd[0], cmd[1:]...)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to list the tables of %s: %w", cfg.DBName, err)
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return false, fmt.Errorf("failed to count the tables of %s: unexpected output %q", cfg.DBName, count)
+	}
+	return n == 0, nil
+}
+
+// Import loads the SQL dump in Import.Source into the Laravel database, downloading it first when it is a URL
+// The dump is loaded by the tool's own connection; on PostgreSQL the tables are created as the
+// migration account, which owns the schema, so the application account gets its privileges on them
+func Import(ctx context.Context, cfg *config.Config) error {
+	source := cfg.Import.Source
+	db := cfg.Database
+	if db.External() && db.HostAdminUser == "" {
+		return fmt.Errorf("importing into %s needs HostAdminUser; %s can't create tables", db.Host, cfg.DBUser)
+	}
+
+	utils.PrintHeader("Importing " + source)
+	if _, err := utils.RunCommandWithOutput(ctx, "which", "pv"); err != nil {
+		utils.PrintStatus("Installing pv to show the import progress...")
+		if err := utils.RunCommand(ctx, "sudo", "apt", "install", "-y", "pv"); err != nil {
+			return err
+		}
+	}
+
+	path := source
+	if cfg.Import.IsURL() {
+		// The suffix tells the script whether to decompress
+		suffix := ".sql"
+		if cfg.Import.Compressed() {
+			suffix = ".sql.gz"
+		}
+		tmp, err := os.CreateTemp("", "laravel-setup-import-*"+suffix)
+		if err != nil {
+			return err
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+
+		utils.PrintStatus("Downloading " + source + "...")
+		if err := utils.RunCommand(ctx, "curl", "-fL", "--progress-bar", "-o", tmp.Name(), source); err != nil {
+			return fmt.Errorf("failed to download %s: %w", source, err)
+		}
+		path = tmp.Name()
+	}
+
+	restore := RestoreCommand(ctx, cfg)
+	if !db.IsMySQL() {
+		creds, err := credentials(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		// The user names are validated, so they can be quoted without escaping
+		owner := migrator(creds).User
+		restore = postgresClient(cfg, "psql", cfg.DBName, "-X", "-q", "-v", "ON_ERROR_STOP=1",
+			"-c", `SET ROLE "`+owner+`"`, "-f", "-")
+	}
+
+	utils.PrintStatus("Loading " + source + " into " + cfg.DBName + "...")
+	err := utils.RunCommand(ctx, "sudo", append([]string{"bash", "-c", importScript, "bash", path}, restore...)...)
+	if err != nil {
+		return fmt.Errorf("failed to import %s into %s: %w", source, cfg.DBName, err)
+	}
+
+	utils.PrintStatus(source + " imported into " + cfg.DBName)
+	return nil
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// Install installs and configures the configured database engine for Laravel,
+// or prepares a managed server when Host is set
+func Install(ctx context.Context, cfg *config.Config) error {
+	if cfg.Database.External() {
+		return installExternal(ctx, cfg)
+	}
+	if err := resolveRootPassword(ctx, cfg); err != nil {
+		return err
+	}
+	if cfg.Database.EngineName() == config.EnginePostgres {
+		return installPostgres(ctx, cfg)
+	}
+	return installMySQL(ctx, cfg)
+}
+
+// legacyCredentialsFiles are the plain-text credentials files earlier versions wrote to the user's home directory
+var legacyCredentialsFiles = []string{"mysql_credentials.txt", "postgres_credentials.txt"}
+
+// Names of the server's own secrets in the vault
+const (
+	rootSecret      = "database/root"
+	hostAdminSecret = "database/host-admin"
+)
+
+// resolveRootPassword fills in DBRootPassword when it isn't configured: the password kept in the vault
+// by an earlier run, or a generated one, so running the setup again doesn't change it
+func resolveRootPassword(ctx context.Context, cfg *config.Config) error {
+	if cfg.DBRootPassword != "" {
+		return nil
+	}
+	v, err := openVault(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if password, ok := v.Get(rootSecret); ok {
+		cfg.DBRootPassword = password
+		return nil
+	}
+	password, err := utils.GeneratePassword()
+	if err != nil {
+		return err
+	}
+	cfg.DBRootPassword = password
+	return nil
+}
+
+// saveCredentials keeps the server's passwords in the vault, next to the accounts' own, and writes the
+// client file that logs the user in to the Laravel database as the application account
+// The plain-text credentials files of earlier versions are deleted
+func saveCredentials(ctx context.Context, cfg *config.Config, creds []credential) error {
+	db := cfg.Database
+
+	v, err := openVault(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	switch {
+	case db.External():
+		if db.HostAdminPassword != "" {
+			v.Set(hostAdminSecret, db.HostAdminPassword)
+		}
+	case db.EngineName() == config.EnginePostgres || db.RootAuthMode() == config.RootAuthPassword:
+		v.Set(rootSecret, cfg.DBRootPassword)
+	default:
+		// root logs in through the socket and has no password
+		v.Delete(rootSecret)
+	}
+	if err := v.Save(ctx); err != nil {
+		return err
+	}
+	utils.PrintStatus(db.DisplayName() + " passwords saved in the vault; see laravel-setup secrets list")
+
+	if err := writeClientFile(cfg, creds[0]); err != nil {
+		return err
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	for _, name := range legacyCredentialsFiles {
+		path := filepath.Join(homeDir, name)
+		if err := os.Remove(path); err == nil {
+			utils.PrintStatus("Deleted the plain-text credentials file ~/" + name)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeClientFile writes ~/.my.cnf or ~/.pgpass for an account, readable by the user only
+// A file the user wrote is left alone
+func writeClientFile(cfg *config.Config, c credential) error {
+	db := cfg.Database
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(homeDir, db.ClientFile())
+
+	existing, err := os.ReadFile(path)
+	if err == nil && !strings.HasPrefix(string(existing), templates.ManagedHeader) {
+		utils.PrintWarning("~/" + db.ClientFile() + " wasn't written by laravel-setup, leaving it alone")
+		return nil
+	}
+
+	var content string
+	switch {
+	case db.IsMySQL() && db.External():
+		content = templates.GetMySQLUserOptions(db.Host, db.PortNumber(), c.User, c.Password, cfg.DBName, db.SSLCA, db.EngineName() == config.EngineMariaDB)
+	case db.IsMySQL():
+		content = templates.GetMySQLUserOptions("localhost", 0, c.User, c.Password, cfg.DBName, "", false)
+	case db.External():
+		content = templates.GetPgpass(db.Host, db.PortNumber(), cfg.DBName, c.User, c.Password)
+	default:
+		// Any host, so the file works over the socket and over TCP
+		content = templates.GetPgpass("*", db.PortNumber(), cfg.DBName, c.User, c.Password)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	utils.PrintStatus("Saved the " + c.User + " login to ~/" + db.ClientFile())
+	return nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/system"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// mysqlFlavor describes the packages and paths that differ between MySQL and MariaDB
+type mysqlFlavor struct {
+	Packages []string
+	// ClientPackages are installed alone when the server is managed elsewhere
+	ClientPackages []string
+	// ConfigDir is the server's drop-in directory, read after the package's own configuration
+	ConfigDir string
+}
+
+// mysqlFlavors maps the MySQL protocol engines to their Ubuntu packages
+var mysqlFlavors = map[string]mysqlFlavor{
+	config.EngineMySQL: {
+		Packages:       []string{"mysql-server", "mysql-client"},
+		ClientPackages: []string{"mysql-client"},
+		ConfigDir:      "/etc/mysql/mysql.conf.d",
+	},
+	config.EngineMariaDB: {
+		Packages:       []string{"mariadb-server", "mariadb-client"},
+		ClientPackages: []string{"mariadb-client"},
+		ConfigDir:      "/etc/mysql/mariadb.conf.d",
+	},
+}
+
+// installMySQL installs and configures MySQL or MariaDB for Laravel
+func installMySQL(ctx context.Context, cfg *config.Config) error {
+	name := cfg.Database.DisplayName()
+	flavor := mysqlFlavors[cfg.Database.EngineName()]
+
+	utils.PrintHeader("Installing " + name)
+	utils.PrintStatus("Installing " + name + " server and client...")
+
+	// Install the server and client
+	err := utils.RunCommand(ctx, "sudo", append([]string{"apt", "install", "-y"}, flavor.Packages...)...)
+	if err != nil {
+		return err
+	}
+
+	utils.PrintStatus(name + " installed successfully")
+
+	// Tune the server for Laravel
+	utils.PrintStatus("Writing " + name + " configuration...")
+	host, err := system.DetectHost()
+	if err != nil {
+		return err
+	}
+	tuning := cfg.MySQLTuning(host)
+	utils.PrintStatus("Sizing " + name + " for " + tuning.Describe())
+
+	err = utils.WriteSystemFile(ctx, flavor.ConfigDir+"/99-laravel-setup.cnf", templates.GetMySQLTuning(templates.MySQLTuning{
+		MariaDB:           cfg.Database.EngineName() == config.EngineMariaDB,
+		BufferPoolMB:      tuning.BufferPoolMB,
+		RedoLogMB:         tuning.RedoLogMB,
+		MaxConnections:    tuning.MaxConnections,
+		LongQueryTime:     tuning.SlowQueryTime.Seconds(),
+		PerformanceSchema: tuning.PerformanceSchema,
+	}), 0644)
+	if err != nil {
+		return err
+	}
+
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", cfg.Database.Service())
+	if err != nil {
+		return err
+	}
+
+	// Secure the installation
+	utils.PrintHeader("Securing " + name + " Installation")
+	if err := hardenMySQL(ctx, cfg); err != nil {
+		return err
+	}
+
+	// Configure the database for Laravel
+	utils.PrintHeader("Configuring " + name + " for Laravel")
+	utils.PrintStatus("Configuring " + name + " database and user...")
+	utils.PrintStatus("Creating database: " + cfg.DBName)
+	for _, account := range cfg.DatabaseAccounts() {
+		utils.PrintStatus("Creating " + account.Profile + " user: " + account.User)
+	}
+
+	creds, err := credentials(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	// Build the script before touching the server, so an invalid name changes nothing
+	script := mysqlSetupScript(cfg.DBName, "localhost", creds)
+	if err := script.Err(); err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+
+	// The script is passed on stdin, so the passwords never touch the disk or the process list
+	if err := mysqlExec(ctx, script.String()); err != nil {
+		return err
+	}
+
+	utils.PrintStatus(name + " configured successfully")
+
+	// Save credentials securely
+	return saveCredentials(ctx, cfg, creds)
+}
+
+// legacyAdminUser is the admin account earlier versions always created with every privilege
+const legacyAdminUser = "admin"
+
+// mysqlSetupScript returns the script that creates the Laravel database and accounts logging in from host
+// Accounts are created or have their password reset and their privileges replaced by their profile's,
+// so the script can run again
+func mysqlSetupScript(dbName, host string, creds []credential) *sqlScript {
+	script := newSQLScript(host)
+
+	// Create database with UTF-8 support for Laravel
+	script.CreateDatabase(dbName)
+
+	admin := false
+	for _, c := range creds {
+		script.CreateUser(c.User, c.Password)
+		script.RevokeAll(c.User)
+		if c.Profile == config.ProfileAdmin {
+			script.Grant("ALL PRIVILEGES", "*", c.User, true)
+			admin = admin || c.User == legacyAdminUser
+		} else {
+			script.Grant(mysqlPrivileges[c.Profile], dbName, c.User, false)
+		}
+	}
+
+	// The admin account is opt-in now; don't leave the one earlier versions created on a local server
+	if !admin && host == "localhost" {
+		script.DropUser(legacyAdminUser)
+	}
+
+	return script
+}
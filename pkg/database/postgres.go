@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/system"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// Connection service of a managed PostgreSQL server, holding its admin account's password
+// The file is only readable by root, so the password never appears on a command line
+const (
+	pgServiceFile = "/etc/postgresql-common/laravel-setup-service.conf"
+	pgService     = "laravel-setup"
+)
+
+// postgresClient returns a PostgreSQL client program such as psql or pg_dump, run as the superuser
+// and connected to a database: the postgres user over the local socket, or the managed server's
+// admin account through the connection service
+func postgresClient(cfg *config.Config, program, dbName string, args ...string) []string {
+	if cfg.Database.External() {
+		cmd := append([]string{"sudo", "env", "PGSERVICEFILE=" + pgServiceFile, program}, args...)
+		return append(cmd, "service="+pgService+" dbname="+dbName)
+	}
+	cmd := append([]string{"sudo", "-u", "postgres", program}, args...)
+	return append(cmd, dbName)
+}
+
+// psql runs SQL as the superuser
+// The SQL is passed on stdin so passwords don't show up in the process list
+func psql(ctx context.Context, cfg *config.Config, sql string) error {
+	cmd := postgresClient(cfg, "psql", "postgres", "-X", "-q", "-v", "ON_ERROR_STOP=1")
+	return utils.RunCommandWithInput(ctx, []byte(sql), cmd[0], cmd[1:]...)
+}
+
+// installPostgres installs and configures PostgreSQL for Laravel
+func installPostgres(ctx context.Context, cfg *config.Config) error {
+	// Names are quoted in the script, but are checked like MySQL's so they stay usable in .env
+	if err := validateIdentifier("database", cfg.DBName, maxDatabaseName); err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+	for _, account := range cfg.DatabaseAccounts() {
+		if err := validateIdentifier("user", account.User, maxUserName); err != nil {
+			return fmt.Errorf("invalid database configuration: %w", err)
+		}
+	}
+
+	utils.PrintHeader("Installing PostgreSQL")
+	utils.PrintStatus("Installing PostgreSQL server and client...")
+
+	err := utils.RunCommand(ctx, "sudo", "apt", "install", "-y", "postgresql", "postgresql-contrib")
+	if err != nil {
+		return err
+	}
+
+	utils.PrintStatus("PostgreSQL installed successfully")
+
+	// Tune the server for Laravel
+	// The conf.d directory next to postgresql.conf is included by the Ubuntu packages
+	configFile, err := utils.RunCommandWithOutput(ctx, "sudo", "-u", "postgres", "psql", "-X", "-A", "-t", "-c", "SHOW config_file")
+	if err != nil {
+		return err
+	}
+
+	host, err := system.DetectHost()
+	if err != nil {
+		return err
+	}
+	reserved := cfg.ResolveMemory(host.MemoryMB).Database
+
+	utils.PrintStatus("Writing PostgreSQL configuration...")
+	tuning := filepath.Join(filepath.Dir(configFile), "conf.d", "99-laravel-setup.conf")
+	if err := utils.WriteSystemFile(ctx, tuning, templates.GetPostgresTuning(reserved), 0644); err != nil {
+		return err
+	}
+
+	// shared_buffers only changes on a restart
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", cfg.Database.Service())
+	if err != nil {
+		return err
+	}
+
+	// Configure the database for Laravel
+	utils.PrintHeader("Configuring PostgreSQL for Laravel")
+	utils.PrintStatus("Creating database: " + cfg.DBName)
+	for _, account := range cfg.DatabaseAccounts() {
+		utils.PrintStatus("Creating " + account.Profile + " user: " + account.User)
+	}
+
+	creds, err := credentials(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	roles := map[string]templates.DatabaseAccount{}
+	for _, account := range templateAccounts(creds) {
+		roles[account.Profile] = account
+	}
+
+	err = psql(ctx, cfg, templates.GetPostgresConfig(cfg.DBName, roles[config.ProfileApp], roles[config.ProfileMigrate],
+		roles[config.ProfileReadOnly], roles[config.ProfileAdmin], cfg.DBRootPassword))
+	if err != nil {
+		return err
+	}
+
+	utils.PrintStatus("PostgreSQL configured successfully")
+
+	// Save credentials securely
+	return saveCredentials(ctx, cfg, creds)
+}
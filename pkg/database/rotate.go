@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// Rotation is a password change of a database account made by RotatePassword
+// The new password is in effect and saved; Commit completes the change, Revert undoes it
+type Rotation struct {
+	Profile  string
+	User     string
+	Password string
+
+	config   *config.Config
+	previous string
+	// retained is set when MySQL still accepts the previous password as a secondary one
+	retained bool
+}
+
+// RotatePassword gives the account of a grant profile a new generated password
+// The vault and the client file are updated at once, so the password isn't lost if a later step fails
+// On MySQL the previous password keeps working until Commit, so running clients aren't locked out meanwhile
+func RotatePassword(ctx context.Context, cfg *config.Config, profile string) (*Rotation, error) {
+	db := cfg.Database
+	if db.External() && db.HostAdminUser == "" {
+		return nil, fmt.Errorf("passwords on %s can only be changed with HostAdminUser set; "+
+			"otherwise change the password with the provider and update DBPassword", db.Host)
+	}
+
+	creds, err := credentials(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *credential
+	profiles := make([]string, 0, len(creds))
+	for i := range creds {
+		profiles = append(profiles, creds[i].Profile)
+		if creds[i].Profile == profile {
+			target = &creds[i]
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("there is no %s account; the configured accounts are %v", profile, profiles)
+	}
+
+	password, err := utils.GeneratePassword()
+	if err != nil {
+		return nil, err
+	}
+	r := &Rotation{
+		Profile:  target.Profile,
+		User:     target.User,
+		Password: password,
+		config:   cfg,
+		previous: target.Password,
+		retained: db.EngineName() == config.EngineMySQL,
+	}
+
+	utils.PrintStatus("Changing the password of " + r.User + "...")
+	if err := r.apply(ctx, r.Password, r.retained, false); err != nil {
+		return nil, fmt.Errorf("failed to change the password of %s: %w", r.User, err)
+	}
+
+	if err := r.save(ctx, r.Password); err != nil {
+		if rerr := r.apply(ctx, r.previous, false, r.retained); rerr != nil {
+			return nil, fmt.Errorf("%w; putting the previous password back failed too: %v", err, rerr)
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// Commit completes the rotation, so the previous password stops working
+func (r *Rotation) Commit(ctx context.Context) error {
+	if !r.retained {
+		return nil
+	}
+	utils.PrintStatus("Discarding the previous password of " + r.User + "...")
+	return r.apply(ctx, "", false, true)
+}
+
+// Revert puts the previous password back on the server and in the vault
+func (r *Rotation) Revert(ctx context.Context) error {
+	utils.PrintStatus("Putting the previous password of " + r.User + " back...")
+	if err := r.apply(ctx, r.previous, false, r.retained); err != nil {
+		return err
+	}
+	return r.save(ctx, r.previous)
+}
+
+// Previous returns the password the account had before the rotation
+func (r *Rotation) Previous() string {
+	return r.previous
+}
+
+// apply changes the account's password on the server, when password is set, and discards its
+// secondary MySQL password when discard is set
+func (r *Rotation) apply(ctx context.Context, password string, retain, discard bool) error {
+	if !r.config.Database.IsMySQL() {
+		return psql(ctx, r.config, templates.GetPostgresPasswordChange(r.User, password))
+	}
+
+	host := "localhost"
+	if r.config.Database.External() {
+		host = "%"
+	}
+	script := newSQLScript(host)
+	if password != "" {
+		script.ChangePassword(r.User, password, retain)
+	}
+	if discard {
+		script.DiscardOldPassword(r.User)
+	}
+	if err := script.Err(); err != nil {
+		return err
+	}
+	return mysqlExec(ctx, script.String())
+}
+
+// save stores a password of the account in the vault and rewrites the client file with it
+func (r *Rotation) save(ctx context.Context, password string) error {
+	v, err := openVault(ctx, r.config)
+	if err != nil {
+		return err
+	}
+	v.Set(PasswordSecret(r.User), password)
+	if err := v.Save(ctx); err != nil {
+		return err
+	}
+
+	creds, err := credentials(ctx, r.config)
+	if err != nil {
+		return err
+	}
+	return saveCredentials(ctx, r.config, creds)
+}
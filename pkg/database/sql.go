@@ -0,0 +1,188 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Limits of MySQL names; MariaDB allows longer user names, MySQL's limit is used for both
+const (
+	maxDatabaseName = 64
+	maxUserName     = 32
+)
+
+// backslashEscapes makes the session treat backslashes in string literals as escapes, as quoteString expects
+const backslashEscapes = "SET SESSION sql_mode = REPLACE(@@SESSION.sql_mode, 'NO_BACKSLASH_ESCAPES', '');"
+
+// identifierPattern matches the database and user names the setup creates
+// Names are quoted anyway; the pattern keeps them usable in .env and shell commands
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// validateIdentifier checks a database or user name before it is used in SQL
+func validateIdentifier(kind, name string, max int) error {
+	if name == "" {
+		return fmt.Errorf("%s name is empty", kind)
+	}
+	if len(name) > max {
+		return fmt.Errorf("%s name %q is longer than %d characters", kind, name, max)
+	}
+	if !identifierPattern.MatchString(name) {
+		return fmt.Errorf("%s name %q may only contain letters, digits and underscores", kind, name)
+	}
+	return nil
+}
+
+// quoteString quotes a MySQL string literal
+// Backslashes are escaped too; mysqlExec turns off NO_BACKSLASH_ESCAPES so this holds for any sql_mode
+func quoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`, "\n", `\n`, "\r", `\r`, "\x1a", `\Z`).Replace(value) + "'"
+}
+
+// quoteIdent quotes a MySQL identifier such as a database name
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// account returns the quoted 'user'@'host' name of an account
+func account(user, host string) string {
+	return quoteString(user) + "@" + quoteString(host)
+}
+
+// sqlScript builds a MySQL script from validated names and escaped values
+// The first error stops the script from being built; String must not be used when Err is set
+type sqlScript struct {
+	statements []string
+	err        error
+	// host is the host part of the accounts the script manages
+	host string
+}
+
+// newSQLScript starts an empty script managing accounts that log in from host,
+// "localhost" for a local server or "%" for a managed one
+func newSQLScript(host string) *sqlScript {
+	return &sqlScript{host: host}
+}
+
+// fail records the first error
+func (s *sqlScript) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// add appends a statement
+func (s *sqlScript) add(statement string) {
+	s.statements = append(s.statements, statement+";")
+}
+
+// CreateDatabase creates a UTF-8 database if it doesn't exist
+func (s *sqlScript) CreateDatabase(name string) {
+	if err := validateIdentifier("database", name, maxDatabaseName); err != nil {
+		s.fail(err)
+		return
+	}
+	s.add("CREATE DATABASE IF NOT EXISTS " + quoteIdent(name) + " CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci")
+}
+
+// CreateUser creates an account, or resets the password of an existing one
+func (s *sqlScript) CreateUser(user, password string) {
+	if err := validateIdentifier("user", user, maxUserName); err != nil {
+		s.fail(err)
+		return
+	}
+	if password == "" {
+		s.fail(fmt.Errorf("password of user %q is empty", user))
+		return
+	}
+	s.add("CREATE USER IF NOT EXISTS " + account(user, s.host) + " IDENTIFIED BY " + quoteString(password))
+	s.add("ALTER USER " + account(user, s.host) + " IDENTIFIED BY " + quoteString(password))
+}
+
+// ChangePassword changes the password of an existing account
+// With retain, MySQL keeps the current password as a secondary one, so clients that haven't picked up
+// the new password can still log in until DiscardOldPassword; MariaDB has no secondary passwords
+func (s *sqlScript) ChangePassword(user, password string, retain bool) {
+	if err := validateIdentifier("user", user, maxUserName); err != nil {
+		s.fail(err)
+		return
+	}
+	if password == "" {
+		s.fail(fmt.Errorf("password of user %q is empty", user))
+		return
+	}
+	statement := "ALTER USER " + account(user, s.host) + " IDENTIFIED BY " + quoteString(password)
+	if retain {
+		statement += " RETAIN CURRENT PASSWORD"
+	}
+	s.add(statement)
+}
+
+// DiscardOldPassword drops the secondary password ChangePassword retained
+func (s *sqlScript) DiscardOldPassword(user string) {
+	if err := validateIdentifier("user", user, maxUserName); err != nil {
+		s.fail(err)
+		return
+	}
+	s.add("ALTER USER " + account(user, s.host) + " DISCARD OLD PASSWORD")
+}
+
+// DropUser drops an account if it exists
+func (s *sqlScript) DropUser(user string) {
+	if err := validateIdentifier("user", user, maxUserName); err != nil {
+		s.fail(err)
+		return
+	}
+	s.add("DROP USER IF EXISTS " + account(user, s.host))
+}
+
+// RevokeAll revokes every privilege of an account, at every level
+// Used before granting a profile, so privileges left from an earlier profile don't linger
+func (s *sqlScript) RevokeAll(user string) {
+	if err := validateIdentifier("user", user, maxUserName); err != nil {
+		s.fail(err)
+		return
+	}
+	s.add("REVOKE ALL PRIVILEGES, GRANT OPTION FROM " + account(user, s.host))
+}
+
+// privilegePattern matches a privilege list such as "SELECT, INSERT" or "ALL PRIVILEGES"
+var privilegePattern = regexp.MustCompile(`^[A-Z ]+(, [A-Z ]+)*$`)
+
+// Grant grants privileges on a database, or on every database when database is "*"
+func (s *sqlScript) Grant(privileges, database, user string, grantOption bool) {
+	if !privilegePattern.MatchString(privileges) {
+		s.fail(fmt.Errorf("invalid privileges %q", privileges))
+		return
+	}
+	if err := validateIdentifier("user", user, maxUserName); err != nil {
+		s.fail(err)
+		return
+	}
+
+	on := "*.*"
+	if database != "*" {
+		if err := validateIdentifier("database", database, maxDatabaseName); err != nil {
+			s.fail(err)
+			return
+		}
+		// _ and % are wildcards in the database name of a grant; escaped, the grant covers this database only
+		on = quoteIdent(strings.NewReplacer("_", `\_`, "%", `\%`).Replace(database)) + ".*"
+	}
+
+	statement := "GRANT " + privileges + " ON " + on + " TO " + account(user, s.host)
+	if grantOption {
+		statement += " WITH GRANT OPTION"
+	}
+	s.add(statement)
+}
+
+// Err returns the first invalid name or value passed to the script
+func (s *sqlScript) Err() error {
+	return s.err
+}
+
+// String returns the script, one statement per line
+func (s *sqlScript) String() string {
+	return strings.Join(s.statements, "\n") + "\n"
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuoteString(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", "secret", `'secret'`},
+		{"empty", "", `''`},
+		{"single quote", "it's", `'it\'s'`},
+		{"quote breakout", "x'; DROP DATABASE laravel; --", `'x\'; DROP DATABASE laravel; --'`},
+		{"backslash", `a\b`, `'a\\b'`},
+		{"backslash before quote", `\'`, `'\\\''`},
+		{"trailing backslash", `abc\`, `'abc\\'`},
+		{"nul", "a\x00b", `'a\0b'`},
+		{"newline", "a\nb", `'a\nb'`},
+		{"carriage return", "a\rb", `'a\rb'`},
+		{"ctrl-z", "a\x1ab", `'a\Zb'`},
+		{"double quote and backtick", "a\"b`c", "'a\"b`c'"},
+		{"percent and underscore", "100%_x", `'100%_x'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteString(tt.value); got != tt.want {
+				t.Errorf("quoteString(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"laravel", "`laravel`"},
+		{"a`b", "`a``b`"},
+		{"`; DROP DATABASE x; --", "```; DROP DATABASE x; --`"},
+		{"a%b_c", "`a%b_c`"},
+	}
+	for _, tt := range tests {
+		if got := quoteIdent(tt.name); got != tt.want {
+			t.Errorf("quoteIdent(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateIdentifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		max     int
+		wantErr string
+	}{
+		{"valid", "laravel_db", maxDatabaseName, ""},
+		{"digits", "db2024", maxDatabaseName, ""},
+		{"at limit", strings.Repeat("u", maxUserName), maxUserName, ""},
+		{"over limit", strings.Repeat("u", maxUserName+1), maxUserName, "longer than 32"},
+		{"database over limit", strings.Repeat("d", maxDatabaseName+1), maxDatabaseName, "longer than 64"},
+		{"empty", "", maxUserName, "is empty"},
+		{"backtick", "a`b", maxUserName, "may only contain"},
+		{"percent", "a%", maxUserName, "may only contain"},
+		{"quote", "a'b", maxUserName, "may only contain"},
+		{"space", "a b", maxUserName, "may only contain"},
+		{"hyphen", "a-b", maxUserName, "may only contain"},
+		{"nul", "a\x00b", maxUserName, "may only contain"},
+		{"newline", "ab\n", maxUserName, "may only contain"},
+		{"non-ascii", "usér", maxUserName, "may only contain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIdentifier("user", tt.value, tt.max)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateIdentifier(%q) = %v, want nil", tt.value, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateIdentifier(%q) = %v, want an error containing %q", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSQLScriptStatements(t *testing.T) {
+	hostile := "p'w\\d\x00\n\r\x1a`%_"
+	quoted := `'p\'w\\d\0\n\r\Z` + "`%_'"
+
+	tests := []struct {
+		name  string
+		build func(*sqlScript)
+		host  string
+		want  []string
+	}{
+		{
+			name:  "create database",
+			build: func(s *sqlScript) { s.CreateDatabase("laravel_db") },
+			host:  "localhost",
+			want:  []string{"CREATE DATABASE IF NOT EXISTS `laravel_db` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;"},
+		},
+		{
+			name:  "create user with hostile password",
+			build: func(s *sqlScript) { s.CreateUser("laravel", hostile) },
+			host:  "localhost",
+			want: []string{
+				"CREATE USER IF NOT EXISTS 'laravel'@'localhost' IDENTIFIED BY " + quoted + ";",
+				"ALTER USER 'laravel'@'localhost' IDENTIFIED BY " + quoted + ";",
+			},
+		},
+		{
+			name:  "change password retaining the current one",
+			build: func(s *sqlScript) { s.ChangePassword("laravel", hostile, true) },
+			host:  "%",
+			want:  []string{"ALTER USER 'laravel'@'%' IDENTIFIED BY " + quoted + " RETAIN CURRENT PASSWORD;"},
+		},
+		{
+			name:  "change password",
+			build: func(s *sqlScript) { s.ChangePassword("laravel", "pw", false) },
+			host:  "localhost",
+			want:  []string{"ALTER USER 'laravel'@'localhost' IDENTIFIED BY 'pw';"},
+		},
+		{
+			name:  "discard old password",
+			build: func(s *sqlScript) { s.DiscardOldPassword("laravel") },
+			host:  "localhost",
+			want:  []string{"ALTER USER 'laravel'@'localhost' DISCARD OLD PASSWORD;"},
+		},
+		{
+			name: "revoke and grant on a database with wildcard characters",
+			build: func(s *sqlScript) {
+				s.RevokeAll("laravel_ro")
+				s.Grant("SELECT, SHOW VIEW", "laravel_db", "laravel_ro", false)
+			},
+			host: "localhost",
+			want: []string{
+				"REVOKE ALL PRIVILEGES, GRANT OPTION FROM 'laravel_ro'@'localhost';",
+				"GRANT SELECT, SHOW VIEW ON `laravel\\_db`.* TO 'laravel_ro'@'localhost';",
+			},
+		},
+		{
+			name:  "grant on every database",
+			build: func(s *sqlScript) { s.Grant("ALL PRIVILEGES", "*", "admin", true) },
+			host:  "localhost",
+			want:  []string{"GRANT ALL PRIVILEGES ON *.* TO 'admin'@'localhost' WITH GRANT OPTION;"},
+		},
+		{
+			name:  "drop user",
+			build: func(s *sqlScript) { s.DropUser("admin") },
+			host:  "localhost",
+			want:  []string{"DROP USER IF EXISTS 'admin'@'localhost';"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSQLScript(tt.host)
+			tt.build(s)
+			if err := s.Err(); err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+			want := strings.Join(tt.want, "\n") + "\n"
+			if got := s.String(); got != want {
+				t.Errorf("script =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestSQLScriptRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   func(*sqlScript)
+		wantErr string
+	}{
+		{"database with backtick", func(s *sqlScript) { s.CreateDatabase("a`b") }, "may only contain"},
+		{"database with percent", func(s *sqlScript) { s.CreateDatabase("a%") }, "may only contain"},
+		{"database too long", func(s *sqlScript) { s.CreateDatabase(strings.Repeat("d", 65)) }, "longer than 64"},
+		{"user with quote", func(s *sqlScript) { s.CreateUser("a'b", "pw") }, "may only contain"},
+		{"user too long", func(s *sqlScript) { s.CreateUser(strings.Repeat("u", 33), "pw") }, "longer than 32"},
+		{"empty password", func(s *sqlScript) { s.CreateUser("laravel", "") }, "password of user"},
+		{"empty rotated password", func(s *sqlScript) { s.ChangePassword("laravel", "", false) }, "password of user"},
+		{"injected privileges", func(s *sqlScript) { s.Grant("SELECT; DROP DATABASE x", "db", "u", false) }, "invalid privileges"},
+		{"grant on hostile database", func(s *sqlScript) { s.Grant("SELECT", "db`x", "u", false) }, "may only contain"},
+		{"drop hostile user", func(s *sqlScript) { s.DropUser("u%") }, "may only contain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSQLScript("localhost")
+			tt.build(s)
+			if err := s.Err(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Err() = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if len(s.statements) != 0 {
+				t.Errorf("statements = %q, want none after an invalid name", s.statements)
+			}
+		})
+	}
+}
+
+func TestSQLScriptKeepsFirstError(t *testing.T) {
+	s := newSQLScript("localhost")
+	s.CreateDatabase("bad`name")
+	s.CreateUser("bad'user", "pw")
+	s.CreateUser("laravel", "pw")
+	if err := s.Err(); err == nil || !strings.Contains(err.Error(), "database name") {
+		t.Fatalf("Err() = %v, want the database name error", err)
+	}
+}
@@ -0,0 +1,167 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/database"
+	"laravel-setup/pkg/php"
+	"laravel-setup/pkg/state"
+	"laravel-setup/pkg/utils"
+)
+
+// Deploy pulls the latest code for the Laravel application and rebuilds its caches
+// The revision that was live before the deployment is recorded so it can be rolled back
+func Deploy(ctx context.Context, cfg *config.Config) error {
+	utils.PrintHeader("Deploying Laravel Application")
+
+	st, err := state.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	// Remember the revision that is currently live
+	previous, err := currentRevision(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if _, ok := st.CurrentRelease(); !ok {
+		st.AddRelease(previous)
+	}
+
+	// Pull the latest code
+	utils.PrintStatus("Pulling latest code in " + cfg.WebRoot + "...")
+	err = utils.RunCommand(ctx, "git", "-C", cfg.WebRoot, "pull", "--ff-only")
+	if err != nil {
+		return err
+	}
+
+	revision, err := currentRevision(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	if revision == previous {
+		utils.PrintStatus("Already at the latest revision " + shortRevision(revision))
+	}
+
+	if err := refresh(ctx, cfg, true); err != nil {
+		return err
+	}
+
+	if revision != previous {
+		st.AddRelease(revision)
+	}
+	if err := st.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	utils.PrintStatus("Deployed revision " + shortRevision(revision))
+	return nil
+}
+
+// Rollback returns the Laravel application to the revision deployed before the current one
+// Database migrations are not reverted, since that could destroy data
+func Rollback(ctx context.Context, cfg *config.Config) error {
+	utils.PrintHeader("Rolling Back Laravel Application")
+
+	st, err := state.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	previous, ok := st.PreviousRelease()
+	if !ok {
+		return fmt.Errorf("no previous release to roll back to")
+	}
+
+	utils.PrintStatus("Checking out revision " + shortRevision(previous.Revision) + "...")
+	err = utils.RunCommand(ctx, "git", "-C", cfg.WebRoot, "reset", "--hard", previous.Revision)
+	if err != nil {
+		return err
+	}
+
+	if err := refresh(ctx, cfg, false); err != nil {
+		return err
+	}
+
+	st.Releases = st.Releases[:len(st.Releases)-1]
+	if err := st.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	utils.PrintStatus("Rolled back to revision " + shortRevision(previous.Revision))
+	utils.PrintWarning("Database migrations were not reverted")
+	return nil
+}
+
+// refresh reinstalls dependencies, rebuilds Laravel caches, refreshes OPcache and restarts the queue workers
+// The PHP version is the one serving the site, which php switch may have changed
+func refresh(ctx context.Context, cfg *config.Config, migrate bool) error {
+	version := php.ServedVersion(ctx, cfg.PrimarySite())
+
+	// New dependencies may require PHP extensions that aren't installed yet
+	err := php.InstallAppExtensions(ctx, version, cfg.WebRoot)
+	if err != nil {
+		return err
+	}
+
+	// Install Composer dependencies with optimizations for production
+	utils.PrintStatus("Installing Composer dependencies...")
+	err = php.ComposerInstall(ctx, version, cfg.WebRoot)
+	if err != nil {
+		return err
+	}
+
+	artisan := cfg.WebRoot + "/artisan"
+
+	if migrate {
+		utils.PrintStatus("Running database migrations...")
+		err = database.Migrate(ctx, cfg, version.Binary(), artisan)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Rebuild the framework caches for the new code
+	utils.PrintStatus("Rebuilding Laravel caches...")
+	for _, cache := range []string{"config:cache", "route:cache", "view:cache"} {
+		err = utils.RunCommand(ctx, version.Binary(), artisan, cache)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Without timestamp validation OPcache keeps serving the old code until PHP-FPM is reloaded
+	err = php.ApplyOPcache(ctx, cfg, !cfg.OPcache.ValidateTimestamps)
+	if err != nil {
+		return err
+	}
+
+	// Restart queue workers so they pick up the new code
+	utils.PrintStatus("Restarting queue workers...")
+	err = utils.RunCommand(ctx, "sudo", "supervisorctl", "restart", "laravel-worker:*")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// currentRevision returns the commit currently checked out in the web root
+func currentRevision(ctx context.Context, cfg *config.Config) (string, error) {
+	revision, err := utils.RunCommandWithOutput(ctx, "git", "-C", cfg.WebRoot, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read current revision in %s: %w", cfg.WebRoot, err)
+	}
+	return revision, nil
+}
+
+// shortRevision abbreviates a commit hash for display
+func shortRevision(revision string) string {
+	if len(revision) > 12 {
+		return revision[:12]
+	}
+	return revision
+}
@@ -0,0 +1,142 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// Result is the outcome of a single diagnostic check
+type Result struct {
+	Name    string
+	OK      bool
+	Message string
+}
+
+// check is a single diagnostic that inspects the host or the configuration
+type check struct {
+	name string
+	fn   func(context.Context, *config.Config) (string, error)
+}
+
+// checks lists every diagnostic run by Run, in display order
+var checks = []check{
+	{"Not running as root", checkNotRoot},
+	{"Sudo privileges", checkSudo},
+	{"Ubuntu operating system", checkUbuntu},
+	{"Required commands", checkCommands},
+	{"Configuration", checkConfig},
+}
+
+// Run executes every diagnostic check and prints the results
+// Returns an error if any check failed
+func Run(ctx context.Context, cfg *config.Config) ([]Result, error) {
+	utils.PrintHeader("Checking Host Prerequisites")
+
+	var results []Result
+	failed := 0
+
+	for _, c := range checks {
+		msg, err := c.fn(ctx, cfg)
+		result := Result{Name: c.name, OK: err == nil, Message: msg}
+		if err != nil {
+			result.Message = err.Error()
+			failed++
+			utils.PrintError(c.name + ": " + result.Message)
+		} else {
+			utils.PrintStatus(c.name + ": " + result.Message)
+		}
+		results = append(results, result)
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+
+	utils.PrintStatus("All checks passed")
+	return results, nil
+}
+
+// checkNotRoot verifies that the tool is run by a regular user
+func checkNotRoot(ctx context.Context, _ *config.Config) (string, error) {
+	output, err := utils.RunCommandWithOutput(ctx, "id", "-u")
+	if err != nil {
+		return "", fmt.Errorf("failed to check user ID: %w", err)
+	}
+	if output == "0" {
+		return "", fmt.Errorf("running as root; use a regular user with sudo privileges")
+	}
+	return "running as " + os.Getenv("USER"), nil
+}
+
+// checkSudo verifies that the current user can use sudo without a password prompt
+func checkSudo(ctx context.Context, _ *config.Config) (string, error) {
+	if !utils.CheckSudoPrivileges(ctx) {
+		return "", fmt.Errorf("no passwordless sudo; add the user to the sudo group: sudo usermod -aG sudo %s", os.Getenv("USER"))
+	}
+	return "ok", nil
+}
+
+// checkUbuntu verifies that the host runs Ubuntu, the only supported distribution
+func checkUbuntu(_ context.Context, _ *config.Config) (string, error) {
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return "", fmt.Errorf("failed to read /etc/os-release: %w", err)
+	}
+
+	var id, name string
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch key {
+		case "ID":
+			id = value
+		case "PRETTY_NAME":
+			name = value
+		}
+	}
+
+	if id != "ubuntu" {
+		return "", fmt.Errorf("unsupported distribution %q", name)
+	}
+	return name, nil
+}
+
+// checkCommands verifies that the commands the setup relies on are installed
+func checkCommands(_ context.Context, _ *config.Config) (string, error) {
+	var missing []string
+	for _, command := range []string{"sudo", "apt", "systemctl", "openssl", "curl", "git"} {
+		if _, err := exec.LookPath(command); err != nil {
+			missing = append(missing, command)
+		}
+	}
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing commands: %s", strings.Join(missing, ", "))
+	}
+	return "ok", nil
+}
+
+// checkConfig verifies that the settings needed for an unattended run are present
+func checkConfig(_ context.Context, cfg *config.Config) (string, error) {
+	var missing []string
+	if cfg.Domain == "" {
+		missing = append(missing, "Domain")
+	}
+	if cfg.RepoURL == "" {
+		missing = append(missing, "RepoURL")
+	}
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("not set (will be prompted for): %s", strings.Join(missing, ", "))
+	}
+	return "domain " + cfg.Domain, nil
+}
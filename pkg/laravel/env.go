@@ -0,0 +1,140 @@
+package laravel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"laravel-setup/pkg/utils"
+)
+
+// envVar is a key and value written to .env
+type envVar struct {
+	Key   string
+	Value string
+}
+
+// envBareValuePattern matches values that don't need quoting in .env
+var envBareValuePattern = regexp.MustCompile(`^[A-Za-z0-9_./:@-]*$`)
+
+// quoteEnvValue quotes a value for .env as read by Laravel's dotenv parser
+// Double quotes are used so escapes work; $ is escaped to stop variable expansion
+func quoteEnvValue(value string) string {
+	if envBareValuePattern.MatchString(value) {
+		return value
+	}
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(value)
+	return `"` + value + `"`
+}
+
+// getEnv returns the value of a key in a .env file, empty if it isn't set
+// Quotes around the value are removed; escapes inside them are not interpreted
+func getEnv(path, key string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	pattern := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(key) + `\s*=\s*(.*?)\s*$`)
+	for _, line := range strings.Split(string(content), "\n") {
+		if match := pattern.FindStringSubmatch(line); match != nil {
+			return strings.Trim(match[1], `"'`), nil
+		}
+	}
+	return "", nil
+}
+
+// setEnv sets keys in a .env file
+// An existing or commented-out line for a key is replaced in place; other keys are appended
+// The file is replaced atomically, so PHP never reads it half written
+func setEnv(ctx context.Context, path string, vars []envVar) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		lines = nil
+	}
+
+	for _, v := range vars {
+		line := v.Key + "=" + quoteEnvValue(v.Value)
+		pattern := regexp.MustCompile(`^\s*(#\s*)?` + regexp.QuoteMeta(v.Key) + `\s*=`)
+
+		// Prefer the active line; fall back to uncommenting the example
+		index := -1
+		for i, existing := range lines {
+			if match := pattern.FindStringSubmatch(existing); match != nil {
+				if match[1] == "" {
+					index = i
+					break
+				}
+				if index == -1 {
+					index = i
+				}
+			}
+		}
+		if index >= 0 {
+			lines[index] = line
+		} else {
+			lines = append(lines, line)
+		}
+	}
+
+	return writeFileAtomic(ctx, path, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// writeFileAtomic replaces a file by renaming a complete copy over it
+// The copy is made in the same directory, so the rename stays on one file system, and keeps the
+// file's mode, owner and group, so the site's pool user can still read it
+func writeFileAtomic(ctx context.Context, path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// The mode is set first, while the copy still belongs to the current user
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if err := chown(ctx, tmp.Name(), int(stat.Uid), int(stat.Gid)); err != nil {
+			return fmt.Errorf("failed to keep the owner of %s: %w", path, err)
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// chown gives a file to a user and group, through sudo when the current user may not
+func chown(ctx context.Context, path string, uid, gid int) error {
+	err := os.Chown(path, uid, gid)
+	if !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	return utils.RunCommand(ctx, "sudo", "chown", strconv.Itoa(uid)+":"+strconv.Itoa(gid), path)
+}
@@ -0,0 +1,62 @@
+package laravel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSetEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := "APP_NAME=Laravel\n# DB_PASSWORD=\nDB_HOST=127.0.0.1\n"
+	if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	vars := []envVar{{"DB_PASSWORD", `p"a$s\w`}, {"DB_HOST", "localhost"}, {"REDIS_HOST", "127.0.0.1"}}
+	if err := setEnv(context.Background(), path, vars); err != nil {
+		t.Fatalf("setEnv() = %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "APP_NAME=Laravel\nDB_PASSWORD=\"p\\\"a\\$s\\\\w\"\nDB_HOST=localhost\nREDIS_HOST=127.0.0.1\n"
+	if string(got) != want {
+		t.Errorf(".env =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteFileAtomicKeepsOwnerAndMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("APP_KEY=\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	// Only root can give the file away, as the site's pool user owns it on a server
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = 65534, 65534
+		if err := os.Chown(path, uid, gid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writeFileAtomic(context.Background(), path, []byte("APP_KEY=base64:x\n")); err != nil {
+		t.Fatalf("writeFileAtomic() = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %04o, want 0640", info.Mode().Perm())
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if int(stat.Uid) != uid || int(stat.Gid) != gid {
+		t.Errorf("owner = %d:%d, want %d:%d", stat.Uid, stat.Gid, uid, gid)
+	}
+}
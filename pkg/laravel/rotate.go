@@ -0,0 +1,113 @@
+package laravel
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/database"
+	"laravel-setup/pkg/php"
+	"laravel-setup/pkg/utils"
+)
+
+// RotateDatabasePassword gives the database account of a grant profile a new password
+// For the application account, the password is written to .env and picked up by PHP-FPM and the
+// queue workers, then the application is checked; if anything fails, the previous password is put back
+func RotateDatabasePassword(ctx context.Context, cfg *config.Config, profile string) error {
+	utils.PrintHeader("Rotating the " + profile + " Database Password")
+
+	rotation, err := database.RotatePassword(ctx, cfg, profile)
+	if err != nil {
+		return err
+	}
+
+	// Only the application account is used by Laravel at runtime
+	if profile != config.ProfileApp {
+		if err := rotation.Commit(ctx); err != nil {
+			return err
+		}
+		utils.PrintStatus("Password of " + rotation.User + " rotated")
+		return nil
+	}
+
+	envPath := cfg.WebRoot + "/.env"
+	original, err := os.ReadFile(envPath)
+	if err == nil {
+		utils.PrintStatus("Updating DB_PASSWORD in " + envPath + "...")
+		err = setEnv(ctx, envPath, []envVar{{"DB_PASSWORD", rotation.Password}})
+		if err == nil {
+			err = reloadApplication(ctx, cfg)
+		}
+		if err == nil {
+			err = checkApplication(ctx, cfg)
+		}
+	}
+
+	if err != nil {
+		utils.PrintError("Rotation failed, rolling back: " + err.Error())
+		if rerr := rollbackRotation(ctx, cfg, rotation, envPath, original); rerr != nil {
+			return fmt.Errorf("%w; rolling back failed too, check %s and the database: %v", err, envPath, rerr)
+		}
+		return fmt.Errorf("the password of %s was not rotated: %w", rotation.User, err)
+	}
+
+	if err := rotation.Commit(ctx); err != nil {
+		return err
+	}
+	utils.PrintStatus("Password of " + rotation.User + " rotated; the application is using it")
+	return nil
+}
+
+// rollbackRotation puts the previous password back on the server, in the store and in .env
+// original is the .env content before the rotation, nil when it couldn't be read
+func rollbackRotation(ctx context.Context, cfg *config.Config, rotation *database.Rotation, envPath string, original []byte) error {
+	if err := rotation.Revert(ctx); err != nil {
+		return err
+	}
+	if original == nil {
+		return nil
+	}
+	if err := writeFileAtomic(ctx, envPath, original); err != nil {
+		return err
+	}
+	return reloadApplication(ctx, cfg)
+}
+
+// reloadApplication makes the running application read .env again
+// The configuration cache is rebuilt, PHP-FPM is reloaded gracefully and the queue workers restarted
+func reloadApplication(ctx context.Context, cfg *config.Config) error {
+	version := php.ServedVersion(ctx, cfg.PrimarySite())
+
+	utils.PrintStatus("Rebuilding the configuration cache...")
+	err := utils.RunCommand(ctx, version.Binary(), cfg.WebRoot+"/artisan", "config:cache")
+	if err != nil {
+		return err
+	}
+
+	utils.PrintStatus("Reloading " + version.FPMService() + "...")
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "reload", version.FPMService())
+	if err != nil {
+		return err
+	}
+
+	utils.PrintStatus("Restarting queue workers...")
+	return utils.RunCommand(ctx, "sudo", "supervisorctl", "restart", "laravel-worker:*")
+}
+
+// checkApplication checks that the application works with the database password in .env
+// PHP-FPM must answer after the reload, and artisan, reading the rebuilt configuration cache,
+// must be able to query the database
+func checkApplication(ctx context.Context, cfg *config.Config) error {
+	utils.PrintStatus("Checking the application...")
+	site := cfg.PrimarySite()
+	if err := php.Ping(ctx, site); err != nil {
+		return fmt.Errorf("PHP-FPM of %s isn't answering: %w", site.Domain, err)
+	}
+
+	_, err := utils.RunCommandWithOutput(ctx, php.ServedVersion(ctx, site).Binary(), cfg.WebRoot+"/artisan", "migrate:status")
+	if err != nil {
+		return fmt.Errorf("the application can't query the database: %w", err)
+	}
+	return nil
+}
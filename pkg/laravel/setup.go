@@ -1,85 +1,98 @@
 package laravel
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"strings"
+	"strconv"
 
 	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/database"
+	"laravel-setup/pkg/php"
 	"laravel-setup/pkg/templates"
 	"laravel-setup/pkg/utils"
+	"laravel-setup/pkg/vault"
 )
 
 // Setup sets up the Laravel application
-func Setup(config *config.Config) error {
+func Setup(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Setting Up Laravel Application")
 
 	// Configure Git and SSH for deployment
-	if err := configureGit(); err != nil {
+	if err := configureGit(ctx); err != nil {
 		return err
 	}
 
 	// Clone the repository
-	if err := cloneRepository(config); err != nil {
+	if err := cloneRepository(ctx, cfg); err != nil {
+		return err
+	}
+
+	// Composer runs on the version serving the site, which php switch may have changed since the last run
+	version := php.ServedVersion(ctx, cfg.PrimarySite())
+
+	// Install the PHP extensions the application requires before Composer checks the platform
+	if err := php.InstallAppExtensions(ctx, version, cfg.WebRoot); err != nil {
 		return err
 	}
 
 	// Install Composer dependencies
-	if err := installDependencies(config); err != nil {
+	if err := installDependencies(ctx, cfg, version); err != nil {
+		return err
+	}
+
+	// Size OPcache for the installed application and enable preloading
+	if err := php.ApplyOPcache(ctx, cfg, false); err != nil {
 		return err
 	}
 
 	// Configure Laravel environment
-	if err := configureEnvironment(config); err != nil {
+	if err := configureEnvironment(ctx, cfg); err != nil {
 		return err
 	}
 
 	// Configure Supervisor for Laravel Queue
-	if err := configureSupervisor(config); err != nil {
+	if err := configureSupervisor(ctx, cfg); err != nil {
 		return err
 	}
 
 	utils.PrintHeader("Laravel Application Setup Complete")
-	utils.PrintStatus("Laravel application has been set up successfully at " + config.WebRoot)
-	utils.PrintStatus("You can now access your application at http://" + config.Domain)
+	utils.PrintStatus("Laravel application has been set up successfully at " + cfg.WebRoot)
+	utils.PrintStatus("You can now access your application at http://" + cfg.Domain)
 	utils.PrintWarning("Remember to set up SSL certificate for HTTPS access")
 
 	return nil
 }
 
 // configureGit configures Git and SSH for deployment
-func configureGit() error {
+func configureGit(ctx context.Context) error {
 	utils.PrintHeader("Configuring Git for Deployment")
 	utils.PrintStatus("Setting up SSH for Git...")
 
 	// Create an SSH directory if it doesn't exist
-	err := utils.RunCommand("mkdir", "-p", "~/.ssh")
+	err := utils.RunCommand(ctx, "mkdir", "-p", "~/.ssh")
 	if err != nil {
 		return err
 	}
 
 	// Set proper permissions for SSH directory
-	err = utils.RunCommand("chmod", "700", "~/.ssh")
+	err = utils.RunCommand(ctx, "chmod", "700", "~/.ssh")
 	if err != nil {
 		return err
 	}
 
 	// Add GitHub to known hosts to prevent SSH prompts
-	err = utils.RunCommand("ssh-keyscan", "-H", "github.com", ">>", "~/.ssh/known_hosts")
+	err = utils.RunCommand(ctx, "ssh-keyscan", "-H", "github.com", ">>", "~/.ssh/known_hosts")
 	if err != nil {
 		return err
 	}
 
 	utils.PrintWarning("Please add your SSH public key to GitHub before proceeding")
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Do you want to generate new ssh key? (y/n)")
-	generateSSHKey, _ := reader.ReadString('\n')
-	generateSSHKey = strings.TrimSpace(generateSSHKey)
+	generateSSHKey, _ := utils.Confirm("Do you want to generate new ssh key?")
 
-	if generateSSHKey == "y" || generateSSHKey == "Y" {
+	if generateSSHKey {
 		// Generate a new SSH key with a strong algorithm
-		err = utils.RunInteractiveCommand("ssh-keygen", "-t", "ed25519", "-C", "deployment@"+os.Getenv("USER"))
+		err = utils.RunInteractiveCommand(ctx, "ssh-keygen", "-t", "ed25519", "-C", "deployment@"+os.Getenv("USER"))
 		if err != nil {
 			return err
 		}
@@ -88,11 +101,9 @@ func configureGit() error {
 
 	utils.PrintWarning("Add the public key (~/.ssh/id_ed25519.pub) to your GitHub account")
 
-	fmt.Print("Print the public key? (y/n)")
-	printPublicKey, _ := reader.ReadString('\n')
-	printPublicKey = strings.TrimSpace(printPublicKey)
+	printPublicKey, _ := utils.Confirm("Print the public key?")
 
-	if printPublicKey == "y" || printPublicKey == "Y" {
+	if printPublicKey {
 		// Read and display the public key
 		pubKeyBytes, err := os.ReadFile(os.Getenv("HOME") + "/.ssh/id_ed25519.pub")
 		if err != nil {
@@ -101,13 +112,12 @@ func configureGit() error {
 		utils.PrintInformation(string(pubKeyBytes))
 	}
 
-	err = utils.RunCommand("sudo", "cp", "-r", "~/.ssh", "/root")
+	err = utils.RunCommand(ctx, "sudo", "cp", "-r", "~/.ssh", "/root")
 	if err != nil {
 		return err
 	}
 
-	fmt.Print("Press Enter when you've added your SSH key to GitHub..")
-	_, err = reader.ReadString('\n')
+	_, err = utils.Prompt("Press Enter when you've added your SSH key to GitHub..")
 	if err != nil {
 		return err
 	}
@@ -116,57 +126,57 @@ func configureGit() error {
 }
 
 // cloneRepository clones the Laravel repository
-func cloneRepository(config *config.Config) error {
+func cloneRepository(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Cloning Laravel Repository")
-	utils.PrintStatus("Cloning repository to " + config.WebRoot + "...")
+	utils.PrintStatus("Cloning repository to " + cfg.WebRoot + "...")
 
 	// Remove the existing directory if it exists
-	if _, err := os.Stat(config.WebRoot); err == nil {
-		utils.PrintWarning("Directory " + config.WebRoot + " already exists. Removing...")
-		err = utils.RunCommand("sudo", "rm", "-rf", config.WebRoot)
+	if _, err := os.Stat(cfg.WebRoot); err == nil {
+		utils.PrintWarning("Directory " + cfg.WebRoot + " already exists. Removing...")
+		err = utils.RunCommand(ctx, "sudo", "rm", "-rf", cfg.WebRoot)
 		if err != nil {
 			return err
 		}
 	}
 
 	// Clone the repository
-	if config.RepoURL == "" {
+	if cfg.RepoURL == "" {
 		utils.PrintError("Repository URL cannot be empty")
 		return fmt.Errorf("repository URL cannot be empty")
 	}
 
-	err := utils.RunCommand("sudo", "git", "clone", config.RepoURL, config.WebRoot)
+	err := utils.RunCommand(ctx, "sudo", "git", "clone", cfg.RepoURL, cfg.WebRoot)
 	if err != nil {
 		return err
 	}
 
 	// Set proper ownership and permissions
 	utils.PrintStatus("Setting proper ownership and permissions...")
-	err = utils.RunCommand("sudo", "chown", "-R", os.Getenv("USER")+":"+config.WebUser, config.WebRoot)
+	err = utils.RunCommand(ctx, "sudo", "chown", "-R", os.Getenv("USER")+":"+cfg.PrimarySite().FPM.Group, cfg.WebRoot)
 	if err != nil {
 		return err
 	}
 
 	// Set directory permissions
-	err = utils.RunCommand("sudo", "chmod", "-R", "755", config.WebRoot)
+	err = utils.RunCommand(ctx, "sudo", "chmod", "-R", "755", cfg.WebRoot)
 	if err != nil {
 		return err
 	}
 
 	// Set storage directory permissions (needs to be writable by web server)
-	err = utils.RunCommand("sudo", "chmod", "-R", "775", config.WebRoot+"/storage")
+	err = utils.RunCommand(ctx, "sudo", "chmod", "-R", "775", cfg.WebRoot+"/storage")
 	if err != nil {
 		return err
 	}
 
 	// Create bootstrap/cache directory if it doesn't exist
-	err = utils.RunCommand("sudo", "mkdir", "-p", config.WebRoot+"/bootstrap/cache")
+	err = utils.RunCommand(ctx, "sudo", "mkdir", "-p", cfg.WebRoot+"/bootstrap/cache")
 	if err != nil {
 		return err
 	}
 
 	// Set bootstrap/cache directory permissions (needs to be writable by web server)
-	err = utils.RunCommand("sudo", "chmod", "-R", "775", config.WebRoot+"/bootstrap/cache")
+	err = utils.RunCommand(ctx, "sudo", "chmod", "-R", "775", cfg.WebRoot+"/bootstrap/cache")
 	if err != nil {
 		return err
 	}
@@ -174,131 +184,242 @@ func cloneRepository(config *config.Config) error {
 	return nil
 }
 
-// installDependencies installs Composer dependencies
-func installDependencies(config *config.Config) error {
+// installDependencies installs Composer dependencies with the given PHP version
+func installDependencies(ctx context.Context, cfg *config.Config, version config.PHPVersion) error {
 	utils.PrintHeader("Installing Composer Dependencies")
 	utils.PrintStatus("Installing Composer dependencies...")
 
 	// Change to web root directory
-	err := os.Chdir(config.WebRoot)
+	err := os.Chdir(cfg.WebRoot)
 	if err != nil {
 		return err
 	}
 
 	// Install Composer dependencies with optimizations for production
-	err = utils.RunCommand("composer", "install", "--no-dev", "--optimize-autoloader")
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return php.ComposerInstall(ctx, version, cfg.WebRoot)
 }
 
+// AppKeySecret is the name of the application's APP_KEY in the vault
+const AppKeySecret = "laravel/app-key"
+
 // configureEnvironment configures the Laravel environment
-func configureEnvironment(config *config.Config) error {
+func configureEnvironment(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Configuring Laravel Environment")
 	utils.PrintStatus("Setting up .env file...")
 
 	// Copy .env.example to .env if it exists
 	if _, err := os.Stat(".env.example"); err == nil {
-		err = utils.RunCommand("cp", ".env.example", ".env")
+		err = utils.RunCommand(ctx, "cp", ".env.example", ".env")
 		if err != nil {
 			return err
 		}
 	} else {
 		utils.PrintWarning("No .env.example file found. Creating empty .env file...")
-		err = utils.RunCommand("touch", ".env")
+		err = utils.RunCommand(ctx, "touch", ".env")
 		if err != nil {
 			return err
 		}
 	}
 
-	// Update the .env file with database credentials
-	err := utils.RunCommand("sed", "-i", "s/DB_DATABASE=laravel/DB_DATABASE="+config.DBName+"/", ".env")
+	// Point the application at the configured database engine
+	// The password comes from the credentials store, which holds it once it has been rotated
+	utils.PrintStatus("Configuring the " + cfg.Database.DisplayName() + " connection...")
+	password, err := database.AppPassword(ctx, cfg)
 	if err != nil {
 		return err
 	}
+	vars := []envVar{
+		{"DB_CONNECTION", cfg.Database.LaravelConnection()},
+		{"DB_HOST", cfg.Database.HostName()},
+		{"DB_PORT", strconv.Itoa(cfg.Database.PortNumber())},
+		{"DB_SOCKET", cfg.Database.Socket()},
+		{"DB_DATABASE", cfg.DBName},
+		{"DB_USERNAME", cfg.DBUser},
+		{"DB_PASSWORD", password},
+	}
+	if ca := cfg.Database.SSLCA; ca != "" {
+		// Laravel's mysql and mariadb connections read MYSQL_ATTR_SSL_CA; pgsql needs DB_SSLMODE and
+		// DB_SSLROOTCERT added to its connection in config/database.php
+		if cfg.Database.IsMySQL() {
+			vars = append(vars, envVar{"MYSQL_ATTR_SSL_CA", ca})
+		} else {
+			vars = append(vars, envVar{"DB_SSLMODE", "verify-full"}, envVar{"DB_SSLROOTCERT", ca})
+		}
+	}
 
-	err = utils.RunCommand("sed", "-i", "s/DB_USERNAME=root/DB_USERNAME="+config.DBUser+"/", ".env")
-	if err != nil {
+	// Point the cache, sessions and queues at Redis, wherever it runs
+	// CACHE_STORE is Laravel 11's name for CACHE_DRIVER
+	vars = append(vars, redisEnv(cfg.Redis)...)
+	vars = append(vars,
+		envVar{"CACHE_DRIVER", "redis"},
+		envVar{"CACHE_STORE", "redis"},
+		envVar{"SESSION_DRIVER", "redis"},
+		envVar{"QUEUE_CONNECTION", "redis"},
+	)
+
+	if err := setEnv(ctx, ".env", vars); err != nil {
 		return err
 	}
 
-	err = utils.RunCommand("sed", "-i", "s|^DB_PASSWORD=.*|DB_PASSWORD=\""+config.DBPassword+"\"|", ".env")
+	// The engine's PDO driver is installed with PHP, but the PHP step may have been skipped
+	err = php.EnsureExtensions(ctx, cfg.PHPVersion, []string{cfg.Database.PHPExtension()})
 	if err != nil {
 		return err
 	}
 
-	// Configure Redis for caching, sessions, and queue
-	err = utils.RunCommand("sed", "-i", "s/CACHE_DRIVER=file/CACHE_DRIVER=redis/", ".env")
+	// Generate an application key
+	utils.PrintStatus("Generating application key...")
+	err = utils.RunCommand(ctx, cfg.PHPVersion.Binary(), "artisan", "key:generate")
 	if err != nil {
 		return err
 	}
 
-	err = utils.RunCommand("sed", "-i", "s/SESSION_DRIVER=file/SESSION_DRIVER=redis/", ".env")
+	// The key decrypts the application's encrypted data, so a copy is kept for moving to another server
+	appKey, err := getEnv(".env", "APP_KEY")
 	if err != nil {
 		return err
 	}
+	if appKey != "" {
+		if err := vault.Store(ctx, cfg, AppKeySecret, appKey); err != nil {
+			return err
+		}
+	}
 
-	err = utils.RunCommand("sed", "-i", "s/QUEUE_CONNECTION=sync/QUEUE_CONNECTION=redis/", ".env")
-	if err != nil {
-		return err
+	return prepareDatabase(ctx, cfg)
+}
+
+// redisEnv returns the .env connection settings of Redis
+// Settings that don't apply are set to null, which Laravel reads as unset, so none are left from an earlier run
+// phpredis connects to a socket given as REDIS_HOST when REDIS_PORT is 0
+func redisEnv(r config.Redis) []envVar {
+	port, scheme := strconv.Itoa(r.PortNumber()), "null"
+	if r.Socket {
+		port = "0"
+	}
+	if r.TLS {
+		scheme = "tls"
 	}
+	user, password := "null", "null"
+	if r.User != "" {
+		user = r.User
+	}
+	if r.Password != "" {
+		password = r.Password
+	}
+	return []envVar{
+		{"REDIS_HOST", r.HostName()},
+		{"REDIS_PORT", port},
+		{"REDIS_USERNAME", user},
+		{"REDIS_PASSWORD", password},
+		{"REDIS_SCHEME", scheme},
+	}
+}
 
-	// Generate an application key
-	utils.PrintStatus("Generating application key...")
-	err = utils.RunCommand("php", "artisan", "key:generate")
-	if err != nil {
-		return err
+// prepareDatabase imports the configured dump, runs the migrations and the seeder
+// The dump and the seeder are only loaded into a database without tables, so a second run leaves the data alone
+func prepareDatabase(ctx context.Context, cfg *config.Config) error {
+	fresh := false
+	if cfg.Import.Source != "" || cfg.Import.SeedClass != "" {
+		empty, err := database.Empty(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		fresh = empty
 	}
 
-	// Run migrations
-	utils.PrintStatus("Running database migrations...")
-	err = utils.RunCommand("php", "artisan", "migrate", "--force")
-	if err != nil {
-		return err
+	if cfg.Import.Source != "" {
+		if fresh {
+			if err := database.Import(ctx, cfg); err != nil {
+				return err
+			}
+		} else {
+			utils.PrintWarning(cfg.DBName + " already has tables, not importing " + cfg.Import.Source)
+		}
+	}
+
+	// Run migrations with the migration account; the application account can't change the schema
+	if cfg.Import.SkipMigrate {
+		utils.PrintWarning("Not running the database migrations: SkipMigrate is set")
+	} else {
+		utils.PrintStatus("Running database migrations...")
+		err := database.Migrate(ctx, cfg, cfg.PHPVersion.Binary(), "artisan")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Import.SeedClass != "" {
+		if !fresh {
+			utils.PrintWarning(cfg.DBName + " already had tables, not running seeder " + cfg.Import.SeedClass)
+			return nil
+		}
+		utils.PrintStatus("Seeding the database with " + cfg.Import.SeedClass + "...")
+		err := database.Seed(ctx, cfg, cfg.PHPVersion.Binary(), "artisan", cfg.Import.SeedClass)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 // configureSupervisor configures Supervisor for Laravel Queue
-func configureSupervisor(config *config.Config) error {
+func configureSupervisor(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Configuring Supervisor for Laravel Queue")
 	utils.PrintStatus("Setting up Supervisor for Laravel queue workers...")
 
-	// Generate Supervisor configuration
-	supervisorConfig := templates.GetSupervisorConfig(config.WebRoot, config.WebUser)
+	// Generate Supervisor configuration, running the workers as the site's PHP-FPM pool user
+	supervisorConfig := templates.GetSupervisorConfig(cfg.WebRoot, cfg.PrimarySite().FPM.User, cfg.PHPVersion.Binary(), config.QueueWorkers)
 
-	// Write Supervisor configuration to file
-	err := os.WriteFile("laravel-worker.conf", []byte(supervisorConfig), 0644)
+	// Write Supervisor configuration to the conf.d directory
+	err := utils.WriteSystemFile(ctx, "/etc/supervisor/conf.d/laravel-worker.conf", supervisorConfig, 0644)
 	if err != nil {
 		return err
 	}
 
-	// Move Supervisor configuration to conf.d directory
-	err = utils.RunCommand("sudo", "mv", "laravel-worker.conf", "/etc/supervisor/conf.d/laravel-worker.conf")
+	// Reload Supervisor configuration
+	err = utils.RunCommand(ctx, "sudo", "supervisorctl", "reread")
 	if err != nil {
 		return err
 	}
 
-	// Reload Supervisor configuration
-	err = utils.RunCommand("sudo", "supervisorctl", "reread")
+	// Update Supervisor to apply changes
+	err = utils.RunCommand(ctx, "sudo", "supervisorctl", "update")
 	if err != nil {
 		return err
 	}
 
-	// Update Supervisor to apply changes
-	err = utils.RunCommand("sudo", "supervisorctl", "update")
+	// Start Laravel workers
+	err = utils.RunCommand(ctx, "sudo", "supervisorctl", "start", "laravel-worker:*")
 	if err != nil {
 		return err
 	}
 
-	// Start Laravel workers
-	err = utils.RunCommand("sudo", "supervisorctl", "start", "laravel-worker:*")
+	return nil
+}
+
+// Rollback stops the queue workers and removes their Supervisor configuration
+// The cloned application is left in place so no data is lost
+func Rollback(ctx context.Context, _ *config.Config) error {
+	utils.PrintHeader("Rolling Back Laravel Queue Workers")
+	utils.PrintStatus("Removing Supervisor configuration for Laravel queue workers...")
+
+	err := utils.RunCommand(ctx, "sudo", "rm", "-f", "/etc/supervisor/conf.d/laravel-worker.conf")
+	if err != nil {
+		return err
+	}
+
+	// Reread and update so Supervisor stops the removed workers
+	err = utils.RunCommand(ctx, "sudo", "supervisorctl", "reread")
+	if err != nil {
+		return err
+	}
+
+	err = utils.RunCommand(ctx, "sudo", "supervisorctl", "update")
 	if err != nil {
 		return err
 	}
 
+	utils.PrintStatus("Laravel queue workers rolled back")
 	return nil
 }
@@ -0,0 +1,213 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+
+	"laravel-setup/pkg/utils"
+)
+
+// DefaultPath is the lock file shared by every laravel-setup run on the host
+const DefaultPath = "/run/laravel-setup.lock"
+
+// pollInterval is how often a waiting Acquire retries the lock
+const pollInterval = 500 * time.Millisecond
+
+// Holder describes the process holding the lock
+type Holder struct {
+	PID       int       `json:"pid"`
+	User      string    `json:"user"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// HeldError is returned when the lock is held by another process
+type HeldError struct {
+	// Holder is the process holding the lock, zero if it couldn't be read
+	Holder Holder
+}
+
+func (e *HeldError) Error() string {
+	if e.Holder.PID == 0 {
+		return "another laravel-setup run is in progress"
+	}
+	return fmt.Sprintf("another laravel-setup run is in progress: %s (pid %d) by user %s, started %s",
+		e.Holder.Command, e.Holder.PID, e.Holder.User, e.Holder.StartedAt.Format("2006-01-02 15:04:05"))
+}
+
+// lockMode is the mode of the lock file: only root may write it, so other users can't erase the holder
+const lockMode = 0644
+
+// Lock is an exclusive lock on a file, held until Release is called or the process exits
+type Lock struct {
+	file *os.File
+	path string
+	// writable is set when the file is open for writing; otherwise the holder is written with sudo
+	writable bool
+}
+
+// Acquire takes the exclusive lock at path on behalf of command
+// If the lock is held and wait is false a *HeldError is returned immediately;
+// if wait is true Acquire blocks until the lock is free or ctx is done
+func Acquire(ctx context.Context, path, command string, wait bool) (*Lock, error) {
+	l, err := open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	file := l.file
+
+	announced := false
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+
+		held := &HeldError{Holder: readHolder(path)}
+		if !wait {
+			_ = file.Close()
+			return nil, held
+		}
+		if !announced {
+			utils.PrintWarning(held.Error())
+			utils.PrintStatus("Waiting for the lock to be released...")
+			announced = true
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = file.Close()
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+
+	if err := l.writeHolder(ctx, command); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to record lock holder in %s: %w", path, err)
+	}
+
+	return l, nil
+}
+
+// Release clears the holder information and releases the lock
+// The file is kept, since removing it would let two processes lock different files
+func (l *Lock) Release() error {
+	_ = l.write(context.Background(), nil)
+	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
+		_ = l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// open opens the lock file, creating it root-owned with sudo unless running as root
+// Other users open it read-only, which is enough to lock it, and write the holder with sudo
+func open(ctx context.Context, path string) (*Lock, error) {
+	if os.Geteuid() == 0 {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, lockMode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+		}
+		// Earlier versions made the file writable by everyone
+		if err := file.Chmod(lockMode); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+		}
+		return &Lock{file: file, path: path, writable: true}, nil
+	}
+
+	if !rootOwned(path) {
+		// touch, chown and chmod keep the existing file, so locks held on it stay valid
+		for _, args := range [][]string{
+			{"touch", path},
+			{"chown", "root:root", path},
+			{"chmod", fmt.Sprintf("%04o", lockMode), path},
+		} {
+			if err := utils.RunCommand(ctx, "sudo", args...); err != nil {
+				return nil, fmt.Errorf("failed to create lock file %s: %w", path, err)
+			}
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+	}
+	return &Lock{file: file, path: path}, nil
+}
+
+// rootOwned reports whether the lock file exists, belongs to root and has lockMode
+func rootOwned(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Uid == 0 && info.Mode().Perm() == lockMode
+}
+
+// writeHolder records the current process as the holder of the lock
+func (l *Lock) writeHolder(ctx context.Context, command string) error {
+	holder := Holder{
+		PID:       os.Getpid(),
+		User:      currentUser(),
+		Command:   command,
+		StartedAt: time.Now(),
+	}
+
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return err
+	}
+	return l.write(ctx, data)
+}
+
+// write replaces the content of the lock file in place, so the lock on it is kept
+func (l *Lock) write(ctx context.Context, data []byte) error {
+	if !l.writable {
+		// dd truncates the file and writes it without replacing it
+		return utils.RunCommandWithInput(ctx, data, "sudo", "dd", "of="+l.path, "status=none")
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// readHolder reads the holder information from the lock file
+// Returns a zero Holder if the file is empty or unreadable
+func readHolder(path string) Holder {
+	var holder Holder
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return holder
+	}
+	_ = json.Unmarshal(data, &holder)
+	return holder
+}
+
+// currentUser returns the name of the user running the tool
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return strconv.Itoa(os.Getuid())
+}
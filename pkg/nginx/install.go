@@ -1,21 +1,26 @@
 package nginx
 
 import (
+	"context"
 	"os"
 	"strings"
 
 	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/system"
 	"laravel-setup/pkg/templates"
 	"laravel-setup/pkg/utils"
 )
 
+// statusSite is the Nginx site exposing the PHP-FPM status pages
+const statusSite = config.FPMStatusSite
+
 // Install installs and configures Nginx for Laravel
-func Install(config *config.Config) error {
+func Install(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Installing Nginx")
 	utils.PrintStatus("Installing Nginx web server...")
 
 	// Install Nginx
-	err := utils.RunCommand("sudo", "apt", "install", "-y", "nginx")
+	err := utils.RunCommand(ctx, "sudo", "apt", "install", "-y", "nginx")
 	if err != nil {
 		return err
 	}
@@ -24,13 +29,13 @@ func Install(config *config.Config) error {
 
 	// Configure Nginx for Laravel
 	utils.PrintHeader("Configuring Nginx for Laravel")
-	utils.PrintStatus("Setting up Nginx configuration for domain: " + config.Domain)
+	utils.PrintStatus("Setting up Nginx configuration for domain: " + cfg.Domain)
 
 	// Add rate-limiting zones to nginx.conf for security
 	// This helps prevent brute force and DoS attacks
 
 	// Check if rate-limiting zones already exist to prevent duplication
-	_, err = utils.RunCommandWithOutput("sudo", "grep", "limit_req_zone.*zone=login", "/etc/nginx/nginx.conf")
+	_, err = utils.RunCommandWithOutput(ctx, "sudo", "grep", "limit_req_zone.*zone=login", "/etc/nginx/nginx.conf")
 	if err != nil && !strings.Contains(err.Error(), "exit status 1") {
 		// Real error, not just "not found"
 		return err
@@ -39,7 +44,7 @@ func Install(config *config.Config) error {
 	// Only add rate-limiting zones if they don't already exist
 	if err != nil {
 		// Rate-limiting zones don't exist, add them
-		err = utils.RunCommand("sudo", "sed", "-i", `/http {/a\\n    # Rate limiting zones\n    limit_req_zone $binary_remote_addr zone=login:10m rate=10r/m;\n    limit_req_zone $binary_remote_addr zone=api:10m rate=100r/m;`, "/etc/nginx/nginx.conf")
+		err = utils.RunCommand(ctx, "sudo", "sed", "-i", `/http {/a\\n    # Rate limiting zones\n    limit_req_zone $binary_remote_addr zone=login:10m rate=10r/m;\n    limit_req_zone $binary_remote_addr zone=api:10m rate=100r/m;`, "/etc/nginx/nginx.conf")
 		if err != nil {
 			return err
 		}
@@ -48,68 +53,139 @@ func Install(config *config.Config) error {
 		utils.PrintStatus("Rate limiting zones already exist in nginx.conf")
 	}
 
-	// Create the site configuration using the template
-	nginxConfig := templates.GetNginxConfig(config.Domain, config.WebRoot)
+	// Write and enable a vhost for every site, each pointing at its own PHP version
+	sites := cfg.AllSites()
+	for _, site := range sites {
+		if err := writeSite(ctx, site); err != nil {
+			return err
+		}
+	}
 
-	// Write Nginx configuration to file
-	err = os.WriteFile("nginx_site.conf", []byte(nginxConfig), 0644)
-	if err != nil {
+	// Expose the PHP-FPM status pages of every pool to local clients only
+	if err := writeStatusSite(ctx, sites); err != nil {
 		return err
 	}
 
-	// Move Nginx configuration to sites-available directory
-	err = utils.RunCommand("sudo", "mv", "nginx_site.conf", "/etc/nginx/sites-available/"+config.Domain)
+	// Remove default site to prevent conflicts
+	err = utils.RunCommand(ctx, "sudo", "rm", "-f", "/etc/nginx/sites-enabled/default")
 	if err != nil {
 		return err
 	}
 
-	// Enable the site by creating a symbolic link in sites-enabled
-	err = utils.RunCommand("sudo", "ln", "-sf", "/etc/nginx/sites-available/"+config.Domain, "/etc/nginx/sites-enabled/")
+	// Test Nginx configuration
+	utils.PrintStatus("Testing Nginx configuration...")
+	err = utils.RunCommand(ctx, "sudo", "nginx", "-t")
 	if err != nil {
 		return err
 	}
 
-	// Remove default site to prevent conflicts
-	err = utils.RunCommand("sudo", "rm", "-f", "/etc/nginx/sites-enabled/default")
+	utils.PrintStatus("Nginx configuration is valid")
+
+	// Restart Nginx to apply changes
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", "nginx")
 	if err != nil {
 		return err
 	}
 
-	// Test Nginx configuration
-	utils.PrintStatus("Testing Nginx configuration...")
-	err = utils.RunCommand("sudo", "nginx", "-t")
+	for _, site := range sites {
+		if err := createWebRoot(ctx, site); err != nil {
+			return err
+		}
+		utils.PrintStatus("Nginx configured successfully for " + site.Domain)
+	}
+
+	return nil
+}
+
+// writeSite writes the vhost of a site to sites-available and enables it
+func writeSite(ctx context.Context, site config.Site) error {
+	utils.PrintStatus("Writing Nginx configuration for " + site.Domain + " (PHP " + string(site.PHPVersion) + ")...")
+
+	// Create the site configuration using the template
+	nginxConfig := templates.GetNginxConfig(site.Domain, site.WebRoot, site.FPMSocket())
+
+	// Write Nginx configuration to sites-available directory
+	err := utils.WriteSystemFile(ctx, "/etc/nginx/sites-available/"+site.Domain, nginxConfig, 0644)
 	if err != nil {
 		return err
 	}
 
-	utils.PrintStatus("Nginx configuration is valid")
+	// Enable the site by creating a symbolic link in sites-enabled
+	return utils.RunCommand(ctx, "sudo", "ln", "-sf", "/etc/nginx/sites-available/"+site.Domain, "/etc/nginx/sites-enabled/")
+}
 
-	// Restart Nginx to apply changes
-	err = utils.RunCommand("sudo", "systemctl", "restart", "nginx")
+// writeStatusSite writes and enables the localhost-only server exposing the PHP-FPM status pages
+func writeStatusSite(ctx context.Context, sites []config.Site) error {
+	utils.PrintStatus("Writing PHP-FPM status server on " + config.FPMStatusAddress + "...")
+
+	pools := make([]templates.StatusPool, len(sites))
+	for i, site := range sites {
+		pools[i] = templates.StatusPool{Domain: site.Domain, Socket: site.FPMSocket()}
+	}
+	statusConfig := templates.GetFPMStatusConfig(config.FPMStatusAddress, config.FPMStatusPath, config.FPMPingPath, pools)
+
+	err := utils.WriteSystemFile(ctx, "/etc/nginx/sites-available/"+statusSite, statusConfig, 0644)
 	if err != nil {
 		return err
 	}
 
+	return utils.RunCommand(ctx, "sudo", "ln", "-sf", "/etc/nginx/sites-available/"+statusSite, "/etc/nginx/sites-enabled/")
+}
+
+// createWebRoot creates the web directory of a site if it doesn't exist
+// The directory belongs to the group of the site's PHP-FPM pool so only that pool can write to it
+func createWebRoot(ctx context.Context, site config.Site) error {
+	// The pool's account is normally created with PHP, but that step may have been skipped
+	if err := system.EnsureAccount(ctx, site.FPM.User, site.FPM.Group); err != nil {
+		return err
+	}
+
 	// Create web directory if it doesn't exist
-	utils.PrintStatus("Setting up web directory...")
-	err = utils.RunCommand("sudo", "mkdir", "-p", config.WebRoot)
+	utils.PrintStatus("Setting up web directory " + site.WebRoot + "...")
+	err := utils.RunCommand(ctx, "sudo", "mkdir", "-p", site.WebRoot)
 	if err != nil {
 		return err
 	}
 
 	// Set proper ownership and permissions
 	// This allows the web server to access the files while maintaining security
-	err = utils.RunCommand("sudo", "chown", "-R", os.Getenv("USER")+":"+config.WebUser, config.WebRoot)
+	err = utils.RunCommand(ctx, "sudo", "chown", "-R", os.Getenv("USER")+":"+site.FPM.Group, site.WebRoot)
 	if err != nil {
 		return err
 	}
 
-	err = utils.RunCommand("sudo", "chmod", "-R", "755", config.WebRoot)
+	return utils.RunCommand(ctx, "sudo", "chmod", "-R", "755", site.WebRoot)
+}
+
+// Rollback removes the site configurations written by Install and reloads Nginx
+// The Nginx package itself and the web directories are left in place
+func Rollback(ctx context.Context, cfg *config.Config) error {
+	utils.PrintHeader("Rolling Back Nginx Configuration")
+
+	names := []string{statusSite}
+	for _, site := range cfg.AllSites() {
+		names = append(names, site.Domain)
+	}
+
+	for _, name := range names {
+		utils.PrintStatus("Removing Nginx configuration: " + name)
+		err := utils.RunCommand(ctx, "sudo", "rm", "-f", "/etc/nginx/sites-enabled/"+name, "/etc/nginx/sites-available/"+name)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only reload if the remaining configuration is valid
+	err := utils.RunCommand(ctx, "sudo", "nginx", "-t")
 	if err != nil {
 		return err
 	}
 
-	utils.PrintStatus("Nginx configured successfully for " + config.Domain)
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "reload", "nginx")
+	if err != nil {
+		return err
+	}
 
+	utils.PrintStatus("Nginx configuration rolled back")
 	return nil
 }
@@ -0,0 +1,20 @@
+package php
+
+import (
+	"context"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// composerScript runs Composer with the PHP binary in $1 for the application in $2
+// Composer is looked up on the PATH, where the essentials step installs it
+const composerScript = `composer=$(command -v composer) || { echo "composer is not installed" >&2; exit 1; }
+exec "$1" "$composer" install --no-dev --optimize-autoloader --working-dir="$2"`
+
+// ComposerInstall installs the Composer dependencies of the application in appDir, optimized for production
+// Composer runs on the given version rather than the default php, so its platform check and the
+// installed packages match the version serving the site, which php switch may have changed
+func ComposerInstall(ctx context.Context, version config.PHPVersion, appDir string) error {
+	return utils.RunCommand(ctx, "bash", "-c", composerScript, "bash", version.Binary(), appDir)
+}
@@ -0,0 +1,50 @@
+package php
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"laravel-setup/pkg/utils"
+)
+
+// recordingExecutor records the commands it is asked to run without running them
+type recordingExecutor struct {
+	commands [][]string
+}
+
+func (e *recordingExecutor) Run(_ context.Context, command string, args ...string) error {
+	e.commands = append(e.commands, append([]string{command}, args...))
+	return nil
+}
+
+func (e *recordingExecutor) Output(ctx context.Context, command string, args ...string) (string, error) {
+	return "", e.Run(ctx, command, args...)
+}
+
+func (e *recordingExecutor) RunWithInput(ctx context.Context, _ []byte, command string, args ...string) error {
+	return e.Run(ctx, command, args...)
+}
+
+func (e *recordingExecutor) RunInteractive(ctx context.Context, command string, args ...string) error {
+	return e.Run(ctx, command, args...)
+}
+
+func (e *recordingExecutor) WriteFile(context.Context, string, []byte, os.FileMode) error {
+	return nil
+}
+
+func TestComposerInstall(t *testing.T) {
+	recorder := &recordingExecutor{}
+	defer utils.SetExecutor(utils.SetExecutor(recorder))
+
+	if err := ComposerInstall(context.Background(), "8.3", "/var/www/laravel"); err != nil {
+		t.Fatalf("ComposerInstall() = %v", err)
+	}
+
+	want := [][]string{{"bash", "-c", composerScript, "bash", "php8.3", "/var/www/laravel"}}
+	if !reflect.DeepEqual(recorder.commands, want) {
+		t.Errorf("ComposerInstall() runs\n%q\nwant\n%q", recorder.commands, want)
+	}
+}
@@ -0,0 +1,278 @@
+package php
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// basePackages are the PHP packages installed for every version regardless of the extensions
+var basePackages = []string{"fpm", "cli", "common"}
+
+// extension is a PHP extension and the apt package that provides it
+type extension struct {
+	// Package is the apt package without the version prefix, e.g. "mysql" for php8.4-mysql
+	Package string
+	// Module is the extension name reported by php -m
+	Module string
+}
+
+// knownExtensions maps extension names to their packages where the two differ
+// Extensions built into PHP are provided by the common or cli package
+// Any other name is assumed to be provided by the package of the same name, e.g. php8.4-swoole
+var knownExtensions = map[string]extension{
+	"mysql":        {"mysql", "pdo_mysql"},
+	"pdo_mysql":    {"mysql", "pdo_mysql"},
+	"mysqli":       {"mysql", "mysqli"},
+	"mysqlnd":      {"mysql", "mysqlnd"},
+	"pdo_pgsql":    {"pgsql", "pdo_pgsql"},
+	"sqlite":       {"sqlite3", "sqlite3"},
+	"pdo_sqlite":   {"sqlite3", "pdo_sqlite"},
+	"dom":          {"xml", "dom"},
+	"simplexml":    {"xml", "SimpleXML"},
+	"xmlreader":    {"xml", "xmlreader"},
+	"xmlwriter":    {"xml", "xmlwriter"},
+	"xsl":          {"xml", "xsl"},
+	"opcache":      {"opcache", "Zend OPcache"},
+	"zend-opcache": {"opcache", "Zend OPcache"},
+	"pcntl":        {"cli", "pcntl"},
+	"readline":     {"readline", "readline"},
+	"calendar":     {"common", "calendar"},
+	"ctype":        {"common", "ctype"},
+	"exif":         {"common", "exif"},
+	"ffi":          {"common", "FFI"},
+	"fileinfo":     {"common", "fileinfo"},
+	"ftp":          {"common", "ftp"},
+	"gettext":      {"common", "gettext"},
+	"iconv":        {"common", "iconv"},
+	"pdo":          {"common", "PDO"},
+	"phar":         {"common", "Phar"},
+	"posix":        {"common", "posix"},
+	"shmop":        {"common", "shmop"},
+	"sockets":      {"common", "sockets"},
+	"sodium":       {"common", "sodium"},
+	"sysvmsg":      {"common", "sysvmsg"},
+	"sysvsem":      {"common", "sysvsem"},
+	"sysvshm":      {"common", "sysvshm"},
+	"tokenizer":    {"common", "tokenizer"},
+	"core":         {"common", "Core"},
+	"date":         {"common", "date"},
+	"filter":       {"common", "filter"},
+	"hash":         {"common", "hash"},
+	"json":         {"common", "json"},
+	"libxml":       {"common", "libxml"},
+	"openssl":      {"common", "openssl"},
+	"pcre":         {"common", "pcre"},
+	"random":       {"common", "random"},
+	"reflection":   {"common", "Reflection"},
+	"session":      {"common", "session"},
+	"spl":          {"common", "SPL"},
+	"standard":     {"common", "standard"},
+	"zlib":         {"common", "zlib"},
+}
+
+// lookupExtension returns the package and module of an extension
+func lookupExtension(name string) extension {
+	name = config.NormalizePHPExtension(name)
+	if ext, ok := knownExtensions[name]; ok {
+		return ext
+	}
+	return extension{Package: name, Module: name}
+}
+
+// versionPackages returns the apt packages installed for a PHP version with the given extensions
+func versionPackages(version config.PHPVersion, extensions []string) []string {
+	packages := []string{version.Package("")}
+	for _, p := range basePackages {
+		packages = append(packages, version.Package(p))
+	}
+	return append(packages, extensionPackages(version, extensions)...)
+}
+
+// extensionPackages returns the apt packages providing the given extensions, without duplicates
+func extensionPackages(version config.PHPVersion, extensions []string) []string {
+	seen := map[string]bool{}
+	for _, p := range basePackages {
+		seen[p] = true
+	}
+
+	var packages []string
+	for _, name := range extensions {
+		ext := lookupExtension(name)
+		if !seen[ext.Package] {
+			seen[ext.Package] = true
+			packages = append(packages, version.Package(ext.Package))
+		}
+	}
+	return packages
+}
+
+// RequiredExtensions returns the PHP extensions an application requires
+// The ext-* requirements of composer.json and of every locked package in composer.lock are collected
+// An application without composer.json requires nothing
+func RequiredExtensions(appDir string) ([]string, error) {
+	seen := map[string]bool{}
+
+	collect := func(requirements map[string]string) {
+		for name := range requirements {
+			ext := config.NormalizePHPExtension(name)
+			if strings.HasPrefix(strings.ToLower(name), "ext-") && config.IsValidPHPExtension(ext) {
+				seen[ext] = true
+			}
+		}
+	}
+
+	var manifest struct {
+		Require map[string]string `json:"require"`
+	}
+	found, err := readJSON(filepath.Join(appDir, "composer.json"), &manifest)
+	if err != nil || !found {
+		return nil, err
+	}
+	collect(manifest.Require)
+
+	var lock struct {
+		Packages []struct {
+			Require map[string]string `json:"require"`
+		} `json:"packages"`
+		Platform map[string]string `json:"platform"`
+	}
+	found, err = readJSON(filepath.Join(appDir, "composer.lock"), &lock)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		for _, pkg := range lock.Packages {
+			collect(pkg.Require)
+		}
+		collect(lock.Platform)
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readJSON decodes a JSON file, reporting false if it doesn't exist
+func readJSON(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// missingExtensions returns the extensions that aren't loaded by a PHP version
+func missingExtensions(ctx context.Context, version config.PHPVersion, extensions []string) ([]string, error) {
+	output, err := utils.RunCommandWithOutput(ctx, version.Binary(), "-m")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PHP %s extensions: %w", version, err)
+	}
+
+	loaded := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		loaded[strings.ToLower(strings.TrimSpace(line))] = true
+	}
+
+	seen := map[string]bool{}
+	var missing []string
+	for _, name := range extensions {
+		name = config.NormalizePHPExtension(name)
+		if !seen[name] && !loaded[strings.ToLower(lookupExtension(name).Module)] {
+			seen[name] = true
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// CheckExtensions verifies that a PHP version has every extension in the list
+func CheckExtensions(ctx context.Context, version config.PHPVersion, extensions []string) error {
+	utils.PrintStatus("Checking PHP " + string(version) + " extensions...")
+
+	missing, err := missingExtensions(ctx, version, extensions)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("PHP %s is missing extensions %s; install them with: sudo apt install %s",
+			version, strings.Join(missing, ", "), strings.Join(extensionPackages(version, missing), " "))
+	}
+	return nil
+}
+
+// InstallAppExtensions installs the extensions an application requires that aren't loaded yet
+// It runs before composer install so the platform check doesn't fail on a missing extension
+func InstallAppExtensions(ctx context.Context, version config.PHPVersion, appDir string) error {
+	utils.PrintStatus("Checking PHP extensions required by the application...")
+
+	required, err := RequiredExtensions(appDir)
+	if err != nil {
+		return err
+	}
+
+	missing, err := missingExtensions(ctx, version, required)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		utils.PrintStatus("All PHP extensions required by the application are installed")
+		return nil
+	}
+
+	utils.PrintStatus("Installing PHP extensions required by the application: " + strings.Join(missing, ", "))
+	return installExtensions(ctx, version, missing)
+}
+
+// EnsureExtensions installs the given PHP extensions for a version if they are missing
+func EnsureExtensions(ctx context.Context, version config.PHPVersion, extensions []string) error {
+	missing, err := missingExtensions(ctx, version, extensions)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	utils.PrintStatus("Installing PHP extensions: " + strings.Join(missing, ", "))
+	return installExtensions(ctx, version, missing)
+}
+
+// installExtensions apt installs missing extensions, restarts PHP-FPM and checks they load
+func installExtensions(ctx context.Context, version config.PHPVersion, missing []string) error {
+	packages := extensionPackages(version, missing)
+	if len(packages) == 0 {
+		return fmt.Errorf("PHP %s is missing built-in extensions %s", version, strings.Join(missing, ", "))
+	}
+
+	err := utils.RunCommand(ctx, "sudo", append([]string{"apt", "install", "-y"}, packages...)...)
+	if err != nil {
+		return err
+	}
+
+	// Restart PHP-FPM so the web requests get the new extensions too
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", version.FPMService())
+	if err != nil {
+		return err
+	}
+
+	return CheckExtensions(ctx, version, missing)
+}
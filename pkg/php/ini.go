@@ -0,0 +1,106 @@
+package php
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// sapis are the PHP SAPIs that get their own php.ini settings
+var sapis = []string{config.SAPIFPM, config.SAPICLI}
+
+// configureINI writes the php.ini settings of FPM and the CLI to their managed conf.d drop-ins
+// and checks that PHP reports them as effective
+func configureINI(ctx context.Context, cfg *config.Config, version config.PHPVersion) error {
+	for _, sapi := range sapis {
+		settings := cfg.PHP.INI.Settings(sapi)
+
+		utils.PrintStatus("Writing " + version.INIDropIn(sapi) + "...")
+		content := templates.GetINIConfig(settings)
+		if err := utils.WriteSystemFile(ctx, version.INIDropIn(sapi), content, 0644); err != nil {
+			return err
+		}
+
+		if err := verifyINI(ctx, version, sapi, settings); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyINI checks that php -i reports the expected value of every directive
+// Directives PHP doesn't know, e.g. of an extension that isn't installed, only produce a warning
+func verifyINI(ctx context.Context, version config.PHPVersion, sapi string, settings map[string]string) error {
+	binary := version.Binary()
+	if sapi == config.SAPIFPM {
+		binary = version.FPMBinary()
+	}
+
+	output, err := utils.RunCommandWithOutput(ctx, "sudo", binary, "-i")
+	if err != nil {
+		return fmt.Errorf("failed to read effective %s settings of PHP %s: %w", sapi, version, err)
+	}
+	effective := parsePHPInfo(output)
+
+	var mismatches []string
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		got, ok := effective[k]
+		if !ok {
+			utils.PrintWarning(fmt.Sprintf("PHP %s (%s) doesn't know the directive %s", version, sapi, k))
+			continue
+		}
+		if !sameINIValue(got, settings[k]) {
+			mismatches = append(mismatches, fmt.Sprintf("%s is %s instead of %s", k, got, settings[k]))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("PHP %s (%s) doesn't use the configured settings, check for conflicting conf.d files: %s",
+			version, sapi, strings.Join(mismatches, "; "))
+	}
+
+	utils.PrintStatus(fmt.Sprintf("PHP %s (%s) reports the configured settings", version, sapi))
+	return nil
+}
+
+// parsePHPInfo returns the local value of every directive in the text output of php -i
+// Directive lines look like "memory_limit => 512M => 512M"
+func parsePHPInfo(output string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Split(line, " => ")
+		if len(parts) == 3 {
+			values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return values
+}
+
+// sameINIValue compares php.ini values the way PHP interprets them, e.g. "Off" equals "0"
+func sameINIValue(a, b string) bool {
+	a, b = normalizeINIValue(a), normalizeINIValue(b)
+	return a == b
+}
+
+// normalizeINIValue maps the spellings of boolean php.ini values to "1" and "0"
+func normalizeINIValue(v string) string {
+	switch v = strings.ToLower(strings.Trim(strings.TrimSpace(v), `"`)); v {
+	case "on", "true", "yes":
+		return "1"
+	case "off", "false", "no", "none", "", "no value":
+		return "0"
+	}
+	return v
+}
@@ -1,109 +1,101 @@
 package php
 
 import (
-	"laravel-setup/pkg/config"
-	"os"
+	"context"
+	"strings"
 
-	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/config"
 	"laravel-setup/pkg/utils"
 )
 
-// Install installs PHP 8.4 and required extensions for Laravel
-func Install(_ *config.Config) error {
-	utils.PrintHeader("Installing PHP 8.4 and Extensions")
-	utils.PrintStatus("Adding PHP repository and installing PHP 8.4 with extensions...")
+// Install installs every configured PHP version side by side with the configured extensions
+// The primary site's version becomes the default php binary
+func Install(ctx context.Context, cfg *config.Config) error {
+	versions := cfg.AllPHPVersions()
+	extensions := cfg.AllPHPExtensions()
+	names := joinVersions(versions)
+	utils.PrintHeader("Installing PHP " + names + " and Extensions")
+	utils.PrintStatus("Adding PHP repository and installing PHP " + names + " with extensions: " + strings.Join(extensions, ", "))
 
 	// Add a PHP repository from Ondrej (maintained PPA for latest PHP versions)
-	err := utils.RunCommand("sudo", "add-apt-repository", "ppa:ondrej/php", "-y")
+	err := utils.RunCommand(ctx, "sudo", "add-apt-repository", "ppa:ondrej/php", "-y")
 	if err != nil {
 		return err
 	}
 
 	// Update package lists after adding the repository
-	err = utils.RunCommand("sudo", "apt", "update")
-	if err != nil {
-		return err
-	}
-
-	// Install PHP and extensions required for Laravel
-	err = utils.RunCommand("sudo", "apt", "install", "-y",
-		"php8.4", "php8.4-fpm", "php8.4-mysql", "php8.4-mbstring",
-		"php8.4-xml", "php8.4-bcmath", "php8.4-curl", "php8.4-gd",
-		"php8.4-zip", "php8.4-intl", "php8.4-soap", "php8.4-redis",
-		"php8.4-imagick", "php8.4-cli", "php8.4-common", "php8.4-opcache")
+	err = utils.RunCommand(ctx, "sudo", "apt", "update")
 	if err != nil {
 		return err
 	}
 
-	utils.PrintStatus("PHP 8.4 and extensions installed successfully")
-
-	// Configure PHP-FPM for optimal Laravel performance
-	if err := configurePHPFPM(); err != nil {
-		return err
+	// Install PHP and the configured extensions, for every version in one transaction
+	var packages []string
+	for _, version := range versions {
+		packages = append(packages, versionPackages(version, extensions)...)
 	}
-
-	// Display a PHP version for verification
-	err = utils.RunCommand("php", "-v")
+	err = utils.RunCommand(ctx, "sudo", append([]string{"apt", "install", "-y"}, packages...)...)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	utils.PrintStatus("PHP " + names + " and extensions installed successfully")
 
-// configurePHPFPM configures PHP-FPM for optimal Laravel performance
-func configurePHPFPM() error {
-	utils.PrintHeader("Configuring PHP-FPM")
-	utils.PrintStatus("Optimizing PHP configuration for Laravel...")
+	for _, version := range versions {
+		// Give every site served by this version its own pool
+		if err := writeVersionPools(ctx, cfg, version); err != nil {
+			return err
+		}
 
-	// Adjust PHP settings for Laravel
-	// Disable path info fixing for security
-	err := utils.RunCommand("sudo", "sed", "-i", "s/;cgi.fix_pathinfo=1/cgi.fix_pathinfo=0/", "/etc/php/8.4/fpm/php.ini")
-	if err != nil {
-		return err
-	}
+		// Configure PHP-FPM for optimal Laravel performance
+		if err := configurePHPFPM(ctx, cfg, version); err != nil {
+			return err
+		}
 
-	// Increase upload size limit for larger file uploads
-	err = utils.RunCommand("sudo", "sed", "-i", "s/upload_max_filesize = 2M/upload_max_filesize = 64M/", "/etc/php/8.4/fpm/php.ini")
-	if err != nil {
-		return err
+		// Display a PHP version for verification
+		err = utils.RunCommand(ctx, version.Binary(), "-v")
+		if err != nil {
+			return err
+		}
 	}
 
-	// Increase post-size limit to match upload size
-	err = utils.RunCommand("sudo", "sed", "-i", "s/post_max_size = 8M/post_max_size = 64M/", "/etc/php/8.4/fpm/php.ini")
+	// Make the primary site's version the one used by php, composer and artisan
+	err = utils.RunCommand(ctx, "sudo", "update-alternatives", "--set", "php", "/usr/bin/"+cfg.PHPVersion.Binary())
 	if err != nil {
 		return err
 	}
 
-	// Increase execution time for longer-running scripts
-	err = utils.RunCommand("sudo", "sed", "-i", "s/max_execution_time = 30/max_execution_time = 300/", "/etc/php/8.4/fpm/php.ini")
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	// Increase the memory limit for more complex applications
-	err = utils.RunCommand("sudo", "sed", "-i", "s/memory_limit = 128M/memory_limit = 512M/", "/etc/php/8.4/fpm/php.ini")
-	if err != nil {
-		return err
+// joinVersions formats a list of PHP versions for display, e.g. "8.2, 8.4"
+func joinVersions(versions []config.PHPVersion) string {
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = string(v)
 	}
+	return strings.Join(names, ", ")
+}
 
-	// Configure OPcache for better performance
-	utils.PrintStatus("Configuring OPcache for better performance...")
+// configurePHPFPM configures PHP-FPM for optimal Laravel performance
+func configurePHPFPM(ctx context.Context, cfg *config.Config, version config.PHPVersion) error {
+	utils.PrintHeader("Configuring PHP-FPM")
+	utils.PrintStatus("Optimizing PHP configuration for Laravel...")
 
-	// Write OPcache configuration to file
-	err = os.WriteFile("opcache.ini", []byte(templates.OPcacheConfig), 0644)
+	// Apply the php.ini settings through managed drop-ins instead of editing php.ini
+	err := configureINI(ctx, cfg, version)
 	if err != nil {
 		return err
 	}
 
-	// Move OPcache configuration to PHP configuration directory
-	err = utils.RunCommand("sudo", "mv", "opcache.ini", "/etc/php/8.4/fpm/conf.d/10-opcache.ini")
+	// Configure OPcache for better performance, sized for the applications already deployed
+	_, err = writeOPcache(ctx, cfg, version)
 	if err != nil {
 		return err
 	}
 
 	// Restart PHP-FPM to apply changes
-	err = utils.RunCommand("sudo", "systemctl", "restart", "php8.4-fpm")
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", version.FPMService())
 	if err != nil {
 		return err
 	}
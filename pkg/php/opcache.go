@@ -0,0 +1,137 @@
+package php
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// opcacheFile returns the managed OPcache drop-in of a version
+// It sorts after the package's 10-opcache.ini, which loads the extension
+func opcacheFile(version config.PHPVersion) string {
+	return version.ConfigDir() + "/fpm/conf.d/98-laravel-setup-opcache.ini"
+}
+
+// preloadFile returns the generated preload script of a version
+func preloadFile(version config.PHPVersion) string {
+	return version.ConfigDir() + "/fpm/laravel-setup-preload.php"
+}
+
+// writeOPcache writes the OPcache configuration of a version, sized for the applications it serves
+// Reports whether the configuration changed, in which case PHP-FPM must be reloaded
+func writeOPcache(ctx context.Context, cfg *config.Config, version config.PHPVersion) (bool, error) {
+	sites := servedSites(ctx, cfg, version)
+	files := 0
+	for _, site := range sites {
+		files += countPHPFiles(site.WebRoot)
+	}
+	o := cfg.OPcache.Sized(files)
+
+	settings := templates.OPcache{
+		MemoryConsumption:     o.MemoryConsumption,
+		InternedStringsBuffer: o.InternedStringsBuffer,
+		MaxAcceleratedFiles:   o.MaxAcceleratedFiles,
+		ValidateTimestamps:    o.ValidateTimestamps,
+		RevalidateFreq:        o.RevalidateFreq,
+		JIT:                   o.JIT,
+		JITBufferSize:         o.JITBufferSize,
+	}
+
+	// Only one preload script can run per PHP-FPM master, and it applies to every pool of that master,
+	// so it is reserved for a primary site that has its PHP version to itself
+	primary := cfg.PrimarySite()
+	servesPrimary := len(sites) > 0 && sites[0].Domain == primary.Domain
+	shared := len(sites) > 1
+	if o.Preload && servesPrimary && shared {
+		utils.PrintWarning("OPcache preloading is off: other sites also run PHP " + string(version) +
+			" and would get " + primary.Domain + "'s classes preloaded; give the primary site a PHP version of its own")
+	}
+	if o.Preload && servesPrimary && !shared {
+		if _, err := os.Stat(primary.WebRoot + "/vendor/composer/autoload_classmap.php"); err == nil {
+			if err := utils.WriteSystemFile(ctx, preloadFile(version), templates.GetPreloadScript(primary.WebRoot), 0644); err != nil {
+				return false, err
+			}
+			settings.Preload = preloadFile(version)
+			settings.PreloadUser = primary.FPM.User
+		} else {
+			utils.PrintWarning("OPcache preloading starts once Composer dependencies are installed in " + primary.WebRoot)
+		}
+	}
+
+	content := templates.GetOPcacheConfig(settings)
+	current, err := utils.RunCommandWithOutput(ctx, "cat", opcacheFile(version))
+	if err == nil && strings.TrimSpace(current) == strings.TrimSpace(content) {
+		return false, nil
+	}
+
+	utils.PrintStatus(fmt.Sprintf("Configuring OPcache for PHP %s: %d PHP files, %d MiB, %d MiB interned strings, %d max files",
+		version, files, o.MemoryConsumption, o.InternedStringsBuffer, o.MaxAcceleratedFiles))
+
+	// Earlier versions overwrote the package's 10-opcache.ini, which loads the extension; put the link back
+	err = utils.RunCommand(ctx, "sudo", "ln", "-sf", version.ConfigDir()+"/mods-available/opcache.ini", version.ConfigDir()+"/fpm/conf.d/10-opcache.ini")
+	if err != nil {
+		return false, err
+	}
+
+	if err := utils.WriteSystemFile(ctx, opcacheFile(version), content, 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ApplyOPcache resizes OPcache for the primary site's application and reloads PHP-FPM when needed
+// The version is the one serving the site, which php switch may have changed
+// PHP-FPM is reloaded when the configuration changed, or when reset is set to clear the cached scripts
+// A reload is used instead of opcache_reset(), since the CLI has its own cache and can't clear PHP-FPM's
+func ApplyOPcache(ctx context.Context, cfg *config.Config, reset bool) error {
+	version := ServedVersion(ctx, cfg.PrimarySite())
+
+	changed, err := writeOPcache(ctx, cfg, version)
+	if err != nil {
+		return err
+	}
+
+	if !changed && !reset {
+		return nil
+	}
+
+	utils.PrintStatus("Reloading " + version.FPMService() + " to refresh OPcache...")
+	return utils.RunCommand(ctx, "sudo", "systemctl", "reload", version.FPMService())
+}
+
+// servedSites returns the sites a PHP version currently serves, the primary site first if it is one of them
+func servedSites(ctx context.Context, cfg *config.Config, version config.PHPVersion) []config.Site {
+	var sites []config.Site
+	for _, site := range cfg.AllSites() {
+		if ServedVersion(ctx, site) == version {
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+// countPHPFiles counts the PHP files in an application, including its vendor directory
+// A directory that doesn't exist yet has no files
+func countPHPFiles(dir string) int {
+	count := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && (d.Name() == ".git" || d.Name() == "node_modules") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), ".php") {
+			count++
+		}
+		return nil
+	})
+	return count
+}
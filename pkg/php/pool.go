@@ -0,0 +1,128 @@
+package php
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/system"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+)
+
+// defaultPool is the pool shipped with PHP-FPM, disabled once a version serves a site from its own pool
+const defaultPool = "www.conf"
+
+// WritePool writes the dedicated PHP-FPM pool of a site, creating its user and group when missing
+// PHP-FPM must be reloaded afterwards for the pool to start
+func WritePool(ctx context.Context, site config.Site) error {
+	utils.PrintStatus("Writing PHP-FPM pool for " + site.Domain + " (PHP " + string(site.PHPVersion) + ")...")
+
+	if err := system.EnsureAccount(ctx, site.FPM.User, site.FPM.Group); err != nil {
+		return err
+	}
+
+	pool := templates.GetFPMPoolConfig(templates.FPMPool{
+		Name:                    site.Domain,
+		User:                    site.FPM.User,
+		Group:                   site.FPM.Group,
+		Socket:                  site.FPMSocket(),
+		PM:                      site.FPM.PM,
+		MaxChildren:             site.FPM.MaxChildren,
+		StartServers:            site.FPM.StartServers,
+		MinSpareServers:         site.FPM.MinSpareServers,
+		MaxSpareServers:         site.FPM.MaxSpareServers,
+		MaxRequests:             site.FPM.MaxRequests,
+		RequestTerminateTimeout: int(site.FPM.RequestTerminateTimeout.Seconds()),
+		SlowlogTimeout:          int(site.FPM.SlowlogTimeout.Seconds()),
+		Slowlog:                 site.FPMSlowlog(),
+		StatusPath:              config.FPMStatusPath,
+		PingPath:                config.FPMPingPath,
+		OpenBasedir:             site.FPM.OpenBasedir,
+		Env:                     site.FPM.Env,
+	})
+
+	return utils.WriteSystemFile(ctx, site.FPMPoolFile(), pool, 0644)
+}
+
+// RemovePool removes the PHP-FPM pool of a site
+// PHP-FPM must be reloaded afterwards for the pool to stop
+func RemovePool(ctx context.Context, site config.Site) error {
+	return utils.RunCommand(ctx, "sudo", "rm", "-f", site.FPMPoolFile())
+}
+
+// syncDefaultPool disables the default www pool of a version once a site has its own pool
+// The default pool is restored when no site pools are left, since PHP-FPM won't start without a pool
+func syncDefaultPool(ctx context.Context, version config.PHPVersion) error {
+	dir := version.ConfigDir() + "/fpm/pool.d/"
+
+	listing, err := utils.RunCommandWithOutput(ctx, "ls", "-1", dir)
+	if err != nil {
+		return err
+	}
+
+	var sitePools, enabled, disabled bool
+	for _, name := range strings.Split(listing, "\n") {
+		switch name = strings.TrimSpace(name); {
+		case name == defaultPool:
+			enabled = true
+		case name == defaultPool+".disabled":
+			disabled = true
+		case strings.HasSuffix(name, ".conf"):
+			sitePools = true
+		}
+	}
+
+	if sitePools && enabled {
+		utils.PrintStatus("Disabling the default PHP " + string(version) + " www pool...")
+		return utils.RunCommand(ctx, "sudo", "mv", dir+defaultPool, dir+defaultPool+".disabled")
+	}
+	if !sitePools && !enabled && disabled {
+		utils.PrintStatus("Restoring the default PHP " + string(version) + " www pool...")
+		return utils.RunCommand(ctx, "sudo", "mv", dir+defaultPool+".disabled", dir+defaultPool)
+	}
+	return nil
+}
+
+// sizedSites returns every site with its PHP-FPM pool sized for this host's RAM and CPUs
+func sizedSites(cfg *config.Config) ([]config.Site, error) {
+	host, err := system.DetectHost()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.SizedSites(host), nil
+}
+
+// sizedSite returns a site with its PHP-FPM pool sized for this host's RAM and CPUs
+func sizedSite(cfg *config.Config, domain string) (config.Site, error) {
+	sites, err := sizedSites(cfg)
+	if err != nil {
+		return config.Site{}, err
+	}
+	for _, site := range sites {
+		if site.Domain == domain {
+			return site, nil
+		}
+	}
+	return config.Site{}, fmt.Errorf("unknown site %s", domain)
+}
+
+// writeVersionPools writes the pool of every site served by a version and disables the default pool
+func writeVersionPools(ctx context.Context, cfg *config.Config, version config.PHPVersion) error {
+	sites, err := sizedSites(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, site := range sites {
+		if site.PHPVersion != version {
+			continue
+		}
+		if err := WritePool(ctx, site); err != nil {
+			return err
+		}
+	}
+
+	return syncDefaultPool(ctx, version)
+}
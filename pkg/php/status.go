@@ -0,0 +1,92 @@
+package php
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"laravel-setup/pkg/config"
+)
+
+// statusTimeout limits each request to the PHP-FPM status server
+const statusTimeout = 5 * time.Second
+
+// PoolStatus is the status page of a PHP-FPM pool
+type PoolStatus struct {
+	Pool               string `json:"pool"`
+	ProcessManager     string `json:"process manager"`
+	StartSince         int    `json:"start since"`
+	AcceptedConn       int    `json:"accepted conn"`
+	ListenQueue        int    `json:"listen queue"`
+	MaxListenQueue     int    `json:"max listen queue"`
+	ListenQueueLen     int    `json:"listen queue len"`
+	IdleProcesses      int    `json:"idle processes"`
+	ActiveProcesses    int    `json:"active processes"`
+	TotalProcesses     int    `json:"total processes"`
+	MaxActiveProcesses int    `json:"max active processes"`
+	MaxChildrenReached int    `json:"max children reached"`
+	SlowRequests       int    `json:"slow requests"`
+}
+
+// Saturated reports whether requests had to wait for a free worker since the pool started
+func (s PoolStatus) Saturated() bool {
+	return s.MaxChildrenReached > 0 || s.ListenQueue > 0
+}
+
+// Ping checks that a site's PHP-FPM pool answers its ping page
+func Ping(ctx context.Context, site config.Site) error {
+	body, err := getStatusPage(ctx, site.FPMPingURL())
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(body) != "pong" {
+		return fmt.Errorf("unexpected ping response %q", strings.TrimSpace(body))
+	}
+	return nil
+}
+
+// Status returns the status page of a site's PHP-FPM pool
+// The page is read from the localhost-only Nginx status server
+func Status(ctx context.Context, site config.Site) (PoolStatus, error) {
+	var status PoolStatus
+
+	body, err := getStatusPage(ctx, site.FPMStatusURL()+"?json")
+	if err != nil {
+		return status, err
+	}
+
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		return status, fmt.Errorf("failed to parse PHP-FPM status of %s: %w", site.Domain, err)
+	}
+	return status, nil
+}
+
+// getStatusPage fetches a page from the PHP-FPM status server
+func getStatusPage(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return string(body), nil
+}
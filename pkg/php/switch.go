@@ -0,0 +1,220 @@
+package php
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// fastcgiPassPattern matches the PHP-FPM socket in an Nginx vhost
+var fastcgiPassPattern = regexp.MustCompile(`fastcgi_pass unix:(\S+);`)
+
+// socketVersionPattern extracts the PHP version from a PHP-FPM socket path
+var socketVersionPattern = regexp.MustCompile(`php(\d+\.\d+)`)
+
+// workerCommandPattern matches the PHP binary in the queue worker command
+var workerCommandPattern = regexp.MustCompile(`(?m)^command=php\d+\.\d+ `)
+
+// statusVhost is the Nginx server exposing the PHP-FPM status pages of every pool
+var statusVhost = vhostPath(config.FPMStatusSite)
+
+// vhostPath returns the Nginx vhost of a site
+func vhostPath(domain string) string {
+	return "/etc/nginx/sites-available/" + domain
+}
+
+// SiteVersion returns the PHP version currently serving a site, read from its Nginx vhost
+func SiteVersion(ctx context.Context, domain string) (config.PHPVersion, error) {
+	vhost, err := utils.RunCommandWithOutput(ctx, "cat", vhostPath(domain))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Nginx configuration of %s: %w", domain, err)
+	}
+
+	_, version, err := parseFastcgiPass(vhost)
+	return version, err
+}
+
+// ServedVersion returns the PHP version serving a site, which php switch may have changed,
+// or its configured version while its vhost isn't set up yet
+func ServedVersion(ctx context.Context, site config.Site) config.PHPVersion {
+	if version, err := SiteVersion(ctx, site.Domain); err == nil {
+		return version
+	}
+	return site.PHPVersion
+}
+
+// parseFastcgiPass returns the PHP-FPM socket of a vhost and the PHP version it belongs to
+func parseFastcgiPass(vhost string) (string, config.PHPVersion, error) {
+	m := fastcgiPassPattern.FindStringSubmatch(vhost)
+	if m == nil {
+		return "", "", fmt.Errorf("no PHP-FPM socket found in Nginx configuration")
+	}
+
+	v := socketVersionPattern.FindStringSubmatch(m[1])
+	if v == nil {
+		return "", "", fmt.Errorf("can't tell the PHP version of socket %s", m[1])
+	}
+
+	return m[1], config.PHPVersion(v[1]), nil
+}
+
+// Switch changes the PHP version serving a site
+// The version must already be installed with every extension Laravel needs
+// The site's pool moves to the new version, and only the PHP-FPM services of the old and new versions are reloaded
+func Switch(ctx context.Context, cfg *config.Config, domain string, version config.PHPVersion) error {
+	utils.PrintHeader("Switching " + domain + " to PHP " + string(version))
+
+	if err := version.Validate(); err != nil {
+		return err
+	}
+
+	site, ok := cfg.FindSite(domain)
+	if !ok {
+		return fmt.Errorf("unknown site %s; add it to Sites in the configuration file", domain)
+	}
+
+	vhost, err := utils.RunCommandWithOutput(ctx, "cat", vhostPath(site.Domain))
+	if err != nil {
+		return fmt.Errorf("failed to read Nginx configuration of %s: %w", site.Domain, err)
+	}
+
+	oldSocket, current, err := parseFastcgiPass(vhost)
+	if err != nil {
+		return err
+	}
+	if current == version {
+		utils.PrintStatus(site.Domain + " already uses PHP " + string(version))
+		return nil
+	}
+	utils.PrintStatus("Current PHP version: " + string(current))
+
+	// Make sure the new version can run the application before touching the vhost
+	if err := checkInstalled(ctx, version); err != nil {
+		return err
+	}
+	if err := CheckExtensions(ctx, version, siteExtensions(cfg, site)); err != nil {
+		return err
+	}
+
+	// Start the site's pool under the new version before sending requests to it
+	site, err = sizedSite(cfg, site.Domain)
+	if err != nil {
+		return err
+	}
+	previous := site
+	previous.PHPVersion = current
+	site.PHPVersion = version
+	if err := WritePool(ctx, site); err != nil {
+		return err
+	}
+	if err := syncDefaultPool(ctx, version); err != nil {
+		return err
+	}
+	if err := reloadService(ctx, version.FPMService()); err != nil {
+		return err
+	}
+
+	// Point the vhost and the PHP-FPM status server at the new pool's socket
+	originals := map[string]string{vhostPath(site.Domain): vhost}
+	if status, err := utils.RunCommandWithOutput(ctx, "cat", statusVhost); err == nil {
+		originals[statusVhost] = status
+	}
+	for path, content := range originals {
+		updated := strings.ReplaceAll(content, "unix:"+oldSocket+";", "unix:"+site.FPMSocket()+";")
+		if err := utils.WriteSystemFile(ctx, path, updated+"\n", 0644); err != nil {
+			return err
+		}
+	}
+
+	// Restore the previous configuration if Nginx rejects the change
+	utils.PrintStatus("Testing Nginx configuration...")
+	if err := utils.RunCommand(ctx, "sudo", "nginx", "-t"); err != nil {
+		utils.PrintError("Nginx configuration is invalid, restoring the previous configuration")
+		for path, content := range originals {
+			if restoreErr := utils.WriteSystemFile(ctx, path, content+"\n", 0644); restoreErr != nil {
+				return restoreErr
+			}
+		}
+		return err
+	}
+
+	if err := reloadService(ctx, "nginx"); err != nil {
+		return err
+	}
+
+	// Stop the site's pool under the old version now that Nginx no longer uses it
+	if err := RemovePool(ctx, previous); err != nil {
+		return err
+	}
+	if err := syncDefaultPool(ctx, current); err != nil {
+		return err
+	}
+	if err := reloadService(ctx, current.FPMService()); err != nil {
+		return err
+	}
+
+	// The primary site's queue workers run with the site's PHP binary
+	if cfg.IsPrimarySite(site.Domain) {
+		if err := switchWorkers(ctx, version); err != nil {
+			return err
+		}
+	}
+
+	utils.PrintStatus(site.Domain + " now uses PHP " + string(version))
+	utils.PrintWarning("Update the PHP version of " + site.Domain + " in your configuration file so the next setup keeps it")
+	return nil
+}
+
+// reloadService reloads a systemd service
+func reloadService(ctx context.Context, service string) error {
+	utils.PrintStatus("Reloading " + service + "...")
+	return utils.RunCommand(ctx, "sudo", "systemctl", "reload", service)
+}
+
+// switchWorkers points the queue worker command at another PHP binary and restarts the workers
+func switchWorkers(ctx context.Context, version config.PHPVersion) error {
+	const workerConfig = "/etc/supervisor/conf.d/laravel-worker.conf"
+
+	current, err := utils.RunCommandWithOutput(ctx, "cat", workerConfig)
+	if err != nil {
+		// No workers were set up, so there's nothing to switch
+		return nil
+	}
+
+	utils.PrintStatus("Switching queue workers to PHP " + string(version) + "...")
+	updated := workerCommandPattern.ReplaceAllString(current, "command="+version.Binary()+" ")
+	if err := utils.WriteSystemFile(ctx, workerConfig, updated+"\n", 0644); err != nil {
+		return err
+	}
+
+	err = utils.RunCommand(ctx, "sudo", "supervisorctl", "reread")
+	if err != nil {
+		return err
+	}
+
+	return utils.RunCommand(ctx, "sudo", "supervisorctl", "update")
+}
+
+// checkInstalled verifies that PHP-FPM is installed for a version
+func checkInstalled(ctx context.Context, version config.PHPVersion) error {
+	status, err := utils.RunCommandWithOutput(ctx, "dpkg-query", "-W", "-f=${Status}", version.FPMService())
+	if err != nil || !strings.Contains(status, "install ok installed") {
+		return fmt.Errorf("PHP %s is not installed; add it to PHPVersions and run the PHP setup step", version)
+	}
+	return nil
+}
+
+// siteExtensions returns the extensions a site needs: the configured ones and those its application requires
+func siteExtensions(cfg *config.Config, site config.Site) []string {
+	extensions := cfg.AllPHPExtensions()
+
+	required, err := RequiredExtensions(site.WebRoot)
+	if err != nil {
+		utils.PrintWarning("Can't read the extensions required by " + site.Domain + ": " + err.Error())
+	}
+	return append(extensions, required...)
+}
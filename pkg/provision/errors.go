@@ -0,0 +1,57 @@
+package provision
+
+import (
+	"errors"
+
+	"laravel-setup/pkg/utils"
+)
+
+// Errors returned by Run before any step starts
+var (
+	ErrRunningAsRoot = errors.New("this script should not be run as root for security reasons")
+	ErrNoSudo        = errors.New("this user doesn't have sudo privileges")
+	ErrAborted       = errors.New("setup aborted before it started")
+)
+
+// StepError reports which setup step failed
+// Use errors.As to retrieve it and errors.Is / errors.As on it to inspect the cause
+type StepError struct {
+	// StepID is the ID of the failed step
+	StepID string
+	// Step is the human-readable name of the failed step
+	Step string
+	// Err is the error returned by the step
+	Err error
+}
+
+func (e *StepError) Error() string {
+	return "step " + e.Step + " failed: " + e.Err.Error()
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// redactedError reports an error with the secrets in its text redacted
+// It unwraps to the original, so errors.Is and errors.As still see the cause
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError returns err with the recorded secrets redacted from its text, or err when there are none in it
+func redactError(err error) error {
+	msg := utils.Redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
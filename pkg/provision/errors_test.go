@@ -0,0 +1,29 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"laravel-setup/pkg/utils"
+)
+
+func TestRedactError(t *testing.T) {
+	utils.AddSecret("hunter2-Secret")
+
+	cause := fmt.Errorf("ALTER USER failed: %w", context.DeadlineExceeded)
+	leaky := fmt.Errorf("mysql -e \"IDENTIFIED BY 'hunter2-Secret'\": %w", cause)
+	err := redactError(leaky)
+	if want := `mysql -e "IDENTIFIED BY '********'": ALTER USER failed: context deadline exceeded`; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("errors.Is(err, context.DeadlineExceeded) = false, want the cause to be kept")
+	}
+
+	clean := errors.New("apt failed")
+	if got := redactError(clean); got != clean {
+		t.Errorf("redactError(%v) = %v, want the error unchanged", clean, got)
+	}
+}
@@ -0,0 +1,86 @@
+package provision
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventType identifies what happened during a run
+type EventType string
+
+// Event types emitted by Run
+const (
+	EventStepStarted     EventType = "step_started"
+	EventStepCompleted   EventType = "step_completed"
+	EventStepSkipped     EventType = "step_skipped"
+	EventStepFailed      EventType = "step_failed"
+	EventCommandExecuted EventType = "command_executed"
+	EventFileChanged     EventType = "file_changed"
+	EventRunFinished     EventType = "run_finished"
+)
+
+// Event describes a point in the lifecycle of a run
+type Event struct {
+	Type EventType
+	// StepID and Step identify the step the event belongs to, empty for run-level events
+	StepID string
+	Step   string
+	Time   time.Time
+	// Duration is how long the step, command or run took
+	Duration time.Duration
+	// Err is the failure, if any
+	Err error
+	// Metadata carries event-specific details such as the command line or the file path
+	Metadata map[string]string
+}
+
+// eventJSON is the wire format of an Event
+type eventJSON struct {
+	Type       EventType         `json:"type"`
+	StepID     string            `json:"step_id,omitempty"`
+	Step       string            `json:"step,omitempty"`
+	Time       time.Time         `json:"time"`
+	DurationMS int64             `json:"duration_ms,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// MarshalJSON encodes the event with the duration in milliseconds and the error as a string
+func (e Event) MarshalJSON() ([]byte, error) {
+	out := eventJSON{
+		Type:       e.Type,
+		StepID:     e.StepID,
+		Step:       e.Step,
+		Time:       e.Time,
+		DurationMS: e.Duration.Milliseconds(),
+		Metadata:   e.Metadata,
+	}
+	if e.Err != nil {
+		out.Error = e.Err.Error()
+	}
+	return json.Marshal(out)
+}
+
+// Observer is notified of every event of a run
+// Notify is called synchronously from the run, so slow observers slow the setup down
+type Observer interface {
+	Notify(Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface
+type ObserverFunc func(Event)
+
+// Notify calls f(e)
+func (f ObserverFunc) Notify(e Event) {
+	f(e)
+}
+
+// MultiObserver passes every event to each of its observers in order
+type MultiObserver []Observer
+
+// Notify passes the event to each observer
+func (m MultiObserver) Notify(e Event) {
+	for _, o := range m {
+		o.Notify(e)
+	}
+}
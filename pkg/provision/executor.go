@@ -0,0 +1,118 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"laravel-setup/pkg/utils"
+)
+
+// observedExecutor wraps an Executor and reports every command and file write to the provisioner
+type observedExecutor struct {
+	utils.Executor
+	p *Provisioner
+}
+
+func (e *observedExecutor) Run(ctx context.Context, command string, args ...string) error {
+	started := time.Now()
+	err := e.Executor.Run(ctx, command, args...)
+	e.command(started, err, "run", command, args)
+	return err
+}
+
+func (e *observedExecutor) Output(ctx context.Context, command string, args ...string) (string, error) {
+	started := time.Now()
+	output, err := e.Executor.Output(ctx, command, args...)
+	e.command(started, err, "output", command, args)
+	return output, err
+}
+
+func (e *observedExecutor) RunWithInput(ctx context.Context, input []byte, command string, args ...string) error {
+	started := time.Now()
+	err := e.Executor.RunWithInput(ctx, input, command, args...)
+	e.command(started, err, "input", command, args)
+	return err
+}
+
+func (e *observedExecutor) RunInteractive(ctx context.Context, command string, args ...string) error {
+	started := time.Now()
+	err := e.Executor.RunInteractive(ctx, command, args...)
+	e.command(started, err, "interactive", command, args)
+	return err
+}
+
+func (e *observedExecutor) WriteFile(ctx context.Context, path string, data []byte, perm os.FileMode) error {
+	started := time.Now()
+	err := e.Executor.WriteFile(ctx, path, data, perm)
+	e.p.emitStep(Event{
+		Type:     EventFileChanged,
+		Time:     time.Now(),
+		Duration: time.Since(started),
+		Err:      err,
+		Metadata: map[string]string{
+			"path": path,
+			"mode": fmt.Sprintf("%04o", perm.Perm()),
+			"size": strconv.Itoa(len(data)),
+		},
+	})
+	return err
+}
+
+// command emits a command_executed event for a finished command
+func (e *observedExecutor) command(started time.Time, err error, mode, command string, args []string) {
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		exitCode = -1
+	}
+
+	e.p.emitStep(Event{
+		Type:     EventCommandExecuted,
+		Time:     time.Now(),
+		Duration: time.Since(started),
+		Err:      err,
+		Metadata: map[string]string{
+			"command":   strings.TrimSpace(command + " " + strings.Join(args, " ")),
+			"mode":      mode,
+			"exit_code": strconv.Itoa(exitCode),
+		},
+	})
+}
+
+// timeoutExecutor wraps an Executor and limits how long each non-interactive command may run
+type timeoutExecutor struct {
+	utils.Executor
+	timeout time.Duration
+}
+
+func (e *timeoutExecutor) Run(ctx context.Context, command string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	return e.Executor.Run(ctx, command, args...)
+}
+
+func (e *timeoutExecutor) Output(ctx context.Context, command string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	return e.Executor.Output(ctx, command, args...)
+}
+
+func (e *timeoutExecutor) RunWithInput(ctx context.Context, input []byte, command string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	return e.Executor.RunWithInput(ctx, input, command, args...)
+}
+
+func (e *timeoutExecutor) WriteFile(ctx context.Context, path string, data []byte, perm os.FileMode) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	return e.Executor.WriteFile(ctx, path, data, perm)
+}
@@ -0,0 +1,294 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/state"
+	"laravel-setup/pkg/utils"
+)
+
+// Options controls how a Provisioner runs the setup
+// Nil fields fall back to running locally and talking to the terminal
+type Options struct {
+	// Executor runs the external commands issued by the steps
+	Executor utils.Executor
+	// Prompter answers the questions asked during the setup
+	Prompter utils.Prompter
+	// Logger receives progress messages
+	Logger utils.Logger
+	// Observer is notified of every lifecycle event of the run
+	Observer Observer
+	// State records the outcome of each step when set
+	State *state.State
+	// Skip lists the IDs of steps to skip, in addition to the skip flags in the config
+	Skip map[string]bool
+	// SkipPreflight disables the root and sudo checks
+	SkipPreflight bool
+	// Confirm prints the plan and waits for the user before the first step
+	Confirm bool
+	// OfferRollback asks through the prompter whether to roll back a step that was
+	// interrupted or timed out, for steps that support it
+	OfferRollback bool
+}
+
+// Provisioner runs the setup steps against a configuration
+// Run installs the Provisioner's executor, prompter and logger as the process-wide ones used by
+// the utils package, so a run is exclusive to the process: runs of different Provisioners don't
+// proceed concurrently but one after another, and other code in the process shares their output
+type Provisioner struct {
+	opts Options
+
+	// current is the step being run, used to attribute command and file events
+	current *Step
+}
+
+// rollbackTimeout limits how long rolling back an interrupted step may take
+const rollbackTimeout = 5 * time.Minute
+
+// runMu serializes runs, since the executor, prompter and logger are process-wide
+var runMu sync.Mutex
+
+// New creates a Provisioner with the given options
+func New(opts Options) *Provisioner {
+	return &Provisioner{opts: opts}
+}
+
+// Run provisions the server described by cfg
+// Missing settings are filled in through the prompter before the first step runs
+// A failing step stops the run and is returned as a *StepError
+// Cancelling ctx stops the running command and the run; the step error then wraps ctx.Err()
+// Only one Run can be in progress per process; concurrent calls wait for each other
+func (p *Provisioner) Run(ctx context.Context, cfg *config.Config) (err error) {
+	runMu.Lock()
+	defer runMu.Unlock()
+
+	restore := p.install(cfg)
+	defer restore()
+
+	started := time.Now()
+	defer func() {
+		p.emit(Event{Type: EventRunFinished, Time: time.Now(), Duration: time.Since(started), Err: err})
+	}()
+
+	if !p.opts.SkipPreflight {
+		// These are security checks to ensure the script is run correctly
+		if !utils.CheckNotRoot(ctx) {
+			return ErrRunningAsRoot
+		}
+		if !utils.CheckSudoPrivileges(ctx) {
+			return ErrNoSudo
+		}
+	}
+
+	if err := config.Complete(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to initialize configuration: %w", err)
+	}
+	// Passwords generated or read from the vault later in the run are recorded as they are
+	utils.AddSecret(
+		cfg.DBPassword, cfg.DBRootPassword, cfg.Database.HostAdminPassword, cfg.Redis.Password,
+		cfg.Backup.Remote.SecretAccessKey, cfg.Backup.Remote.Passphrase,
+	)
+
+	utils.PrintStatus("Setting up server for domain: " + cfg.Domain)
+	utils.PrintStatus("Running as user: " + os.Getenv("USER"))
+
+	if p.opts.Confirm {
+		utils.PrintHeader("Starting Laravel Server Setup Process")
+		utils.PrintStatus("This script will set up a complete Laravel production server")
+		PrintPlan(cfg, p.opts.Skip)
+
+		if _, err := utils.Prompt("Press Enter to begin the setup process..."); err != nil {
+			return ErrAborted
+		}
+	}
+
+	// Run each step of the setup process, skipping those that the user has opted to skip
+	for _, s := range steps {
+		if err := ctx.Err(); err != nil {
+			return &StepError{StepID: s.ID, Step: s.Name, Err: err}
+		}
+
+		if s.skipped(cfg, p.opts.Skip) {
+			utils.PrintStatus("Skipping " + s.Name + " step as requested")
+			p.record(s, state.StatusSkipped, nil)
+			p.emit(Event{Type: EventStepSkipped, StepID: s.ID, Step: s.Name, Time: time.Now()})
+			continue
+		}
+
+		if err := p.runStep(ctx, s, cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runStep runs a single step, recording and reporting its outcome
+func (p *Provisioner) runStep(ctx context.Context, s Step, cfg *config.Config) error {
+	utils.PrintHeader("Running " + s.Name)
+	p.emit(Event{Type: EventStepStarted, StepID: s.ID, Step: s.Name, Time: time.Now()})
+
+	p.current = &s
+	defer func() { p.current = nil }()
+
+	stepCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout := cfg.Timeouts.StepTimeout(s.ID); timeout > 0 {
+		stepCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	started := time.Now()
+	err := s.Run(stepCtx, cfg)
+	duration := time.Since(started)
+	interrupted := stepCtx.Err()
+	cancel()
+
+	if err != nil && interrupted != nil {
+		// Report why the step stopped rather than how the killed command exited
+		if !errors.Is(err, interrupted) {
+			err = fmt.Errorf("%w: %v", interrupted, err)
+		}
+		p.record(s, state.StatusInterrupted, err)
+		p.emit(Event{Type: EventStepFailed, StepID: s.ID, Step: s.Name, Time: time.Now(), Duration: duration, Err: err})
+		p.offerRollback(ctx, s, cfg)
+		return &StepError{StepID: s.ID, Step: s.Name, Err: err}
+	}
+
+	if err != nil {
+		p.record(s, state.StatusFailed, err)
+		p.emit(Event{Type: EventStepFailed, StepID: s.ID, Step: s.Name, Time: time.Now(), Duration: duration, Err: err})
+		return &StepError{StepID: s.ID, Step: s.Name, Err: err}
+	}
+
+	p.record(s, state.StatusCompleted, nil)
+	p.emit(Event{Type: EventStepCompleted, StepID: s.ID, Step: s.Name, Time: time.Now(), Duration: duration})
+	return nil
+}
+
+// offerRollback asks whether to undo an interrupted step and runs its rollback if confirmed
+// The rollback gets a fresh context, since the run's context may already be cancelled
+func (p *Provisioner) offerRollback(ctx context.Context, s Step, cfg *config.Config) {
+	if !p.opts.OfferRollback {
+		return
+	}
+	if s.Rollback == nil {
+		utils.PrintWarning("The " + s.Name + " step can't be rolled back automatically; it may be partially applied")
+		return
+	}
+
+	rollback, err := utils.Confirm("The " + s.Name + " step was interrupted. Roll back its changes?")
+	if err != nil || !rollback {
+		utils.PrintWarning("The " + s.Name + " step may be partially applied")
+		return
+	}
+
+	rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+
+	if err := s.Rollback(rollbackCtx, cfg); err != nil {
+		utils.PrintError("Failed to roll back " + s.Name + ": " + err.Error())
+		return
+	}
+	p.record(s, state.StatusRolledBack, nil)
+}
+
+// install swaps in the executor, prompter and logger from the options
+// Commands are limited by the configured command timeout and reported to the observer
+// Returns a function that restores the previous ones
+func (p *Provisioner) install(cfg *config.Config) func() {
+	var restores []func()
+
+	executor := p.opts.Executor
+	if executor == nil {
+		executor = utils.GetExecutor()
+	}
+	if cfg.Timeouts.Command > 0 {
+		executor = &timeoutExecutor{Executor: executor, timeout: cfg.Timeouts.Command}
+	}
+	if p.opts.Observer != nil {
+		executor = &observedExecutor{Executor: executor, p: p}
+	}
+	previous := utils.SetExecutor(executor)
+	restores = append(restores, func() { utils.SetExecutor(previous) })
+
+	if p.opts.Prompter != nil {
+		previous := utils.SetPrompter(p.opts.Prompter)
+		restores = append(restores, func() { utils.SetPrompter(previous) })
+	}
+	if p.opts.Logger != nil {
+		previous := utils.SetLogger(p.opts.Logger)
+		restores = append(restores, func() { utils.SetLogger(previous) })
+	}
+
+	return func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
+// record saves the outcome of a step to the state file, if one is configured
+func (p *Provisioner) record(s Step, status string, stepErr error) {
+	if p.opts.State == nil {
+		return
+	}
+
+	if stepErr != nil {
+		stepErr = redactError(stepErr)
+	}
+	p.opts.State.SetStep(s.Name, status, stepErr)
+	if err := p.opts.State.Save(); err != nil {
+		utils.PrintWarning("Failed to save state: " + err.Error())
+	}
+}
+
+// emit redacts secrets from an event's error and passes the event to the observer, if one is configured
+func (p *Provisioner) emit(e Event) {
+	if p.opts.Observer == nil {
+		return
+	}
+	if e.Err != nil {
+		e.Err = redactError(e.Err)
+	}
+	p.opts.Observer.Notify(e)
+}
+
+// emitStep attributes an event to the step being run, redacts secrets from it and emits it
+func (p *Provisioner) emitStep(e Event) {
+	if p.current != nil {
+		e.StepID = p.current.ID
+		e.Step = p.current.Name
+	}
+	for key, value := range e.Metadata {
+		e.Metadata[key] = utils.Redact(value)
+	}
+	p.emit(e)
+}
+
+// PrintPlan prints the numbered list of setup steps and whether each will run
+func PrintPlan(cfg *config.Config, skip map[string]bool) {
+	utils.PrintStatus("The setup process is divided into several steps:")
+	for i, s := range steps {
+		skipped := s.skipped(cfg, skip)
+		utils.PrintStatus(fmt.Sprintf("%d. %s%s", i+1, s.Description, getSkipStatus(skipped)))
+		if s.Details != nil && !skipped {
+			for _, detail := range s.Details(cfg) {
+				utils.PrintStatus("   - " + detail)
+			}
+		}
+	}
+	utils.PrintStatus("")
+}
+
+// getSkipStatus returns a string indicating whether a step will be skipped
+func getSkipStatus(skip bool) string {
+	if skip {
+		return " (will be skipped)"
+	}
+	return ""
+}
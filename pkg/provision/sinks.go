@@ -0,0 +1,93 @@
+package provision
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"laravel-setup/pkg/utils"
+)
+
+// JSONObserver writes every event as a line of JSON
+type JSONObserver struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONObserver creates an observer that writes JSON lines to w
+func NewJSONObserver(w io.Writer) *JSONObserver {
+	return &JSONObserver{enc: json.NewEncoder(w)}
+}
+
+// Notify writes the event as a line of JSON
+func (o *JSONObserver) Notify(e Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.enc.Encode(e); err != nil {
+		utils.PrintWarning("Failed to write event: " + err.Error())
+	}
+}
+
+// FileObserver appends every event as a line of JSON to a file
+type FileObserver struct {
+	*JSONObserver
+	file *os.File
+}
+
+// NewFileObserver opens the file for appending and creates an observer writing JSON lines to it
+func NewFileObserver(path string) (*FileObserver, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileObserver{JSONObserver: NewJSONObserver(file), file: file}, nil
+}
+
+// Close closes the underlying file
+func (o *FileObserver) Close() error {
+	return o.file.Close()
+}
+
+// WebhookObserver POSTs every event as JSON to a URL
+// Delivery failures are reported as warnings and never fail the run
+type WebhookObserver struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookObserver creates an observer that POSTs events to url with a short timeout
+func NewWebhookObserver(url string) *WebhookObserver {
+	return &WebhookObserver{URL: url, Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Notify POSTs the event to the webhook URL
+func (o *WebhookObserver) Notify(e Event) {
+	if err := o.post(e); err != nil {
+		utils.PrintWarning("Failed to deliver event to webhook: " + err.Error())
+	}
+}
+
+func (o *WebhookObserver) post(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.Client.Post(o.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
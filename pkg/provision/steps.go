@@ -0,0 +1,263 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"laravel-setup/pkg/backup"
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/database"
+	"laravel-setup/pkg/laravel"
+	"laravel-setup/pkg/nginx"
+	"laravel-setup/pkg/php"
+	"laravel-setup/pkg/redis"
+	"laravel-setup/pkg/security"
+	"laravel-setup/pkg/services"
+	"laravel-setup/pkg/system"
+)
+
+// Step is a single stage of the setup process
+type Step struct {
+	// ID identifies the step in Options.Skip, in timeouts and in the CLI's --skip-<id> flags
+	ID string
+	// Name is the human-readable name of the step
+	Name string
+	// Description is shown in the setup plan
+	Description string
+	// Details returns extra lines shown under the step in the setup plan, nil if there are none
+	Details func(*config.Config) []string
+	// Run performs the step
+	Run func(context.Context, *config.Config) error
+	// Rollback undoes a partially applied step, nil if the step can't be undone
+	Rollback func(context.Context, *config.Config) error
+	// Skip reports whether the configuration file asks to skip the step
+	Skip func(*config.Config) bool
+}
+
+// steps lists the setup steps in the order they run
+var steps = []Step{
+	{
+		ID:          "system-update",
+		Name:        "System Update",
+		Description: "System update",
+		Run:         system.Update,
+		Skip:        func(c *config.Config) bool { return c.SkipSystemUpdate },
+	},
+	{
+		ID:          "essentials",
+		Name:        "Install Essentials",
+		Description: "Installing essential packages",
+		Run:         system.InstallEssentials,
+		Skip:        func(c *config.Config) bool { return c.SkipEssentials },
+	},
+	{
+		ID:          "php",
+		Name:        "Install PHP",
+		Description: "Installing PHP and extensions",
+		Details:     phpDetails,
+		Run:         php.Install,
+		Skip:        func(c *config.Config) bool { return c.SkipPHP },
+	},
+	{
+		ID:          "mysql",
+		Name:        "Install Database",
+		Description: "Installing and configuring the database server",
+		Details:     databaseDetails,
+		Run:         database.Install,
+		Skip:        func(c *config.Config) bool { return c.SkipMySQL },
+	},
+	{
+		ID:          "redis",
+		Name:        "Install Redis",
+		Description: "Installing and configuring Redis",
+		Details:     redisDetails,
+		Run:         redis.Install,
+		Skip:        func(c *config.Config) bool { return c.SkipRedis },
+	},
+	{
+		ID:          "nginx",
+		Name:        "Install Nginx",
+		Description: "Installing and configuring Nginx",
+		Run:         nginx.Install,
+		Rollback:    nginx.Rollback,
+		Skip:        func(c *config.Config) bool { return c.SkipNginx },
+	},
+	{
+		ID:          "security",
+		Name:        "Configure Security",
+		Description: "Configuring security (firewall, fail2ban, SSH)",
+		Run:         security.Configure,
+		Rollback:    security.Rollback,
+		Skip:        func(c *config.Config) bool { return c.SkipSecurity },
+	},
+	{
+		ID:          "laravel",
+		Name:        "Setup Laravel",
+		Description: "Setting up Laravel application",
+		Details:     laravelDetails,
+		Run:         laravel.Setup,
+		Rollback:    laravel.Rollback,
+		Skip:        func(c *config.Config) bool { return c.SkipLaravel },
+	},
+	{
+		ID:          "services",
+		Name:        "Configure Services",
+		Description: "Configuring and starting services",
+		Run:         services.Configure,
+		Skip:        func(c *config.Config) bool { return c.SkipServices },
+	},
+	{
+		ID:          "backup",
+		Name:        "Schedule Backups",
+		Description: "Scheduling database backups",
+		Details:     backupDetails,
+		Run:         backup.Schedule,
+		Rollback:    backup.Unschedule,
+		Skip:        func(c *config.Config) bool { return c.SkipBackup },
+	},
+}
+
+// Steps returns the setup steps in the order they run
+func Steps() []Step {
+	return append([]Step(nil), steps...)
+}
+
+// skipped reports whether a step will be skipped
+// Skips requested in the options are combined with the skip flags from the config file
+func (s Step) skipped(cfg *config.Config, skip map[string]bool) bool {
+	return skip[s.ID] || s.Skip(cfg)
+}
+
+// phpDetails lists the PHP versions installed and the version of each site
+func phpDetails(cfg *config.Config) []string {
+	var details []string
+	for _, site := range cfg.AllSites() {
+		if site.Domain != "" {
+			details = append(details, "PHP "+string(site.PHPVersion)+" for "+site.Domain)
+		}
+	}
+	for _, version := range cfg.AllPHPVersions() {
+		details = append(details, "PHP "+string(version)+" installed")
+	}
+	details = append(details, "Extensions: "+strings.Join(cfg.AllPHPExtensions(), ", "))
+	return append(details, fpmDetails(cfg)...)
+}
+
+// fpmDetails shows the PHP-FPM pool of each site as sized for this host
+func fpmDetails(cfg *config.Config) []string {
+	host, err := system.DetectHost()
+	if err != nil {
+		return []string{"PHP-FPM pools can't be sized: " + err.Error()}
+	}
+
+	memory := cfg.ResolveMemory(host.MemoryMB)
+	details := []string{fmt.Sprintf("Host: %d MiB RAM, %d CPUs; reserved database %d MiB, Redis %d MiB, system %d MiB; %d MiB per PHP-FPM worker",
+		host.MemoryMB, host.CPUs, memory.Database, memory.Redis, memory.System, memory.FPMWorker)}
+
+	for _, site := range cfg.SizedSites(host) {
+		if site.Domain != "" {
+			details = append(details, "PHP-FPM pool "+site.Domain+": "+site.FPM.Describe())
+		}
+	}
+	return details
+}
+
+// laravelDetails shows what is loaded into the database after the application is installed
+func laravelDetails(cfg *config.Config) []string {
+	var details []string
+	if cfg.Import.Source != "" {
+		details = append(details, "Import "+cfg.Import.Source+" into "+cfg.DBName+" if it has no tables")
+	}
+	if cfg.Import.SkipMigrate {
+		details = append(details, "Migrations skipped")
+	}
+	if cfg.Import.SeedClass != "" {
+		details = append(details, "Seed with "+cfg.Import.SeedClass+" if "+cfg.DBName+" has no tables")
+	}
+	return details
+}
+
+// redisDetails shows where Redis runs and how the local server is configured
+func redisDetails(cfg *config.Config) []string {
+	r := cfg.Redis
+	if r.External() {
+		return []string{fmt.Sprintf("Redis at %s:%d, not installed here", r.Host, r.PortNumber())}
+	}
+
+	listen := fmt.Sprintf("Listening on 127.0.0.1:%d", r.PortNumber())
+	if r.Socket {
+		listen = "Listening on " + config.RedisSocket + " only, for the redis group"
+	}
+	auth := "No password"
+	switch {
+	case r.User != "":
+		auth = "ACL user " + r.User + ", default user turned off"
+	case r.Password != "":
+		auth = "Password required"
+	}
+	details := []string{listen, auth, "Persistence: " + r.PersistenceMode()}
+
+	memory := fmt.Sprintf("maxmemory %d MiB", r.MaxMemoryMB)
+	if r.MaxMemoryMB == 0 {
+		host, err := system.DetectHost()
+		if err != nil {
+			return append(details, "Redis can't be sized: "+err.Error())
+		}
+		memory = fmt.Sprintf("maxmemory %d MiB", r.MaxMemory(cfg.ResolveMemory(host.MemoryMB).Redis))
+	}
+	return append(details, memory+", "+r.Policy()+" eviction")
+}
+
+// backupDetails shows where and when the database is backed up
+func backupDetails(cfg *config.Config) []string {
+	b := cfg.Backup.WithDefaults()
+	details := []string{
+		"Schedule: " + b.Schedule + " (systemd timer laravel-setup-backup.timer)",
+		fmt.Sprintf("Dumps in %s, keeping %d daily and %d weekly", b.Dir, b.KeepDaily, b.KeepWeekly),
+	}
+	if b.Remote.Enabled() {
+		encryption := "unencrypted"
+		if b.Remote.Passphrase != "" {
+			encryption = "encrypted on the host"
+		}
+		details = append(details, fmt.Sprintf("Uploaded with storage/app to bucket %s/%s, %s, kept %d days",
+			b.Remote.Bucket, b.Remote.Prefix, encryption, b.Remote.KeepDays))
+	}
+	if cfg.ConfigPath == "" {
+		details = append(details, "Not scheduled: needs a configuration file")
+	}
+	return details
+}
+
+// databaseDetails shows the database engine, the Laravel connection and the accounts
+func databaseDetails(cfg *config.Config) []string {
+	details := []string{
+		fmt.Sprintf("Engine: %s (service %s, port %d)", cfg.Database.DisplayName(), cfg.Database.Service(), cfg.Database.PortNumber()),
+		"Database " + cfg.DBName + ", DB_CONNECTION=" + cfg.Database.LaravelConnection(),
+	}
+	if cfg.Database.External() {
+		details[0] = fmt.Sprintf("Engine: managed %s at %s:%d, only the client is installed",
+			cfg.Database.DisplayName(), cfg.Database.Host, cfg.Database.PortNumber())
+		if cfg.Database.SSLCA != "" {
+			details = append(details, "TLS verified with "+cfg.Database.SSLCA)
+		}
+		if cfg.Database.HostAdminUser == "" {
+			details = append(details, "No admin credentials: the database and "+cfg.DBUser+" must exist")
+		} else {
+			details = append(details, "Database and accounts created as "+cfg.Database.HostAdminUser)
+		}
+	}
+	for _, account := range cfg.DatabaseAccounts() {
+		details = append(details, "User "+account.User+": "+account.Profile+" profile")
+	}
+
+	if cfg.Database.IsMySQL() && !cfg.Database.External() {
+		host, err := system.DetectHost()
+		if err != nil {
+			return append(details, cfg.Database.DisplayName()+" can't be sized: "+err.Error())
+		}
+		details = append(details, cfg.Database.DisplayName()+" sized for "+cfg.MySQLTuning(host).Describe())
+	}
+	return details
+}
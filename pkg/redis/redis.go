@@ -0,0 +1,173 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/system"
+	"laravel-setup/pkg/templates"
+	"laravel-setup/pkg/utils"
+	"laravel-setup/pkg/vault"
+)
+
+// PasswordSecret is the name of the password Laravel authenticates to Redis with in the vault
+const PasswordSecret = "redis/password"
+
+// Where the server's configuration lives
+const (
+	configFile  = "/etc/redis/redis.conf"
+	includeFile = "/etc/redis/laravel-setup.conf"
+)
+
+// versionPattern finds the version in the output of redis-server --version, e.g. "v=7.0.15"
+var versionPattern = regexp.MustCompile(`v=(\d+)\.(\d+)`)
+
+// Install installs and configures the Redis server for the cache, sessions and queues,
+// or only keeps the password of a Redis server elsewhere
+func Install(ctx context.Context, cfg *config.Config) error {
+	if cfg.Redis.Password != "" {
+		if err := vault.Store(ctx, cfg, PasswordSecret, cfg.Redis.Password); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Redis.External() {
+		utils.PrintStatus(fmt.Sprintf("Redis runs at %s:%d, not configuring a local server", cfg.Redis.Host, cfg.Redis.PortNumber()))
+		return nil
+	}
+
+	utils.PrintHeader("Installing Redis")
+	err := utils.RunCommand(ctx, "sudo", "apt", "install", "-y", "redis-server")
+	if err != nil {
+		return err
+	}
+
+	utils.PrintStatus("Writing Redis configuration...")
+	host, err := system.DetectHost()
+	if err != nil {
+		return err
+	}
+	settings := templates.RedisSettings{
+		MaxMemoryMB:    cfg.Redis.MaxMemory(cfg.ResolveMemory(host.MemoryMB).Redis),
+		EvictionPolicy: cfg.Redis.Policy(),
+		Port:           cfg.Redis.PortNumber(),
+		Persistence:    cfg.Redis.PersistenceMode(),
+		Password:       cfg.Redis.Password,
+		User:           cfg.Redis.User,
+	}
+	if cfg.Redis.Socket {
+		settings.Socket = config.RedisSocket
+	}
+	if settings.User != "" {
+		// Channel permissions, and the &* that grants them all, came with Redis 6.2
+		major, minor, err := serverVersion(ctx)
+		if err != nil {
+			return err
+		}
+		settings.AllChannels = major > 6 || major == 6 && minor >= 2
+	}
+	utils.PrintStatus(fmt.Sprintf("Limiting Redis to %d MiB with %s eviction, %s persistence",
+		settings.MaxMemoryMB, settings.EvictionPolicy, settings.Persistence))
+
+	// The file holds the password, so only root and the redis group may read it
+	if err := utils.WriteSystemFile(ctx, includeFile, templates.GetRedisConfig(settings), 0640); err != nil {
+		return err
+	}
+	if err := utils.RunCommand(ctx, "sudo", "chown", "root:redis", includeFile); err != nil {
+		return err
+	}
+	if err := includeSettings(ctx); err != nil {
+		return err
+	}
+
+	if cfg.Redis.Socket {
+		if err := grantSocket(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "enable", "redis-server")
+	if err != nil {
+		return err
+	}
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", "redis-server")
+	if err != nil {
+		return err
+	}
+	if err := checkServer(ctx, cfg); err != nil {
+		return err
+	}
+
+	utils.PrintStatus("Redis configured successfully")
+	return nil
+}
+
+// includeSettings includes the managed settings at the end of redis.conf, where they override the package's defaults
+func includeSettings(ctx context.Context) error {
+	line := "include " + includeFile
+	if _, err := utils.RunCommandWithOutput(ctx, "sudo", "grep", "-qxF", line, configFile); err == nil {
+		return nil
+	}
+	return utils.RunCommand(ctx, "sudo", "sed", "-i", "$a "+line, configFile)
+}
+
+// grantSocket adds the users of the PHP-FPM pools and the deploy user to the redis group, which may use the socket
+// Processes started before, such as this shell, only get the group on their next login
+func grantSocket(ctx context.Context, cfg *config.Config) error {
+	var users []string
+	seen := map[string]bool{}
+	for _, site := range cfg.AllSites() {
+		users = append(users, site.FPM.User)
+	}
+	if user := os.Getenv("USER"); user != "" && user != "root" {
+		users = append(users, user)
+	}
+	for _, user := range users {
+		if seen[user] {
+			continue
+		}
+		seen[user] = true
+		utils.PrintStatus("Allowing " + user + " to connect to " + config.RedisSocket + "...")
+		if err := utils.RunCommand(ctx, "sudo", "usermod", "-aG", "redis", user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serverVersion returns the major and minor version of the installed redis-server
+func serverVersion(ctx context.Context) (int, int, error) {
+	output, err := utils.RunCommandWithOutput(ctx, "redis-server", "--version")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get the Redis version: %w", err)
+	}
+	m := versionPattern.FindStringSubmatch(output)
+	if m == nil {
+		return 0, 0, fmt.Errorf("failed to get the Redis version: unexpected output %q", output)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return major, minor, nil
+}
+
+// checkServer checks that the restarted server answers where Laravel connects to it
+// A server requiring a password answers without one that authentication is required, which is enough here
+func checkServer(ctx context.Context, cfg *config.Config) error {
+	args := []string{"redis-cli", "-h", "127.0.0.1", "-p", strconv.Itoa(cfg.Redis.PortNumber()), "ping"}
+	if cfg.Redis.Socket {
+		args = []string{"redis-cli", "-s", config.RedisSocket, "ping"}
+	}
+	output, err := utils.RunCommandWithOutput(ctx, "sudo", args...)
+	if err == nil && (strings.Contains(output, "PONG") || strings.Contains(output, "NOAUTH")) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("redis doesn't answer at %s: %w", cfg.Redis.HostName(), err)
+	}
+	return fmt.Errorf("redis doesn't answer at %s: %s", cfg.Redis.HostName(), output)
+}
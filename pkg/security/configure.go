@@ -1,27 +1,26 @@
 package security
 
 import (
-	"os"
-
+	"context"
 	"laravel-setup/pkg/config"
 	"laravel-setup/pkg/templates"
 	"laravel-setup/pkg/utils"
 )
 
 // Configure sets up security measures for the server
-func Configure(config *config.Config) error {
+func Configure(ctx context.Context, cfg *config.Config) error {
 	// Configure firewall
-	if err := configureFirewall(config); err != nil {
+	if err := configureFirewall(ctx, cfg); err != nil {
 		return err
 	}
 
 	// Configure fail2ban
-	if err := configureFail2ban(config); err != nil {
+	if err := configureFail2ban(ctx, cfg); err != nil {
 		return err
 	}
 
 	// Configure SSH
-	if err := configureSSH(config); err != nil {
+	if err := configureSSH(ctx, cfg); err != nil {
 		return err
 	}
 
@@ -32,48 +31,48 @@ func Configure(config *config.Config) error {
 }
 
 // configureFirewall sets up UFW firewall with appropriate rules
-func configureFirewall(config *config.Config) error {
+func configureFirewall(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Configuring UFW Firewall")
 	utils.PrintStatus("Setting up firewall rules...")
 
 	// Set default policies
-	err := utils.RunCommand("sudo", "ufw", "default", "deny", "incoming")
+	err := utils.RunCommand(ctx, "sudo", "ufw", "default", "deny", "incoming")
 	if err != nil {
 		return err
 	}
 
-	err = utils.RunCommand("sudo", "ufw", "default", "allow", "outgoing")
+	err = utils.RunCommand(ctx, "sudo", "ufw", "default", "allow", "outgoing")
 	if err != nil {
 		return err
 	}
 
 	// Allow SSH on custom port
-	err = utils.RunCommand("sudo", "ufw", "allow", config.SSHPort+"/tcp")
+	err = utils.RunCommand(ctx, "sudo", "ufw", "allow", cfg.SSHPort+"/tcp")
 	if err != nil {
 		return err
 	}
 
 	// Allow HTTP
-	err = utils.RunCommand("sudo", "ufw", "allow", "80/tcp")
+	err = utils.RunCommand(ctx, "sudo", "ufw", "allow", "80/tcp")
 	if err != nil {
 		return err
 	}
 
 	// Allow HTTPS
-	err = utils.RunCommand("sudo", "ufw", "allow", "443/tcp")
+	err = utils.RunCommand(ctx, "sudo", "ufw", "allow", "443/tcp")
 	if err != nil {
 		return err
 	}
 
 	// Enable firewall
 	utils.PrintStatus("Enabling firewall...")
-	err = utils.RunCommand("sudo", "ufw", "--force", "enable")
+	err = utils.RunCommand(ctx, "sudo", "ufw", "--force", "enable")
 	if err != nil {
 		return err
 	}
 
 	utils.PrintStatus("Firewall configured and enabled successfully")
-	err = utils.RunCommand("sudo", "ufw", "status")
+	err = utils.RunCommand(ctx, "sudo", "ufw", "status")
 	if err != nil {
 		return err
 	}
@@ -82,39 +81,33 @@ func configureFirewall(config *config.Config) error {
 }
 
 // configureFail2ban sets up fail2ban to protect against brute force attacks
-func configureFail2ban(config *config.Config) error {
+func configureFail2ban(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Configuring Fail2ban")
 	utils.PrintStatus("Setting up fail2ban for intrusion prevention...")
 
 	// Copy default configuration
-	err := utils.RunCommand("sudo", "cp", "/etc/fail2ban/jail.conf", "/etc/fail2ban/jail.local")
+	err := utils.RunCommand(ctx, "sudo", "cp", "/etc/fail2ban/jail.conf", "/etc/fail2ban/jail.local")
 	if err != nil {
 		return err
 	}
 
 	// Generate fail2ban configuration
-	fail2banConfig := templates.GetFail2banConfig(config.SSHPort)
-
-	// Write fail2ban configuration to file
-	err = os.WriteFile("fail2ban_custom.conf", []byte(fail2banConfig), 0644)
-	if err != nil {
-		return err
-	}
+	fail2banConfig := templates.GetFail2banConfig(cfg.SSHPort)
 
-	// Move fail2ban configuration to jail.d directory
-	err = utils.RunCommand("sudo", "mv", "fail2ban_custom.conf", "/etc/fail2ban/jail.d/custom.conf")
+	// Write fail2ban configuration to jail.d directory
+	err = utils.WriteSystemFile(ctx, "/etc/fail2ban/jail.d/custom.conf", fail2banConfig, 0644)
 	if err != nil {
 		return err
 	}
 
 	// Enable fail2ban to start on boot
-	err = utils.RunCommand("sudo", "systemctl", "enable", "fail2ban")
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "enable", "fail2ban")
 	if err != nil {
 		return err
 	}
 
 	// Restart fail2ban to apply changes
-	err = utils.RunCommand("sudo", "systemctl", "restart", "fail2ban")
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", "fail2ban")
 	if err != nil {
 		return err
 	}
@@ -124,47 +117,79 @@ func configureFail2ban(config *config.Config) error {
 }
 
 // configureSSH hardens SSH configuration for better security
-func configureSSH(config *config.Config) error {
+func configureSSH(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Configuring SSH Security")
 	utils.PrintStatus("Hardening SSH configuration...")
 
 	// Backup original SSH configuration
-	err := utils.RunCommand("sudo", "cp", "/etc/ssh/sshd_config", "/etc/ssh/sshd_config.backup")
+	err := utils.RunCommand(ctx, "sudo", "cp", "/etc/ssh/sshd_config", "/etc/ssh/sshd_config.backup")
 	if err != nil {
 		return err
 	}
 
 	// Generate SSH configuration
-	sshConfig := templates.GetSSHConfig(config.SSHPort)
-
-	// Write SSH configuration to file
-	err = os.WriteFile("ssh_security.conf", []byte(sshConfig), 0644)
-	if err != nil {
-		return err
-	}
+	sshConfig := templates.GetSSHConfig(cfg.SSHPort)
 
 	// Create sshd_config.d directory if it doesn't exist
-	err = utils.RunCommand("sudo", "mkdir", "-p", "/etc/ssh/sshd_config.d")
+	err = utils.RunCommand(ctx, "sudo", "mkdir", "-p", "/etc/ssh/sshd_config.d")
 	if err != nil {
 		return err
 	}
 
-	// Move SSH configuration to sshd_config.d directory
-	err = utils.RunCommand("sudo", "mv", "ssh_security.conf", "/etc/ssh/sshd_config.d/security.conf")
+	// Write SSH configuration to sshd_config.d directory
+	err = utils.WriteSystemFile(ctx, "/etc/ssh/sshd_config.d/security.conf", sshConfig, 0644)
 	if err != nil {
 		return err
 	}
 
 	// Restart SSH service to apply changes
 	utils.PrintStatus("Restarting SSH service to apply changes...")
-	err = utils.RunCommand("sudo", "systemctl", "restart", "ssh")
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", "ssh")
 	if err != nil {
 		return err
 	}
 
 	utils.PrintStatus("SSH security configured successfully")
-	utils.PrintWarning("SSH port has been changed to: " + config.SSHPort)
+	utils.PrintWarning("SSH port has been changed to: " + cfg.SSHPort)
 	utils.PrintWarning("Make sure to update your SSH client configuration")
 
 	return nil
-}
\ No newline at end of file
+}
+
+// Rollback undoes the security configuration so the server is reachable as before
+// The SSH hardening and fail2ban jail are removed and the firewall is disabled
+func Rollback(ctx context.Context, _ *config.Config) error {
+	utils.PrintHeader("Rolling Back Security Configuration")
+
+	// Restore the original SSH configuration first so access on the old port keeps working
+	utils.PrintStatus("Removing SSH hardening...")
+	err := utils.RunCommand(ctx, "sudo", "rm", "-f", "/etc/ssh/sshd_config.d/security.conf")
+	if err != nil {
+		return err
+	}
+
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", "ssh")
+	if err != nil {
+		return err
+	}
+
+	utils.PrintStatus("Removing fail2ban jail configuration...")
+	err = utils.RunCommand(ctx, "sudo", "rm", "-f", "/etc/fail2ban/jail.d/custom.conf")
+	if err != nil {
+		return err
+	}
+
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", "fail2ban")
+	if err != nil {
+		return err
+	}
+
+	utils.PrintStatus("Disabling firewall...")
+	err = utils.RunCommand(ctx, "sudo", "ufw", "--force", "disable")
+	if err != nil {
+		return err
+	}
+
+	utils.PrintStatus("Security configuration rolled back")
+	return nil
+}
@@ -1,10 +1,9 @@
 package services
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"strings"
 
 	"laravel-setup/pkg/config"
 	"laravel-setup/pkg/templates"
@@ -12,41 +11,24 @@ import (
 )
 
 // Configure configures and starts all services
-func Configure(config *config.Config) error {
+func Configure(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Configuring and Starting Services")
 
-	// Enable and start Nginx
-	if err := enableService("nginx"); err != nil {
-		return err
-	}
-
-	// Enable and start PHP-FPM
-	if err := enableService("php8.4-fp"); err != nil {
-		return err
-	}
-
-	// Enable and start MySQL
-	if err := enableService("mysql"); err != nil {
-		return err
-	}
-
-	// Enable and start Redis
-	if err := enableService("redis-server"); err != nil {
-		return err
-	}
-
-	// Enable and start Supervisor
-	if err := enableService("supervisor"); err != nil {
-		return err
+	// Enable and start Nginx, PHP-FPM for every installed version, the database server, Redis and Supervisor
+	// The database and Redis are skipped when they are managed elsewhere
+	for _, service := range cfg.Services() {
+		if err := enableService(ctx, service); err != nil {
+			return err
+		}
 	}
 
 	// Setup SSL certificate
-	if err := setupSSL(config); err != nil {
+	if err := setupSSL(ctx, cfg); err != nil {
 		return err
 	}
 
 	// Create server information file
-	if err := createServerInfo(config); err != nil {
+	if err := createServerInfo(ctx, cfg); err != nil {
 		return err
 	}
 
@@ -58,17 +40,17 @@ func Configure(config *config.Config) error {
 }
 
 // enableService enables and starts a service
-func enableService(service string) error {
+func enableService(ctx context.Context, service string) error {
 	utils.PrintStatus("Enabling and starting " + service + "...")
 
 	// Enable service to start on boot
-	err := utils.RunCommand("sudo", "systemctl", "enable", service)
+	err := utils.RunCommand(ctx, "sudo", "systemctl", "enable", service)
 	if err != nil {
 		return err
 	}
 
 	// Restart service to apply changes
-	err = utils.RunCommand("sudo", "systemctl", "restart", service)
+	err = utils.RunCommand(ctx, "sudo", "systemctl", "restart", service)
 	if err != nil {
 		return err
 	}
@@ -77,26 +59,23 @@ func enableService(service string) error {
 }
 
 // setupSSL sets up SSL certificate using Let's Encrypt
-func setupSSL(config *config.Config) error {
+func setupSSL(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Setting up SSL Certificate")
 	utils.PrintWarning("Make sure your domain DNS is pointing to this server before running SSL setup")
 
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Do you want to setup SSL certificate now? (y/n): ")
-	setupSSL, _ := reader.ReadString('\n')
-	setupSSL = strings.TrimSpace(setupSSL)
+	setupSSL, _ := utils.Confirm("Do you want to setup SSL certificate now?")
 
-	if setupSSL == "y" || setupSSL == "Y" {
+	if setupSSL {
 		// Use Certbot to obtain and install SSL certificate
-		err := utils.RunCommand("sudo", "certbot", "--nginx", "-d", config.Domain, "-d", "www."+config.Domain)
+		err := utils.RunCommand(ctx, "sudo", "certbot", "--nginx", "-d", cfg.Domain, "-d", "www."+cfg.Domain)
 		if err != nil {
 			utils.PrintError("Failed to install SSL certificate")
-			utils.PrintWarning("You can try again later with: sudo certbot --nginx -d " + config.Domain + " -d www." + config.Domain)
+			utils.PrintWarning("You can try again later with: sudo certbot --nginx -d " + cfg.Domain + " -d www." + cfg.Domain)
 		} else {
 			utils.PrintStatus("SSL certificate installed successfully")
 
 			// Setup auto-renewal via cron job
-			err = utils.RunCommand("sudo", "bash", "-c", "echo \"0 12 * * * /usr/bin/certbot renew --quiet\" | sudo crontab -")
+			err = utils.RunCommand(ctx, "sudo", "bash", "-c", "echo \"0 12 * * * /usr/bin/certbot renew --quiet\" | sudo crontab -")
 			if err != nil {
 				return err
 			}
@@ -105,25 +84,35 @@ func setupSSL(config *config.Config) error {
 		}
 	} else {
 		utils.PrintWarning("SSL certificate setup skipped")
-		utils.PrintWarning("You can set it up later with: sudo certbot --nginx -d " + config.Domain + " -d www." + config.Domain)
+		utils.PrintWarning("You can set it up later with: sudo certbot --nginx -d " + cfg.Domain + " -d www." + cfg.Domain)
 	}
 
 	return nil
 }
 
 // createServerInfo creates a server information file
-func createServerInfo(config *config.Config) error {
+func createServerInfo(ctx context.Context, cfg *config.Config) error {
 	utils.PrintHeader("Creating Server Information File")
 	utils.PrintStatus("Saving server information to file...")
 
 	// Generate server information content
+	dbEngine, dbLogDir := cfg.Database.DisplayName(), cfg.Database.LogDir()
+	if cfg.Database.External() {
+		dbEngine = fmt.Sprintf("%s (managed, %s:%d)", dbEngine, cfg.Database.Host, cfg.Database.PortNumber())
+		dbLogDir = ""
+	}
 	serverInfo := templates.GetServerInfoContent(
-		config.Domain,
-		config.WebRoot,
-		config.DBName,
-		config.DBUser,
-		config.SSHPort,
+		cfg.Domain,
+		cfg.WebRoot,
+		dbEngine,
+		dbLogDir,
+		cfg.Database.ClientFile(),
+		cfg.DBName,
+		cfg.DBUser,
+		cfg.SSHPort,
 		os.Getenv("USER"),
+		string(cfg.PHPVersion),
+		cfg.Services(),
 	)
 
 	// Write server information to file with restricted permissions
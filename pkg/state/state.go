@@ -0,0 +1,132 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Step status values recorded in the state file
+const (
+	StatusCompleted   = "completed"
+	StatusFailed      = "failed"
+	StatusSkipped     = "skipped"
+	StatusInterrupted = "interrupted"
+	StatusRolledBack  = "rolled_back"
+)
+
+// StepState records the outcome of the last run of a setup step
+type StepState struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Release records a deployed revision of the Laravel application
+type Release struct {
+	Revision   string    `json:"revision"`
+	DeployedAt time.Time `json:"deployed_at"`
+}
+
+// State holds everything the tool remembers between runs
+type State struct {
+	Steps    map[string]StepState `json:"steps"`
+	Releases []Release            `json:"releases"`
+
+	path string
+}
+
+// GetDefaultStatePath returns the default path for the state file in the user's home directory
+func GetDefaultStatePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".laravel-setup", "state.json"), nil
+}
+
+// Load reads the state file from the default location
+// Returns an empty state if the file doesn't exist yet
+func Load() (*State, error) {
+	path, err := GetDefaultStatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadFromFile(path)
+}
+
+// LoadFromFile reads the state file from the given path
+// Returns an empty state if the file doesn't exist yet
+func LoadFromFile(path string) (*State, error) {
+	s := &State{Steps: map[string]StepState{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Steps == nil {
+		s.Steps = map[string]StepState{}
+	}
+
+	return s, nil
+}
+
+// Save writes the state file, creating its directory if needed
+// The file is replaced atomically so an interrupted write never leaves it truncated
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// SetStep records the outcome of a setup step
+func (s *State) SetStep(name, status string, stepErr error) {
+	st := StepState{Status: status, UpdatedAt: time.Now()}
+	if stepErr != nil {
+		st.Error = stepErr.Error()
+	}
+	s.Steps[name] = st
+}
+
+// AddRelease appends a deployed revision to the release history
+func (s *State) AddRelease(revision string) {
+	s.Releases = append(s.Releases, Release{Revision: revision, DeployedAt: time.Now()})
+}
+
+// CurrentRelease returns the most recently deployed release, if any
+func (s *State) CurrentRelease() (Release, bool) {
+	if len(s.Releases) == 0 {
+		return Release{}, false
+	}
+	return s.Releases[len(s.Releases)-1], true
+}
+
+// PreviousRelease returns the release deployed before the current one, if any
+func (s *State) PreviousRelease() (Release, bool) {
+	if len(s.Releases) < 2 {
+		return Release{}, false
+	}
+	return s.Releases[len(s.Releases)-2], true
+}
@@ -0,0 +1,25 @@
+package system
+
+import (
+	"context"
+
+	"laravel-setup/pkg/utils"
+)
+
+// EnsureAccount creates the system user and group a pool runs as, if they don't exist
+func EnsureAccount(ctx context.Context, user, group string) error {
+	if _, err := utils.RunCommandWithOutput(ctx, "getent", "group", group); err != nil {
+		utils.PrintStatus("Creating system group " + group + "...")
+		if err := utils.RunCommand(ctx, "sudo", "groupadd", "--system", group); err != nil {
+			return err
+		}
+	}
+
+	if _, err := utils.RunCommandWithOutput(ctx, "id", "-u", user); err != nil {
+		utils.PrintStatus("Creating system user " + user + "...")
+		return utils.RunCommand(ctx, "sudo", "useradd", "--system", "--gid", group,
+			"--no-create-home", "--home-dir", "/nonexistent", "--shell", "/usr/sbin/nologin", user)
+	}
+
+	return nil
+}
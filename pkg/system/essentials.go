@@ -1,6 +1,7 @@
 package system
 
 import (
+	"context"
 	"laravel-setup/pkg/config"
 	"strings"
 
@@ -9,16 +10,17 @@ import (
 
 // InstallEssentials installs essential system packages
 // These packages are required for the Laravel server setup
-func InstallEssentials(_ *config.Config) error {
+func InstallEssentials(ctx context.Context, cfg *config.Config) error {
 	utils.PrintStatus("Installing essential system packages...")
 
 	// Install essential packages
 	// These packages provide core functionality for the server
-	err := utils.RunCommand("sudo", "apt", "install", "-y",
+	packages := []string{
 		"curl", "wget", "git", "unzip", "software-properties-common",
 		"apt-transport-https", "ca-certificates", "gnupg", "lsb-release",
 		"ufw", "fail2ban", "htop", "tree", "vim", "supervisor",
-		"redis-server", "certbot", "python3-certbot-nginx")
+		"certbot", "python3-certbot-nginx"}
+	err := utils.RunCommand(ctx, "sudo", append([]string{"apt", "install", "-y"}, packages...)...)
 	if err != nil {
 		return err
 	}
@@ -26,12 +28,12 @@ func InstallEssentials(_ *config.Config) error {
 	utils.PrintStatus("Essential packages installed successfully")
 
 	// Install Composer (PHP dependency manager)
-	if err := installComposer(); err != nil {
+	if err := installComposer(ctx); err != nil {
 		return err
 	}
 
 	// Install Node.js and npm (JavaScript runtime and package manager)
-	if err := installNodeJS(); err != nil {
+	if err := installNodeJS(ctx); err != nil {
 		return err
 	}
 
@@ -39,30 +41,30 @@ func InstallEssentials(_ *config.Config) error {
 }
 
 // installComposer installs the Composer PHP dependency manager
-func installComposer() error {
+func installComposer(ctx context.Context) error {
 	utils.PrintHeader("Installing Composer")
 	utils.PrintStatus("Downloading and installing Composer...")
 
 	// Download Composer installer
-	err := utils.RunCommand("curl", "-sS", "https://getcomposer.org/installer", "-o", "composer-setup.php")
+	err := utils.RunCommand(ctx, "curl", "-sS", "https://getcomposer.org/installer", "-o", "composer-setup.php")
 	if err != nil {
 		return err
 	}
 
 	// Run the installer
-	err = utils.RunCommand("php", "composer-setup.php")
+	err = utils.RunCommand(ctx, "php", "composer-setup.php")
 	if err != nil {
 		return err
 	}
 
 	// Move composer.phar to a directory in the PATH
-	err = utils.RunCommand("sudo", "mv", "composer.phar", "/usr/local/bin/composer")
+	err = utils.RunCommand(ctx, "sudo", "mv", "composer.phar", "/usr/local/bin/composer")
 	if err != nil {
 		return err
 	}
 
 	// Make it executable
-	err = utils.RunCommand("sudo", "chmod", "+x", "/usr/local/bin/composer")
+	err = utils.RunCommand(ctx, "sudo", "chmod", "+x", "/usr/local/bin/composer")
 	if err != nil {
 		return err
 	}
@@ -72,35 +74,35 @@ func installComposer() error {
 }
 
 // installNodeJS installs Node.js and npm
-func installNodeJS() error {
+func installNodeJS(ctx context.Context) error {
 	utils.PrintHeader("Installing Node.js and npm")
 	utils.PrintStatus("Adding Node.js repository and installing Node.js...")
 
 	// Download Node.js setup script
-	err := utils.RunCommand("curl", "-fsSL", "https://deb.nodesource.com/setup_22.x", "-o", "nodejs-setup.sh")
+	err := utils.RunCommand(ctx, "curl", "-fsSL", "https://deb.nodesource.com/setup_22.x", "-o", "nodejs-setup.sh")
 	if err != nil {
 		return err
 	}
 
 	// Run the setup script
-	err = utils.RunCommand("sudo", "bash", "nodejs-setup.sh")
+	err = utils.RunCommand(ctx, "sudo", "bash", "nodejs-setup.sh")
 	if err != nil {
 		return err
 	}
 
 	// Install Node.js
-	err = utils.RunCommand("sudo", "apt", "install", "-y", "nodejs")
+	err = utils.RunCommand(ctx, "sudo", "apt", "install", "-y", "nodejs")
 	if err != nil {
 		return err
 	}
 
 	// Check Node.js and npm versions
-	nodeVersion, err := utils.RunCommandWithOutput("node", "-v")
+	nodeVersion, err := utils.RunCommandWithOutput(ctx, "node", "-v")
 	if err != nil {
 		return err
 	}
 
-	npmVersion, err := utils.RunCommandWithOutput("npm", "-v")
+	npmVersion, err := utils.RunCommandWithOutput(ctx, "npm", "-v")
 	if err != nil {
 		return err
 	}
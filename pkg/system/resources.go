@@ -0,0 +1,42 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"laravel-setup/pkg/config"
+)
+
+// DetectHost reads the total RAM from /proc/meminfo and counts the CPU cores
+func DetectHost() (config.Host, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return config.Host{}, fmt.Errorf("failed to read memory information: %w", err)
+	}
+	defer f.Close()
+
+	memoryKB, err := parseMemTotal(bufio.NewScanner(f))
+	if err != nil {
+		return config.Host{}, err
+	}
+
+	return config.Host{MemoryMB: memoryKB / 1024, CPUs: runtime.NumCPU()}, nil
+}
+
+// parseMemTotal returns the MemTotal line of /proc/meminfo in KiB
+func parseMemTotal(scanner *bufio.Scanner) (int, error) {
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			return strconv.Atoi(fields[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+}
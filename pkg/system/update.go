@@ -1,23 +1,24 @@
 package system
 
 import (
+	"context"
 	"laravel-setup/pkg/config"
 	"laravel-setup/pkg/utils"
 )
 
 // Update updates the system packages
 // This ensures the system has the latest security patches and package information
-func Update(_ *config.Config) error {
+func Update(ctx context.Context, _ *config.Config) error {
 	utils.PrintStatus("Updating system packages...")
 
 	// Update package lists
-	err := utils.RunCommand("sudo", "apt", "update")
+	err := utils.RunCommand(ctx, "sudo", "apt", "update")
 	if err != nil {
 		return err
 	}
 
 	// Upgrade installed packages
-	err = utils.RunCommand("sudo", "apt", "upgrade", "-y")
+	err = utils.RunCommand(ctx, "sudo", "apt", "upgrade", "-y")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,36 @@
+package templates
+
+import "fmt"
+
+// GetBackupService returns the systemd service that runs a database backup
+// It runs as root and waits for any setup or deploy holding the run lock
+func GetBackupService(binary, configPath string) string {
+	return fmt.Sprintf(`# Managed by laravel-setup
+[Unit]
+Description=Laravel database backup
+After=network.target mysql.service mariadb.service postgresql.service
+
+[Service]
+Type=oneshot
+ExecStart="%s" backup now --wait --config-path "%s"
+Nice=10
+IOSchedulingClass=idle
+`, binary, configPath)
+}
+
+// GetBackupTimer returns the systemd timer that schedules the database backups
+// schedule is an OnCalendar expression; a missed run is made up at the next boot
+func GetBackupTimer(schedule string) string {
+	return fmt.Sprintf(`# Managed by laravel-setup
+[Unit]
+Description=Scheduled Laravel database backups
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+RandomizedDelaySec=10min
+
+[Install]
+WantedBy=timers.target
+`, schedule)
+}
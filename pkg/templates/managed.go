@@ -0,0 +1,69 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GetMySQLClientOptions returns the client option file used to connect to a managed MySQL or MariaDB server
+func GetMySQLClientOptions(host string, port int, user, password, sslCA string, mariaDB bool) string {
+	return "# Managed by laravel-setup; the managed server and its admin account for the mysql client\n" +
+		mysqlClientSection(host, port, user, password, sslCA, mariaDB)
+}
+
+// ManagedHeader starts the files laravel-setup writes into the user's home directory,
+// so files the user wrote are never replaced
+const ManagedHeader = "# Managed by laravel-setup"
+
+// GetMySQLUserOptions returns the user's ~/.my.cnf, which logs the mysql client in to the Laravel database
+// port is 0 for the local server, reached through its socket
+func GetMySQLUserOptions(host string, port int, user, password, dbName, sslCA string, mariaDB bool) string {
+	return ManagedHeader + "; rewritten by the setup and db rotate-password\n" +
+		mysqlClientSection(host, port, user, password, sslCA, mariaDB) +
+		fmt.Sprintf("\n[mysql]\ndatabase = %s\n", dbName)
+}
+
+// mysqlClientSection returns the [client] options that log in to a server
+// With an sslCA, the server's certificate and host name are verified; the option differs between the clients
+func mysqlClientSection(host string, port int, user, password, sslCA string, mariaDB bool) string {
+	password = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(password)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[client]\nhost = %s\n", host)
+	if port != 0 {
+		fmt.Fprintf(&b, "port = %d\n", port)
+	}
+	fmt.Fprintf(&b, "user = %s\npassword = \"%s\"\n", user, password)
+	if sslCA != "" {
+		if mariaDB {
+			fmt.Fprintf(&b, "ssl-ca = %s\nssl-verify-server-cert\n", sslCA)
+		} else {
+			fmt.Fprintf(&b, "ssl-ca = %s\nssl-mode = VERIFY_IDENTITY\n", sslCA)
+		}
+	}
+	return b.String()
+}
+
+// GetPgpass returns the user's ~/.pgpass, which logs psql in to the Laravel database
+func GetPgpass(host string, port int, dbName, user, password string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `:`, `\:`).Replace
+	return fmt.Sprintf("%s; rewritten by the setup and db rotate-password\n%s:%d:%s:%s:%s\n",
+		ManagedHeader, escape(host), port, escape(dbName), escape(user), escape(password))
+}
+
+// GetPostgresService returns a connection service file for a managed PostgreSQL server
+// Values run to the end of the line, so they aren't quoted
+func GetPostgresService(service, host string, port int, user, password, sslCA string) string {
+	tls := ""
+	if sslCA != "" {
+		tls = fmt.Sprintf("sslmode=verify-full\nsslrootcert=%s\n", sslCA)
+	}
+
+	return fmt.Sprintf(`# Managed by laravel-setup; the managed server and its admin account for psql and pg_dump
+[%s]
+host=%s
+port=%d
+user=%s
+password=%s
+%s`, service, host, port, user, password, tls)
+}
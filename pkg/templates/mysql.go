@@ -1,49 +1,71 @@
 package templates
 
-import "fmt"
-
-// GetMySQLConfig returns the MySQL configuration SQL script
-// This configures MySQL for Laravel with appropriate user permissions and security settings
-func GetMySQLConfig(dbName, dbUser, dbPassword, dbRootPassword string) string {
-	return fmt.Sprintf(`
--- Create database with UTF-8 support for Laravel
-CREATE DATABASE IF NOT EXISTS %s CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
-
--- Create Laravel user with restricted permissions
-DELETE FROM mysql.user WHERE User='%s' AND Host='localhost';
-CREATE USER IF NOT EXISTS '%s'@'localhost' IDENTIFIED BY '%s';
-GRANT ALL PRIVILEGES ON %s.* TO '%s'@'localhost';
-
--- Create admin user for easier database management
-DELETE FROM mysql.user WHERE User='admin' AND Host='localhost';
-CREATE USER IF NOT EXISTS 'admin'@'localhost' IDENTIFIED BY '%s';
-GRANT ALL PRIVILEGES ON *.* TO 'admin'@'localhost' WITH GRANT OPTION;
-
--- Secure the installation by removing anonymous users and test database
-DELETE FROM mysql.user WHERE User='';
-DELETE FROM mysql.user WHERE User='root' AND Host NOT IN ('localhost', '127.0.0.1', '::1');
-DROP DATABASE IF EXISTS test;
-DELETE FROM mysql.db WHERE Db='test' OR Db='test\\_%%';
-
--- Flush privileges to apply changes
-FLUSH PRIVILEGES;
-`, dbName, dbUser, dbPassword, dbName, dbUser, dbRootPassword)
+import (
+	"fmt"
+	"strings"
+)
+
+// DatabaseAccount is a database account created by the setup scripts
+type DatabaseAccount struct {
+	// Profile is the account's grant profile, e.g. "app" or "migrate"
+	Profile  string
+	User     string
+	Password string
+}
+
+// MySQLTuning holds the values of the MySQL and MariaDB configuration drop-in
+type MySQLTuning struct {
+	// MariaDB selects the MariaDB spelling of the redo log size
+	MariaDB           bool
+	BufferPoolMB      int
+	RedoLogMB         int
+	MaxConnections    int
+	LongQueryTime     float64
+	PerformanceSchema bool
+}
+
+// GetMySQLTuning returns the MySQL and MariaDB server configuration drop-in
+func GetMySQLTuning(t MySQLTuning) string {
+	// MySQL 8.0.30 replaced the log file size and count with a single capacity
+	redoLog := fmt.Sprintf("innodb_redo_log_capacity = %dM", t.RedoLogMB)
+	if t.MariaDB {
+		redoLog = fmt.Sprintf("innodb_log_file_size = %dM", t.RedoLogMB)
+	}
+
+	performanceSchema := "OFF"
+	if t.PerformanceSchema {
+		performanceSchema = "ON"
+	}
+
+	return fmt.Sprintf(`# Managed by laravel-setup; changes are overwritten on the next run
+[mysqld]
+# Only accept connections from this server
+bind-address = 127.0.0.1
+skip-name-resolve
+
+# Laravel's default charset and collation
+character-set-server = utf8mb4
+collation-server = utf8mb4_unicode_ci
+
+# Memory, sized from the host's RAM, the PHP-FPM pools and Redis
+innodb_buffer_pool_size = %dM
+%s
+max_connections = %d
+performance_schema = %s
+
+# Slow query log
+slow_query_log = ON
+slow_query_log_file = /var/log/mysql/mysql-slow.log
+long_query_time = %g
+`, t.BufferPoolMB, redoLog, t.MaxConnections, performanceSchema, t.LongQueryTime)
 }
 
-// GetMySQLCredentialsContent returns the MySQL credentials content
-// This provides a secure way to store and access MySQL credentials
-func GetMySQLCredentialsContent(dbName, dbUser, dbPassword, dbRootPassword string) string {
-	return fmt.Sprintf(`MySQL Credentials:
-==================
-Laravel Database: %s
-Laravel User: %s
-Laravel Password: %s
-
-Admin User: admin
-Admin Password: %s
-
-Connection Examples:
-mysql -u %s -p%s %s
-mysql -u admin -p%s
-`, dbName, dbUser, dbPassword, dbRootPassword, dbUser, dbPassword, dbName, dbRootPassword)
+// GetMySQLRootOptions returns the client option file used to connect as root with a password
+func GetMySQLRootOptions(password string) string {
+	password = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(password)
+	return fmt.Sprintf(`# Managed by laravel-setup; root's password for the mysql client
+[client]
+user = root
+password = "%s"
+`, password)
 }
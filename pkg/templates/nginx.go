@@ -1,10 +1,14 @@
 package templates
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // GetNginxConfig returns the Nginx configuration for a Laravel application
 // This configures Nginx with security headers, gzip compression, and rate limiting
-func GetNginxConfig(domain, webRoot string) string {
+// PHP requests are passed to the PHP-FPM pool listening on fpmSocket
+func GetNginxConfig(domain, webRoot, fpmSocket string) string {
 	return fmt.Sprintf(`server {
     listen 80;
     server_name %s www.%s;
@@ -42,7 +46,7 @@ func GetNginxConfig(domain, webRoot string) string {
 
     # PHP processing
     location ~ \.php$ {
-        fastcgi_pass unix:/var/run/php/php8.4-fpm.sock;
+        fastcgi_pass unix:%s;
         fastcgi_index index.php;
         fastcgi_param SCRIPT_FILENAME $realpath_root$fastcgi_script_name;
         include fastcgi_params;
@@ -71,5 +75,43 @@ func GetNginxConfig(domain, webRoot string) string {
     location ~ /\. {
         deny all;
     }
-}`, domain, domain, webRoot)
+}`, domain, domain, webRoot, fpmSocket)
+}
+
+// StatusPool is a PHP-FPM pool exposed by the status server
+type StatusPool struct {
+	Domain string
+	Socket string
+}
+
+// GetFPMStatusConfig returns an Nginx server exposing the status and ping pages of every PHP-FPM pool
+// The server only listens on localhost and only answers local clients, under /<domain>/status and /<domain>/ping
+func GetFPMStatusConfig(address, statusPath, pingPath string, pools []StatusPool) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `server {
+    listen %s;
+    server_name localhost;
+    access_log off;
+
+    allow 127.0.0.1;
+    deny all;
+`, address)
+
+	for _, pool := range pools {
+		for _, path := range []string{statusPath, pingPath} {
+			fmt.Fprintf(&b, `
+    location = /%s%s {
+        fastcgi_pass unix:%s;
+        fastcgi_param SCRIPT_NAME %s;
+        fastcgi_param SCRIPT_FILENAME %s;
+        fastcgi_param REQUEST_METHOD $request_method;
+        fastcgi_param QUERY_STRING $query_string;
+    }
+`, pool.Domain, path, pool.Socket, path, path)
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
 }
@@ -1,14 +1,184 @@
 package templates
 
-// OPcacheConfig is the configuration for PHP OPcache
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// iniBareValuePattern matches php.ini values that can be written without quotes
+var iniBareValuePattern = regexp.MustCompile(`^-?[A-Za-z0-9_./:,-]*$`)
+
+// GetINIConfig returns a conf.d drop-in setting the given php.ini directives, sorted by name
+// Files in conf.d are read after php.ini, and the 99- prefix makes this one win over the others
+func GetINIConfig(settings map[string]string) string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("; Managed by laravel-setup, changes are overwritten on the next run\n")
+	b.WriteString("; Set values in the [php.ini] table of the configuration file instead\n")
+	for _, k := range keys {
+		v := settings[k]
+		if !iniBareValuePattern.MatchString(v) {
+			v = `"` + v + `"`
+		}
+		fmt.Fprintf(&b, "%s = %s\n", k, v)
+	}
+	return b.String()
+}
+
+// OPcache holds the values rendered into the OPcache configuration
+type OPcache struct {
+	MemoryConsumption     int
+	InternedStringsBuffer int
+	MaxAcceleratedFiles   int
+	ValidateTimestamps    bool
+	RevalidateFreq        int
+	JIT                   bool
+	JITBufferSize         int
+	// Preload is the preload script, empty to disable preloading
+	Preload     string
+	PreloadUser string
+}
+
+// GetOPcacheConfig returns the configuration for PHP OPcache
 // OPcache improves PHP performance by storing precompiled script bytecode in shared memory
-const OPcacheConfig = `
+// The JIT compiler is only enabled with JIT, whatever JITBufferSize is
+func GetOPcacheConfig(o OPcache) string {
+	var b strings.Builder
+
+	validate := 0
+	if o.ValidateTimestamps {
+		validate = 1
+	}
+
+	fmt.Fprintf(&b, `; Managed by laravel-setup, changes are overwritten on the next run
 opcache.enable=1
-opcache.memory_consumption=256
-opcache.interned_strings_buffer=8
-opcache.max_accelerated_files=4000
-opcache.revalidate_freq=2
-opcache.fast_shutdown=1
-opcache.enable_cli=1
-opcache.validate_timestamps=0
-`
\ No newline at end of file
+opcache.memory_consumption=%d
+opcache.interned_strings_buffer=%d
+opcache.max_accelerated_files=%d
+opcache.validate_timestamps=%d
+opcache.revalidate_freq=%d
+`, o.MemoryConsumption, o.InternedStringsBuffer, o.MaxAcceleratedFiles, validate, o.RevalidateFreq)
+
+	if o.JIT && o.JITBufferSize > 0 {
+		fmt.Fprintf(&b, "opcache.jit=tracing\nopcache.jit_buffer_size=%dM\n", o.JITBufferSize)
+	} else {
+		b.WriteString("opcache.jit=disable\nopcache.jit_buffer_size=0\n")
+	}
+
+	if o.Preload != "" {
+		fmt.Fprintf(&b, "opcache.preload=%s\nopcache.preload_user=%s\n", o.Preload, o.PreloadUser)
+	}
+
+	return b.String()
+}
+
+// GetPreloadScript returns an OPcache preload script for a Laravel application
+// It loads the framework classes from Composer's optimized class map; classes that fail to load are skipped
+func GetPreloadScript(webRoot string) string {
+	return fmt.Sprintf(`<?php
+// Managed by laravel-setup, changes are overwritten on the next run
+// Preloads the Laravel framework classes into OPcache when PHP-FPM starts
+
+require '%[1]s/vendor/autoload.php';
+
+$classMap = require '%[1]s/vendor/composer/autoload_classmap.php';
+
+foreach ($classMap as $class => $file) {
+    if (!str_starts_with($class, 'Illuminate\\') || str_contains($file, '/Testing/')) {
+        continue;
+    }
+
+    try {
+        class_exists($class) || interface_exists($class) || trait_exists($class);
+    } catch (\Throwable $e) {
+        // Skip classes whose dependencies aren't installed
+    }
+}
+`, webRoot)
+}
+
+// FPMPool holds the values rendered into a PHP-FPM pool configuration
+type FPMPool struct {
+	Name                    string
+	User                    string
+	Group                   string
+	Socket                  string
+	PM                      string
+	MaxChildren             int
+	StartServers            int
+	MinSpareServers         int
+	MaxSpareServers         int
+	MaxRequests             int
+	RequestTerminateTimeout int
+	SlowlogTimeout          int
+	Slowlog                 string
+	StatusPath              string
+	PingPath                string
+	OpenBasedir             string
+	Env                     map[string]string
+}
+
+// GetFPMPoolConfig returns the configuration of a dedicated PHP-FPM pool for a site
+// Each pool runs as its own user behind its own socket, so one site can't starve or read another
+// Only Nginx (www-data) may connect to the socket
+func GetFPMPoolConfig(p FPMPool) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `[%s]
+user = %s
+group = %s
+
+listen = %s
+listen.owner = www-data
+listen.group = www-data
+listen.mode = 0660
+
+pm = %s
+pm.max_children = %d
+`, p.Name, p.User, p.Group, p.Socket, p.PM, p.MaxChildren)
+
+	switch p.PM {
+	case "dynamic":
+		fmt.Fprintf(&b, "pm.start_servers = %d\npm.min_spare_servers = %d\npm.max_spare_servers = %d\n",
+			p.StartServers, p.MinSpareServers, p.MaxSpareServers)
+	case "ondemand":
+		b.WriteString("pm.process_idle_timeout = 10s\n")
+	}
+
+	fmt.Fprintf(&b, `pm.max_requests = %d
+
+; Kill runaway requests and log a backtrace of slow ones
+request_terminate_timeout = %ds
+request_slowlog_timeout = %ds
+slowlog = %s
+
+; Health pages, reachable through the localhost-only Nginx status server
+pm.status_path = %s
+ping.path = %s
+ping.response = pong
+
+; Keep the site's PHP code inside its own directory
+php_admin_value[open_basedir] = "%s"
+`, p.MaxRequests, p.RequestTerminateTimeout, p.SlowlogTimeout, p.Slowlog, p.StatusPath, p.PingPath, p.OpenBasedir)
+
+	if len(p.Env) > 0 {
+		b.WriteString("\n")
+		names := make([]string, 0, len(p.Env))
+		for name := range p.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, "env[%s] = \"%s\"\n", name, p.Env[name])
+		}
+	}
+
+	return b.String()
+}
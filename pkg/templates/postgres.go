@@ -0,0 +1,117 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pgIdent quotes a PostgreSQL identifier such as a database or role name
+func pgIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// pgLiteral quotes a PostgreSQL string literal
+// standard_conforming_strings is on by default, so only single quotes need escaping
+func pgLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// GetPostgresConfig returns the psql script that creates the Laravel database and accounts
+// The migration account owns the database and schema, and the tables it creates are shared with the
+// application and read-only accounts through default privileges
+// The script can be run again: existing roles and databases are updated instead of created
+// rootPassword is empty on a managed server, whose postgres role is left alone
+func GetPostgresConfig(dbName string, app, migrator, readOnly, admin DatabaseAccount, rootPassword string) string {
+	var b strings.Builder
+	db := pgIdent(dbName)
+	owner := pgIdent(migrator.User)
+
+	for _, account := range []DatabaseAccount{app, migrator, readOnly, admin} {
+		if account.User == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "-- Create the %s user, or reset its password\n", account.Profile)
+		fmt.Fprintf(&b, "SELECT format('CREATE ROLE %%I LOGIN', %s) WHERE NOT EXISTS (SELECT FROM pg_roles WHERE rolname = %s)\\gexec\n",
+			pgLiteral(account.User), pgLiteral(account.User))
+		options := "LOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE"
+		if account == admin {
+			options = "LOGIN SUPERUSER"
+		}
+		fmt.Fprintf(&b, "ALTER ROLE %s WITH %s PASSWORD %s;\n\n", pgIdent(account.User), options, pgLiteral(account.Password))
+	}
+
+	fmt.Fprintf(&b, `-- Create the database with UTF-8 support, owned by the migration user
+SELECT format('CREATE DATABASE %%I OWNER %%I ENCODING ''UTF8'' TEMPLATE template0', %[1]s, %[2]s) WHERE NOT EXISTS (SELECT FROM pg_database WHERE datname = %[1]s)\gexec
+ALTER DATABASE %[3]s OWNER TO %[4]s;
+REVOKE ALL ON DATABASE %[3]s FROM PUBLIC;
+
+-- Tables created by the application user before it lost its DDL privileges move to the migration user
+\connect %[3]s
+REASSIGN OWNED BY %[5]s TO %[4]s;
+ALTER SCHEMA public OWNER TO %[4]s;
+REVOKE ALL ON SCHEMA public FROM PUBLIC;
+
+-- The application user reads and writes rows only
+GRANT CONNECT, TEMPORARY ON DATABASE %[3]s TO %[5]s;
+GRANT USAGE ON SCHEMA public TO %[5]s;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO %[5]s;
+GRANT USAGE, SELECT, UPDATE ON ALL SEQUENCES IN SCHEMA public TO %[5]s;
+ALTER DEFAULT PRIVILEGES FOR ROLE %[4]s IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO %[5]s;
+ALTER DEFAULT PRIVILEGES FOR ROLE %[4]s IN SCHEMA public GRANT USAGE, SELECT, UPDATE ON SEQUENCES TO %[5]s;
+`, pgLiteral(dbName), pgLiteral(migrator.User), db, owner, pgIdent(app.User))
+
+	if readOnly.User != "" {
+		fmt.Fprintf(&b, `
+-- The read-only user reads rows only
+GRANT CONNECT ON DATABASE %[1]s TO %[3]s;
+GRANT USAGE ON SCHEMA public TO %[3]s;
+GRANT SELECT ON ALL TABLES IN SCHEMA public TO %[3]s;
+ALTER DEFAULT PRIVILEGES FOR ROLE %[2]s IN SCHEMA public GRANT SELECT ON TABLES TO %[3]s;
+`, db, owner, pgIdent(readOnly.User))
+	}
+
+	// A managed server's superuser belongs to the provider
+	if rootPassword != "" {
+		fmt.Fprintf(&b, `
+-- Give the postgres superuser a password for TCP connections
+ALTER ROLE postgres WITH PASSWORD %s;
+`, pgLiteral(rootPassword))
+	}
+	return b.String()
+}
+
+// GetPostgresPasswordChange returns the SQL that changes the password of a role
+// Sessions already open keep working; only new connections need the new password
+func GetPostgresPasswordChange(user, password string) string {
+	return fmt.Sprintf("ALTER ROLE %s WITH PASSWORD %s;\n", pgIdent(user), pgLiteral(password))
+}
+
+// GetPostgresTuning returns the PostgreSQL configuration drop-in
+// reservedMB is the RAM set aside for the database server
+func GetPostgresTuning(reservedMB int) string {
+	sharedBuffers := reservedMB / 4
+	if sharedBuffers < 128 {
+		sharedBuffers = 128
+	}
+	effectiveCache := reservedMB * 3 / 4
+	if effectiveCache < sharedBuffers {
+		effectiveCache = sharedBuffers
+	}
+
+	return fmt.Sprintf(`# Managed by laravel-setup; changes are overwritten on the next run
+
+# Only accept connections from this server
+listen_addresses = 'localhost'
+password_encryption = scram-sha-256
+
+# Memory, sized from the %d MiB reserved for the database
+shared_buffers = %dMB
+effective_cache_size = %dMB
+maintenance_work_mem = 64MB
+work_mem = 8MB
+
+# Laravel stores text as UTF-8
+client_encoding = 'UTF8'
+timezone = 'UTC'
+`, reservedMB, sharedBuffers, effectiveCache)
+}
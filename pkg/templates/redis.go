@@ -0,0 +1,81 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RedisSettings holds the values of the Redis server configuration included from redis.conf
+type RedisSettings struct {
+	MaxMemoryMB    int
+	EvictionPolicy string
+	// Socket listens on a Unix socket only; otherwise Port on 127.0.0.1
+	Socket string
+	Port   int
+	// Persistence is "rdb", "aof" or "none"
+	Persistence string
+	// Password is required from the default user, or from User when it is set
+	Password string
+	User     string
+	// AllChannels grants User every Pub/Sub channel, which Redis 6.2 and later deny by default
+	AllChannels bool
+}
+
+// GetRedisConfig returns the Redis server configuration, included at the end of redis.conf so it overrides it
+func GetRedisConfig(s RedisSettings) string {
+	var b strings.Builder
+	b.WriteString("# Managed by laravel-setup; changes are overwritten on the next run\n")
+
+	if s.Socket != "" {
+		fmt.Fprintf(&b, `
+# Only accept connections through the socket, from the redis group
+port 0
+unixsocket %s
+unixsocketperm 770
+`, s.Socket)
+	} else {
+		fmt.Fprintf(&b, `
+# Only accept connections from this server
+bind 127.0.0.1
+port %d
+`, s.Port)
+	}
+
+	fmt.Fprintf(&b, `
+# Memory, sized from the RAM reserved for Redis
+maxmemory %dmb
+maxmemory-policy %s
+`, s.MaxMemoryMB, s.EvictionPolicy)
+
+	// An empty save clears the snapshot schedule redis.conf may already have set
+	b.WriteString("\n# Persistence\nsave \"\"\n")
+	switch s.Persistence {
+	case "aof":
+		b.WriteString("appendonly yes\nappendfsync everysec\n")
+	case "none":
+		b.WriteString("appendonly no\n")
+	default:
+		b.WriteString("save 3600 1\nsave 300 100\nsave 60 10000\nappendonly no\n")
+	}
+
+	switch {
+	case s.User != "":
+		channels := ""
+		if s.AllChannels {
+			channels = " &*"
+		}
+		fmt.Fprintf(&b, `
+# Laravel authenticates as its own ACL user; the default user is turned off
+user default off
+user %s on %s ~*%s +@all
+`, s.User, redisQuote(">"+s.Password), channels)
+	case s.Password != "":
+		fmt.Fprintf(&b, "\n# Clients authenticate with the password\nrequirepass %s\n", redisQuote(s.Password))
+	}
+	return b.String()
+}
+
+// redisQuote quotes a value for redis.conf, which splits arguments like a shell
+func redisQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
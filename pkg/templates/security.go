@@ -1,6 +1,9 @@
 package templates
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // GetFail2banConfig returns the Fail2ban configuration
 // This configures Fail2ban to protect against brute force attacks
@@ -52,18 +55,34 @@ Protocol 2`, sshPort)
 
 // GetServerInfoContent returns the server information content
 // This provides a summary of the server configuration for reference
-func GetServerInfoContent(domain, webRoot, dbName, dbUser, sshPort, username string) string {
+// services are the systemd services running on the server; dbLogDir is empty for a managed database
+// clientFile is the file in the user's home directory that logs the database client in
+func GetServerInfoContent(domain, webRoot, dbEngine, dbLogDir, clientFile, dbName, dbUser, sshPort, username, phpVersion string, services []string) string {
+	var status strings.Builder
+	for _, service := range services {
+		fmt.Fprintf(&status, "- sudo systemctl status %s\n", service)
+	}
+
+	dbLog := ""
+	if dbLogDir != "" {
+		dbLog = fmt.Sprintf("- %s: %s\n", dbEngine, dbLogDir)
+	}
+
 	return fmt.Sprintf(`===========================================
 Laravel Production Server Setup Complete
 ===========================================
 
 Domain: %s
 Web Directory: %s
+PHP Version: %s
 
 Database Information:
+- Engine: %s
 - Database Name: %s
 - Database User: %s
-- Database Password: See mysql_credentials.txt file
+- Database Password: laravel-setup secrets get database/%s
+- Client Login: ~/%s
+- All Secrets: laravel-setup secrets list
 
 Important Security Notes:
 - SSH Port changed to: %s
@@ -71,17 +90,11 @@ Important Security Notes:
 - Fail2ban is configured
 
 Service Status Commands:
-- sudo systemctl status nginx
-- sudo systemctl status php8.4-fpm
-- sudo systemctl status mysql
-- sudo systemctl status redis-server
-- sudo systemctl status supervisor
-
+%s
 Log Locations:
 - Nginx: /var/log/nginx/
-- PHP-FPM: /var/log/php8.4-fpm.log
-- MySQL: /var/log/mysql/
-- Laravel: %s/storage/logs/
+- PHP-FPM: /var/log/php%s-fpm.log
+%s- Laravel: %s/storage/logs/
 
 Security Tools:
 - UFW Firewall: sudo ufw status
@@ -89,5 +102,5 @@ Security Tools:
 
 SSH Connection (remember the new port):
 ssh -p %s %s@your-server-ip
-`, domain, webRoot, dbName, dbUser, sshPort, webRoot, sshPort, username)
+`, domain, webRoot, phpVersion, dbEngine, dbName, dbUser, dbUser, clientFile, sshPort, status.String(), phpVersion, dbLog, webRoot, sshPort, username)
 }
@@ -4,17 +4,18 @@ import "fmt"
 
 // GetSupervisorConfig returns the Supervisor configuration for Laravel queue workers
 // This ensures Laravel queue jobs are processed reliably and automatically restarted if they fail
-func GetSupervisorConfig(webRoot, webUser string) string {
+// Workers run with the given PHP binary so they use the same version as the site
+func GetSupervisorConfig(webRoot, webUser, phpBinary string, workers int) string {
 	return fmt.Sprintf(`[program:laravel-worker]
 process_name=%%(program_name)s_%%(process_num)02d
-command=php %s/artisan queue:work --sleep=3 --tries=3 --max-time=3600
+command=%s %s/artisan queue:work --sleep=3 --tries=3 --max-time=3600
 autostart=true
 autorestart=true
 stopasgroup=true
 killasgroup=true
 user=%s
-numprocs=2
+numprocs=%d
 redirect_stderr=true
 stdout_logfile=%s/storage/logs/worker.log
-stopwaitsecs=3600`, webRoot, webUser, webRoot)
+stopwaitsecs=3600`, phpBinary, webRoot, webUser, workers, webRoot)
 }
\ No newline at end of file
@@ -10,7 +10,7 @@ func CleanupTempFiles() error {
 
 	// List of temporary files that might be created during the setup process
 	tempFiles := []string{
-		"mysql_config.sql",     // Created in mysql/install.go
+		"mysql_config.sql",     // Left behind by older versions; contains passwords
 		"nginx_site.conf",      // Created in nginx/install.go
 		"opcache.ini",          // Created in php/install.go
 		"laravel-worker.conf",  // Created in laravel/setup.go
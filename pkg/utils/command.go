@@ -1,44 +1,32 @@
 package utils
 
 import (
+	"context"
 	"os"
-	"os/exec"
 	"strings"
 )
 
 // RunCommand executes a shell command and returns the error if any
 // Streams command output to stdout and stderr for real-time feedback
-func RunCommand(command string, args ...string) error {
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func RunCommand(ctx context.Context, command string, args ...string) error {
+	return executor.Run(ctx, command, args...)
 }
 
 // RunCommandWithOutput executes a shell command and returns the output and error
 // Useful when you need to capture the output for processing
-func RunCommandWithOutput(command string, args ...string) (string, error) {
-	cmd := exec.Command(command, args...)
-	output, err := cmd.Output()
-	return strings.TrimSpace(string(output)), err
+func RunCommandWithOutput(ctx context.Context, command string, args ...string) (string, error) {
+	return executor.Output(ctx, command, args...)
 }
 
-// GenerateRandomPassword generates a random password using OpenSSL
-// Falls back to a default password if OpenSSL fails
-func GenerateRandomPassword() string {
-	output, err := RunCommandWithOutput("openssl", "rand", "-base64", "16")
-	if err != nil {
-		// If OpenSSL fails, return a default password
-		// This is not ideal but prevents the script from failing
-		return "defaultpassword"
-	}
-	return output
+// WriteSystemFile writes a root-owned system file such as a service configuration
+func WriteSystemFile(ctx context.Context, path string, content string, perm os.FileMode) error {
+	return executor.WriteFile(ctx, path, []byte(content), perm)
 }
 
 // CheckNotRoot checks if the script is run as root
 // Returns true if not running as root, false otherwise
-func CheckNotRoot() bool {
-	output, err := RunCommandWithOutput("id", "-u")
+func CheckNotRoot(ctx context.Context) bool {
+	output, err := RunCommandWithOutput(ctx, "id", "-u")
 	if err != nil {
 		PrintError("Failed to check user ID")
 		return false
@@ -58,61 +46,45 @@ func CheckNotRoot() bool {
 
 // CheckSudoPrivileges checks if the user has sudo privileges
 // Returns true if the user has sudo privileges, false otherwise
-func CheckSudoPrivileges() bool {
-	cmd := exec.Command("sudo", "-n", "true")
-	err := cmd.Run()
+func CheckSudoPrivileges(ctx context.Context) bool {
+	_, err := RunCommandWithOutput(ctx, "sudo", "-n", "true")
 	return err == nil
 }
 
 // RunInteractiveCommand executes a shell command that requires user interaction
 // Connects stdin, stdout, and stderr to allow for interactive input/output
-func RunInteractiveCommand(command string, args ...string) error {
-	cmd := exec.Command(command, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func RunInteractiveCommand(ctx context.Context, command string, args ...string) error {
+	return executor.RunInteractive(ctx, command, args...)
 }
 
-// RunCommandWithFileInput executes a shell command with the contents of a file as input
+// RunCommandWithInput executes a shell command with the given data as input
 // Useful for commands that would normally use shell redirection (e.g., mysql < file.sql)
-func RunCommandWithFileInput(inputFile string, command string, args ...string) error {
-	// Read the input file
-	input, err := os.ReadFile(inputFile)
-	if err != nil {
-		return err
-	}
-
-	// Create the command
-	cmd := exec.Command(command, args...)
-
-	// Create a pipe to the command's stdin
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return err
-	}
-
-	// Set up stdout and stderr
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func RunCommandWithInput(ctx context.Context, input []byte, command string, args ...string) error {
+	return executor.RunWithInput(ctx, input, command, args...)
+}
 
-	// Start the command
-	if err := cmd.Start(); err != nil {
-		return err
+// RunCommandWithEnv executes a shell command with extra environment variables, given as KEY=value
+// The variables are passed on stdin and exported by a shell, so secrets don't show up in the process list
+// The command's own stdin is empty
+func RunCommandWithEnv(ctx context.Context, env []string, command string, args ...string) error {
+	var input strings.Builder
+	for _, variable := range env {
+		key, value, _ := strings.Cut(variable, "=")
+		input.WriteString(key + "='" + strings.ReplaceAll(value, "'", `'\''`) + "'\n")
 	}
 
-	// Write the file contents to the command's stdin
-	_, err = stdin.Write(input)
-	if err != nil {
-		return err
-	}
+	script := `set -a; . /dev/stdin; set +a; exec "$@" </dev/null`
+	return RunCommandWithInput(ctx, []byte(input.String()), "sh", append([]string{"-c", script, "sh", command}, args...)...)
+}
 
-	// Close stdin to signal EOF
-	err = stdin.Close()
+// RunCommandWithFileInput executes a shell command with the contents of a file as input
+// Useful for commands that would normally use shell redirection (e.g., mysql < file.sql)
+func RunCommandWithFileInput(ctx context.Context, inputFile string, command string, args ...string) error {
+	// Read the input file
+	input, err := os.ReadFile(inputFile)
 	if err != nil {
 		return err
 	}
 
-	// Wait for the command to complete
-	return cmd.Wait()
+	return RunCommandWithInput(ctx, input, command, args...)
 }
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Executor runs the external commands issued by the setup steps
+// Replace it with SetExecutor to run commands elsewhere, record them or fake them
+// Implementations must stop the command when the context is done
+type Executor interface {
+	// Run executes a command, streaming its output
+	Run(ctx context.Context, command string, args ...string) error
+	// Output executes a command and returns its trimmed standard output
+	Output(ctx context.Context, command string, args ...string) (string, error)
+	// RunWithInput executes a command with the given data on its standard input
+	RunWithInput(ctx context.Context, input []byte, command string, args ...string) error
+	// RunInteractive executes a command that needs a terminal for user interaction
+	RunInteractive(ctx context.Context, command string, args ...string) error
+	// WriteFile writes a file owned by root, replacing it if it exists
+	WriteFile(ctx context.Context, path string, data []byte, perm os.FileMode) error
+}
+
+// LocalExecutor runs commands on the local machine
+// When the context is done the command is sent SIGTERM and killed if it hasn't exited after StopGracePeriod
+type LocalExecutor struct {
+	// Stdout and Stderr receive the output of streamed commands
+	// They default to the process's own stdout and stderr when nil
+	Stdout io.Writer
+	Stderr io.Writer
+	// StopGracePeriod is how long a stopped command may take to exit, defaulting to 10 seconds
+	StopGracePeriod time.Duration
+}
+
+// Run executes a command, streaming its output
+func (e *LocalExecutor) Run(ctx context.Context, command string, args ...string) error {
+	cmd := e.command(ctx, command, args...)
+	cmd.Stdout = e.stdout()
+	cmd.Stderr = e.stderr()
+	return cmd.Run()
+}
+
+// Output executes a command and returns its trimmed standard output
+func (e *LocalExecutor) Output(ctx context.Context, command string, args ...string) (string, error) {
+	cmd := e.command(ctx, command, args...)
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+// RunWithInput executes a command with the given data on its standard input
+func (e *LocalExecutor) RunWithInput(ctx context.Context, input []byte, command string, args ...string) error {
+	cmd := e.command(ctx, command, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = e.stdout()
+	cmd.Stderr = e.stderr()
+	return cmd.Run()
+}
+
+// RunInteractive executes a command connected to the process's terminal
+func (e *LocalExecutor) RunInteractive(ctx context.Context, command string, args ...string) error {
+	cmd := e.command(ctx, command, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// WriteFile writes a file owned by root, replacing it if it exists
+// The content is staged in a temporary file and installed with sudo
+func (e *LocalExecutor) WriteFile(ctx context.Context, path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp("", "laravel-setup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return e.Run(ctx, "sudo", "install", "-m", fmt.Sprintf("%04o", perm.Perm()), tmp.Name(), path)
+}
+
+// command creates a command that is stopped gracefully when the context is done
+func (e *LocalExecutor) command(ctx context.Context, command string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = e.StopGracePeriod
+	if cmd.WaitDelay == 0 {
+		cmd.WaitDelay = 10 * time.Second
+	}
+	return cmd
+}
+
+func (e *LocalExecutor) stdout() io.Writer {
+	if e.Stdout != nil {
+		return e.Stdout
+	}
+	return os.Stdout
+}
+
+func (e *LocalExecutor) stderr() io.Writer {
+	if e.Stderr != nil {
+		return e.Stderr
+	}
+	return os.Stderr
+}
+
+// executor is the Executor used by the package-level Run* functions
+var executor Executor = &LocalExecutor{}
+
+// SetExecutor replaces the Executor used by the package-level Run* functions
+// Returns the previous Executor so callers can restore it
+func SetExecutor(e Executor) Executor {
+	previous := executor
+	executor = e
+	return previous
+}
+
+// GetExecutor returns the Executor used by the package-level Run* functions
+func GetExecutor() Executor {
+	return executor
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Characters of generated passwords
+// The special characters need no quoting in .env, option files, .pgpass or URLs
+const (
+	passwordLower   = "abcdefghijklmnopqrstuvwxyz"
+	passwordUpper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordDigits  = "0123456789"
+	passwordSpecial = "-_."
+	passwordLength  = 24
+)
+
+// GeneratePassword returns a random password from crypto/rand
+// It always has a lowercase and an uppercase letter, a digit and a special character, so it passes
+// every MySQL validate_password policy, up to STRONG
+// The password is recorded with AddSecret, so it is redacted from reports before it is even stored
+func GeneratePassword() (string, error) {
+	classes := []string{passwordLower, passwordUpper, passwordDigits, passwordSpecial}
+	alphabet := strings.Join(classes, "")
+
+	password := make([]byte, passwordLength)
+	for i := range password {
+		c, err := randomChar(alphabet)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	// Put one character of each class at distinct random positions
+	positions := make([]int, passwordLength)
+	for i := range positions {
+		positions[i] = i
+	}
+	for i, class := range classes {
+		j, err := randomInt(passwordLength - i)
+		if err != nil {
+			return "", err
+		}
+		positions[i], positions[i+j] = positions[i+j], positions[i]
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+		password[positions[i]] = c
+	}
+	AddSecret(string(password))
+	return string(password), nil
+}
+
+// randomChar returns a random character of chars
+func randomChar(chars string) (byte, error) {
+	i, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+// randomInt returns a uniform random number in [0, n)
+func randomInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate a password: %w", err)
+	}
+	return int(i.Int64()), nil
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		password, err := GeneratePassword()
+		if err != nil {
+			t.Fatalf("GeneratePassword() error = %v", err)
+		}
+		if len(password) != passwordLength {
+			t.Fatalf("GeneratePassword() = %q, want %d characters", password, passwordLength)
+		}
+		for _, class := range []string{passwordLower, passwordUpper, passwordDigits, passwordSpecial} {
+			if !strings.ContainsAny(password, class) {
+				t.Fatalf("GeneratePassword() = %q, has none of %q", password, class)
+			}
+		}
+		if seen[password] {
+			t.Fatalf("GeneratePassword() returned %q twice", password)
+		}
+		seen[password] = true
+	}
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"fmt"
+	"io"
+	"os"
 )
 
 // Color codes for terminal output
@@ -13,31 +15,90 @@ const (
 	ColorReset  = "\033[0m"
 )
 
+// Logger receives the progress messages printed by the setup steps
+// Replace it with SetLogger to send messages somewhere other than the terminal
+type Logger interface {
+	Status(message string)
+	Warning(message string)
+	Error(message string)
+	Header(message string)
+	Information(message string)
+}
+
+// ConsoleLogger prints colored messages for a terminal
+type ConsoleLogger struct {
+	// Out receives the messages, defaulting to stdout when nil
+	Out io.Writer
+}
+
+// Status prints a status message with green color
+func (l *ConsoleLogger) Status(message string) {
+	_, _ = fmt.Fprintf(l.out(), "%s[INFO]%s %s\n", ColorGreen, ColorReset, message)
+}
+
+// Warning prints a warning message with yellow color
+func (l *ConsoleLogger) Warning(message string) {
+	_, _ = fmt.Fprintf(l.out(), "%s[WARNING]%s %s\n", ColorYellow, ColorReset, message)
+}
+
+// Error prints an error message with red color
+func (l *ConsoleLogger) Error(message string) {
+	_, _ = fmt.Fprintf(l.out(), "%s[ERROR]%s %s\n", ColorRed, ColorReset, message)
+}
+
+// Header prints a header with blue color
+func (l *ConsoleLogger) Header(message string) {
+	_, _ = fmt.Fprintf(l.out(), "%s================================%s\n", ColorBlue, ColorReset)
+	_, _ = fmt.Fprintf(l.out(), "%s%s%s\n", ColorBlue, message, ColorReset)
+	_, _ = fmt.Fprintf(l.out(), "%s================================%s\n", ColorBlue, ColorReset)
+}
+
+// Information prints information with green color
+func (l *ConsoleLogger) Information(message string) {
+	_, _ = fmt.Fprintf(l.out(), "%s================================%s\n", ColorGreen, ColorReset)
+	_, _ = fmt.Fprintf(l.out(), "%s%s%s\n", ColorGreen, message, ColorReset)
+	_, _ = fmt.Fprintf(l.out(), "%s================================%s\n", ColorGreen, ColorReset)
+}
+
+func (l *ConsoleLogger) out() io.Writer {
+	if l.Out != nil {
+		return l.Out
+	}
+	return os.Stdout
+}
+
+// logger is the Logger used by the package-level Print* functions
+var logger Logger = &ConsoleLogger{}
+
+// SetLogger replaces the Logger used by the package-level Print* functions
+// Returns the previous Logger so callers can restore it
+func SetLogger(l Logger) Logger {
+	previous := logger
+	logger = l
+	return previous
+}
+
 // PrintStatus prints a status message with green color
 func PrintStatus(message string) {
-	fmt.Printf("%s[INFO]%s %s\n", ColorGreen, ColorReset, message)
+	logger.Status(message)
 }
 
 // PrintWarning prints a warning message with yellow color
 func PrintWarning(message string) {
-	fmt.Printf("%s[WARNING]%s %s\n", ColorYellow, ColorReset, message)
+	logger.Warning(message)
 }
 
 // PrintError prints an error message with red color
 func PrintError(message string) {
-	fmt.Printf("%s[ERROR]%s %s\n", ColorRed, ColorReset, message)
+	logger.Error(message)
 }
 
 // PrintHeader prints a header with blue color
 func PrintHeader(message string) {
-	fmt.Printf("%s================================%s\n", ColorBlue, ColorReset)
-	fmt.Printf("%s%s%s\n", ColorBlue, message, ColorReset)
-	fmt.Printf("%s================================%s\n", ColorBlue, ColorReset)
+	logger.Header(message)
 }
 
 // PrintInformation prints information with green color
 func PrintInformation(message string) {
-	fmt.Printf("%s================================%s\n", ColorGreen, ColorReset)
-	fmt.Printf("%s%s%s\n", ColorGreen, message, ColorReset)
-	fmt.Printf("%s================================%s\n", ColorGreen, ColorReset)
+	logger.Information(message)
 }
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Prompter asks the user for input during the setup
+// Replace it with SetPrompter to answer questions programmatically
+type Prompter interface {
+	// Prompt asks a question and returns the trimmed answer
+	Prompt(question string) (string, error)
+	// Confirm asks a yes/no question and reports whether the answer was yes
+	Confirm(question string) (bool, error)
+}
+
+// StdinPrompter asks questions on stdout and reads the answers from stdin
+type StdinPrompter struct {
+	// Out receives the questions, defaulting to stdout when nil
+	Out io.Writer
+
+	reader *bufio.Reader
+}
+
+// NewStdinPrompter creates a Prompter that reads answers from the given reader
+func NewStdinPrompter(in io.Reader) *StdinPrompter {
+	return &StdinPrompter{reader: bufio.NewReader(in)}
+}
+
+// Prompt asks a question and returns the trimmed answer
+func (p *StdinPrompter) Prompt(question string) (string, error) {
+	out := p.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	_, _ = fmt.Fprint(out, question)
+	answer, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// PromptHidden asks a question with the terminal's echo turned off, for passphrases
+// Without a terminal, stty fails and the answer is read as it is
+func (p *StdinPrompter) PromptHidden(question string) (string, error) {
+	ctx := context.Background()
+	if err := RunInteractiveCommand(ctx, "stty", "-echo"); err == nil {
+		defer func() {
+			_ = RunInteractiveCommand(ctx, "stty", "echo")
+			// The newline typed by the user wasn't echoed
+			out := p.Out
+			if out == nil {
+				out = os.Stdout
+			}
+			_, _ = fmt.Fprintln(out)
+		}()
+	}
+	return p.Prompt(question)
+}
+
+// Confirm asks a yes/no question and reports whether the answer was yes
+func (p *StdinPrompter) Confirm(question string) (bool, error) {
+	answer, err := p.Prompt(question + " (y/n): ")
+	if err != nil {
+		return false, err
+	}
+	return answer == "y" || answer == "Y", nil
+}
+
+// prompter is the Prompter used by Prompt and Confirm
+var prompter Prompter = NewStdinPrompter(os.Stdin)
+
+// SetPrompter replaces the Prompter used by Prompt and Confirm
+// Returns the previous Prompter so callers can restore it
+func SetPrompter(p Prompter) Prompter {
+	previous := prompter
+	prompter = p
+	return previous
+}
+
+// Prompt asks the user a question and returns the trimmed answer
+func Prompt(question string) (string, error) {
+	return prompter.Prompt(question)
+}
+
+// PromptHidden asks the user a question without echoing the answer
+// Prompters that can't hide the answer, such as ones answering programmatically, are asked with Prompt
+func PromptHidden(question string) (string, error) {
+	if hidden, ok := prompter.(interface {
+		PromptHidden(question string) (string, error)
+	}); ok {
+		return hidden.PromptHidden(question)
+	}
+	return prompter.Prompt(question)
+}
+
+// Confirm asks the user a yes/no question and reports whether the answer was yes
+func Confirm(question string) (bool, error) {
+	return prompter.Confirm(question)
+}
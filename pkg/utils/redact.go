@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// secrets holds every password, key and passphrase the process has handled, for Redact
+var (
+	secretsMu sync.Mutex
+	secrets   = map[string]bool{}
+)
+
+// AddSecret records values that must not appear in what is reported, such as events and logs
+// Empty values are ignored
+func AddSecret(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, value := range values {
+		if value != "" {
+			secrets[value] = true
+		}
+	}
+}
+
+// Redact replaces every recorded secret in s
+// Longer secrets are replaced first, so one containing another is replaced whole
+func Redact(s string) string {
+	secretsMu.Lock()
+	values := make([]string, 0, len(secrets))
+	for value := range secrets {
+		values = append(values, value)
+	}
+	secretsMu.Unlock()
+
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, "********")
+	}
+	return s
+}
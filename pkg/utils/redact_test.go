@@ -0,0 +1,32 @@
+package utils
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	AddSecret("", "s3cret", "s3cret-longer", "p'w\\d")
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"mysql -p s3cret", "mysql -p ********"},
+		{"IDENTIFIED BY 's3cret-longer'", "IDENTIFIED BY '********'"},
+		{`ALTER USER x IDENTIFIED BY 'p'w\d'`, "ALTER USER x IDENTIFIED BY '********'"},
+		{"nothing to hide", "nothing to hide"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGeneratePasswordIsRedacted(t *testing.T) {
+	password, err := GeneratePassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Redact("password=" + password); got != "password=********" {
+		t.Errorf("Redact() = %q, want the generated password redacted", got)
+	}
+}
@@ -0,0 +1,325 @@
+package vault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"laravel-setup/pkg/config"
+	"laravel-setup/pkg/utils"
+)
+
+// HostKeyFile holds the random key that unlocks the vault in host-key mode; only root can read it
+const HostKeyFile = "/etc/laravel-setup/vault.key"
+
+// PassphraseVar passes the vault passphrase to unattended runs, such as a deploy from CI
+const PassphraseVar = "LARAVEL_SETUP_VAULT_PASSPHRASE"
+
+// Key derivation and file format
+const (
+	version          = 1
+	keySize          = 32
+	pbkdf2Iterations = 600000
+)
+
+// envelope is the vault file: the secrets encrypted with AES-256-GCM, and how to derive the key
+type envelope struct {
+	Version int    `json:"version"`
+	Unlock  string `json:"unlock"`
+	Salt    []byte `json:"salt,omitempty"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Vault holds secrets by name, such as "database/laravel", in an encrypted file in the user's home directory
+// Changes are kept in memory until Save
+type Vault struct {
+	path    string
+	unlock  string
+	secrets map[string]string
+
+	// key and salt encrypt the file in the configured unlock mode, derived on the first Save if needed
+	key  []byte
+	salt []byte
+}
+
+// passphrase is the passphrase entered in this run, so it is only asked for once
+var passphrase string
+
+// GetDefaultVaultPath returns the default path of the vault in the user's home directory
+func GetDefaultVaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".laravel-setup", "vault.json"), nil
+}
+
+// Open unlocks the vault at the default location
+// Returns an empty vault if there is none yet
+func Open(ctx context.Context, cfg *config.Config) (*Vault, error) {
+	path, err := GetDefaultVaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return OpenFile(ctx, path, cfg.Vault.UnlockMode())
+}
+
+// OpenFile unlocks the vault at path, which is saved in the unlock mode given
+// A vault saved in another mode is unlocked the way it was saved, and re-encrypted on Save
+func OpenFile(ctx context.Context, path, unlock string) (*Vault, error) {
+	v := &Vault{path: path, unlock: unlock, secrets: map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse the vault %s: %w", path, err)
+	}
+	if env.Version != version {
+		return nil, fmt.Errorf("the vault %s has version %d; this version of laravel-setup reads version %d", path, env.Version, version)
+	}
+
+	key, err := deriveKey(ctx, env.Unlock, env.Salt, false)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := decrypt(key, env)
+	if err != nil {
+		if env.Unlock == config.VaultPassphrase {
+			passphrase = ""
+			return nil, fmt.Errorf("failed to unlock the vault %s: wrong passphrase", path)
+		}
+		return nil, fmt.Errorf("failed to unlock the vault %s: %s doesn't match it", path, HostKeyFile)
+	}
+	if err := json.Unmarshal(plaintext, &v.secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse the vault %s: %w", path, err)
+	}
+
+	if env.Unlock == unlock {
+		v.key, v.salt = key, env.Salt
+	}
+	return v, nil
+}
+
+// Store sets a secret in the vault at the default location and saves it
+func Store(ctx context.Context, cfg *config.Config, name, value string) error {
+	v, err := Open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	v.Set(name, value)
+	return v.Save(ctx)
+}
+
+// Get returns a secret and whether it is set
+// Secrets read or stored are recorded with utils.AddSecret, so they are redacted from reports
+func (v *Vault) Get(name string) (string, bool) {
+	value, ok := v.secrets[name]
+	if ok {
+		utils.AddSecret(value)
+	}
+	return value, ok
+}
+
+// Set stores a secret
+func (v *Vault) Set(name, value string) {
+	utils.AddSecret(value)
+	v.secrets[name] = value
+}
+
+// Delete removes a secret
+func (v *Vault) Delete(name string) {
+	delete(v.secrets, name)
+}
+
+// Names returns the names of the secrets, sorted
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Path returns the file the vault is kept in
+func (v *Vault) Path() string {
+	return v.path
+}
+
+// Save encrypts the vault with a fresh nonce and writes it, creating its directory if needed
+// The file is replaced atomically so an interrupted write never leaves it truncated
+func (v *Vault) Save(ctx context.Context) error {
+	if v.key == nil {
+		if v.unlock == config.VaultPassphrase {
+			v.salt = make([]byte, 16)
+			if _, err := rand.Read(v.salt); err != nil {
+				return err
+			}
+		}
+		key, err := deriveKey(ctx, v.unlock, v.salt, true)
+		if err != nil {
+			return err
+		}
+		v.key = key
+	}
+
+	plaintext, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	env := envelope{Version: version, Unlock: v.unlock, Salt: v.salt}
+	if err := encrypt(v.key, &env, plaintext); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return err
+	}
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.path)
+}
+
+// additionalData binds the ciphertext to the format and unlock mode recorded next to it
+func additionalData(env *envelope) []byte {
+	return []byte(fmt.Sprintf("laravel-setup vault v%d %s", env.Version, env.Unlock))
+}
+
+// encrypt seals the plaintext into the envelope
+func encrypt(key []byte, env *envelope, plaintext []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return err
+	}
+	env.Data = gcm.Seal(nil, env.Nonce, plaintext, additionalData(env))
+	return nil
+}
+
+// decrypt opens the envelope, failing if the key is wrong or the file was changed
+func decrypt(key []byte, env envelope) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, env.Nonce, env.Data, additionalData(&env))
+}
+
+// newGCM returns AES-256-GCM with the key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey returns the key of an unlock mode
+// create makes a host key when there is none, or asks for a new passphrase twice
+func deriveKey(ctx context.Context, unlock string, salt []byte, create bool) ([]byte, error) {
+	switch unlock {
+	case config.VaultHostKey:
+		return hostKey(ctx, create)
+	case config.VaultPassphrase:
+		secret, err := readPassphrase(create)
+		if err != nil {
+			return nil, err
+		}
+		return pbkdf2.Key(sha256.New, secret, salt, pbkdf2Iterations, keySize)
+	}
+	return nil, fmt.Errorf("unknown vault unlock mode %q", unlock)
+}
+
+// hostKey reads the host key, creating it when create is set and there is none
+func hostKey(ctx context.Context, create bool) ([]byte, error) {
+	if _, err := utils.RunCommandWithOutput(ctx, "sudo", "test", "-f", HostKeyFile); err != nil {
+		if !create {
+			return nil, fmt.Errorf("the vault is locked with %s, which doesn't exist on this host", HostKeyFile)
+		}
+
+		utils.PrintStatus("Creating the vault host key " + HostKeyFile + "...")
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		err := utils.RunCommand(ctx, "sudo", "install", "-d", "-m", "0700", filepath.Dir(HostKeyFile))
+		if err != nil {
+			return nil, err
+		}
+		if err := utils.WriteSystemFile(ctx, HostKeyFile, base64.StdEncoding.EncodeToString(key)+"\n", 0600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	encoded, err := utils.RunCommandWithOutput(ctx, "sudo", "cat", HostKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", HostKeyFile, err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("%s is not a vault host key", HostKeyFile)
+	}
+	return key, nil
+}
+
+// readPassphrase returns the passphrase from PassphraseVar, or asks for it without echoing it
+// A new passphrase is asked for twice
+func readPassphrase(create bool) (string, error) {
+	if secret := os.Getenv(PassphraseVar); secret != "" {
+		utils.AddSecret(secret)
+		return secret, nil
+	}
+	if passphrase != "" {
+		return passphrase, nil
+	}
+
+	secret, err := utils.PromptHidden("Vault passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", errors.New("the vault passphrase is empty; set it in " + PassphraseVar + " or enter it when asked")
+	}
+	if create {
+		again, err := utils.PromptHidden("Repeat the vault passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != secret {
+			return "", errors.New("the vault passphrases don't match")
+		}
+	}
+
+	passphrase = secret
+	utils.AddSecret(secret)
+	return secret, nil
+}